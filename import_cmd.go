@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/importer"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+)
+
+// runImport implements `worktime import -file sessions.csv [-preset toggl] [-dry-run=false]`.
+// It uses the same parser and store as POST /admin/imports/sessions/ and prints the report as JSON.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "path to the CSV file")
+	presetName := fs.String("preset", "generic", "column mapping preset: "+strings.Join(importer.PresetNames(), ", "))
+	tz := fs.String("tz", "UTC", "time zone for times without an offset")
+	dryRun := fs.Bool("dry-run", true, "validate only, don't write anything")
+	createProjects := fs.Bool("create-projects", false, "create projects that don't exist yet")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "import: -file is required")
+		return 2
+	}
+
+	preset, ok := importer.GetPreset(*presetName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "import: unknown preset %q\n", *presetName)
+		return 2
+	}
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: invalid tz: %v\n", err)
+		return 2
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	rows, rowErrors, err := importer.ParseCSV(f, preset, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+
+	db, err := store.Open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	opts := store.ImportOptions{DryRun: *dryRun, CreateProjects: *createProjects}
+
+	report, err := importer.Import(context.Background(), store.NewPostgresImportStore(db), rows, rowErrors, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+
+	out, _ := json.MarshalIndent(report, "", " ")
+	fmt.Println(string(out))
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,538 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+type AbsenceHandler struct {
+	absenceStore store.AbsenceStore
+	logger       *log.Logger
+	Hub          *Hub
+}
+
+func NewAbsenceHandler(absenceStore store.AbsenceStore, logger *log.Logger, hub *Hub) *AbsenceHandler {
+	return &AbsenceHandler{
+		absenceStore: absenceStore,
+		logger:       logger,
+		Hub:          hub,
+	}
+}
+
+func (ah *AbsenceHandler) publish(eventType string, req *store.AbsenceRequest, by int64) {
+	ah.Hub.Publish(Event{
+		Type:   eventType,
+		UserID: req.UserId,
+		Data: map[string]any{
+			"absence_id":   req.Id,
+			"user_id":      req.UserId,
+			"absence_type": req.AbsenceTypeName,
+			"start_date":   req.StartDate,
+			"end_date":     req.EndDate,
+			"half_day":     req.HalfDay,
+			"status":       req.Status,
+			"by":           by,
+		},
+	})
+}
+
+func (ah *AbsenceHandler) HandleListAbsenceTypes(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	types, err := ah.absenceStore.ListAbsenceTypes(r.Context())
+	if err != nil {
+		ah.logger.Println("ListAbsenceTypes error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"absence_types": types})
+}
+
+func (ah *AbsenceHandler) HandleCreateAbsenceType(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name"`
+		Paid          *bool  `json:"paid"`
+		UsesAllowance *bool  `json:"uses_allowance"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	t := &store.AbsenceType{
+		Name:          strings.TrimSpace(req.Name),
+		Paid:          true,
+		UsesAllowance: true,
+	}
+	if t.Name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+	if req.Paid != nil {
+		t.Paid = *req.Paid
+	}
+	if req.UsesAllowance != nil {
+		t.UsesAllowance = *req.UsesAllowance
+	}
+
+	if err := ah.absenceStore.CreateAbsenceType(r.Context(), t); err != nil {
+		if strings.Contains(err.Error(), "absence_types_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "absence type already exists"})
+			return
+		}
+		ah.logger.Println("CreateAbsenceType error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"absence_type": t})
+}
+
+// HandleListAbsences lists absence requests. Users see their own,
+// admins see everyone's unless they pass user_id.
+func (ah *AbsenceHandler) HandleListAbsences(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	filter := store.AbsenceFilter{
+		Status: strings.ToLower(strings.TrimSpace(q.Get("status"))),
+		From:   strings.TrimSpace(q.Get("from")),
+		To:     strings.TrimSpace(q.Get("to")),
+	}
+
+	switch filter.Status {
+	case "", store.AbsencePending, store.AbsenceApproved, store.AbsenceRejected, store.AbsenceCancelled:
+	default:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid status"})
+		return
+	}
+
+	for _, d := range []string{filter.From, filter.To} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from and to must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	if u.Role == "admin" {
+		if s := strings.TrimSpace(q.Get("user_id")); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v <= 0 {
+				utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+				return
+			}
+			filter.UserID = &v
+		}
+	} else {
+		myID := u.Id
+		filter.UserID = &myID
+	}
+
+	absences, err := ah.absenceStore.ListAbsenceRequests(r.Context(), filter)
+	if err != nil {
+		ah.logger.Println("ListAbsenceRequests error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"absences": absences})
+}
+
+// HandleCreateAbsence lets a user request time off. The request starts as pending.
+// For types that use an allowance, the working days requested must fit into
+// what is still available (minus other pending requests) in each year touched.
+func (ah *AbsenceHandler) HandleCreateAbsence(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		AbsenceTypeID int64  `json:"absence_type_id"`
+		StartDate     string `json:"start_date"`
+		EndDate       string `json:"end_date"`
+		HalfDay       bool   `json:"half_day"`
+		Note          string `json:"note"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	req.StartDate = strings.TrimSpace(req.StartDate)
+	req.EndDate = strings.TrimSpace(req.EndDate)
+	if req.EndDate == "" {
+		req.EndDate = req.StartDate
+	}
+
+	start, err := time.Parse(time.DateOnly, req.StartDate)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "start_date must be YYYY-MM-DD"})
+		return
+	}
+	end, err := time.Parse(time.DateOnly, req.EndDate)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "end_date must be YYYY-MM-DD"})
+		return
+	}
+	if end.Before(start) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "end_date must not be before start_date"})
+		return
+	}
+	if req.HalfDay && !end.Equal(start) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "a half day must start and end on the same date"})
+		return
+	}
+
+	if req.AbsenceTypeID <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "absence_type_id must be positive"})
+		return
+	}
+
+	absenceType, err := ah.absenceStore.GetAbsenceType(r.Context(), req.AbsenceTypeID)
+	if err != nil {
+		ah.logger.Println("GetAbsenceType error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if absenceType == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "absence type not found"})
+		return
+	}
+
+	overlaps, err := ah.absenceStore.HasOverlappingAbsence(r.Context(), u.Id, req.StartDate, req.EndDate)
+	if err != nil {
+		ah.logger.Println("HasOverlappingAbsence error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if overlaps {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "you already have an absence in this period"})
+		return
+	}
+
+	daysByYear, err := ah.absenceStore.CountAbsenceDays(r.Context(), u.Id, req.StartDate, req.EndDate, req.HalfDay)
+	if err != nil {
+		ah.logger.Println("CountAbsenceDays error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if len(daysByYear) == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the period has no working days"})
+		return
+	}
+
+	if absenceType.UsesAllowance {
+		years := make([]int, 0, len(daysByYear))
+		for y := range daysByYear {
+			years = append(years, y)
+		}
+		sort.Ints(years)
+
+		for _, y := range years {
+			balance, err := ah.absenceStore.GetAllowanceBalance(r.Context(), u.Id, absenceType.Id, y)
+			if err != nil {
+				ah.logger.Println("GetAllowanceBalance error:", err)
+				utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+				return
+			}
+			// no allowance set up for that year: nothing to check against
+			if balance == nil {
+				continue
+			}
+			if left := balance.Available - balance.Pending; daysByYear[y] > left {
+				utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{
+					"error": fmt.Sprintf("not enough %s allowance in %d: %.1f days requested, %.1f left", absenceType.Name, y, daysByYear[y], left),
+				})
+				return
+			}
+		}
+	}
+
+	absence := &store.AbsenceRequest{
+		UserId:        u.Id,
+		AbsenceTypeId: absenceType.Id,
+		StartDate:     req.StartDate,
+		EndDate:       req.EndDate,
+		HalfDay:       req.HalfDay,
+		Note:          strings.TrimSpace(req.Note),
+	}
+
+	if err := ah.absenceStore.CreateAbsenceRequest(r.Context(), absence); err != nil {
+		ah.logger.Println("CreateAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	created, err := ah.absenceStore.GetAbsenceRequest(r.Context(), absence.Id)
+	if err != nil || created == nil {
+		ah.logger.Println("GetAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ah.publish("absence_requested", created, u.Id)
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"absence": created})
+}
+
+func (ah *AbsenceHandler) HandleApproveAbsence(w http.ResponseWriter, r *http.Request) {
+	ah.decide(w, r, store.AbsenceApproved)
+}
+
+func (ah *AbsenceHandler) HandleRejectAbsence(w http.ResponseWriter, r *http.Request) {
+	ah.decide(w, r, store.AbsenceRejected)
+}
+
+// decide approves or rejects a pending request (admin only) and notifies the user.
+func (ah *AbsenceHandler) decide(w http.ResponseWriter, r *http.Request, status string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	u, _ := middleware.GetUser(r)
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+
+	// the body is optional
+	if r.ContentLength != 0 {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&req); err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+			return
+		}
+	}
+
+	err = ah.absenceStore.DecideAbsenceRequest(r.Context(), id, status, u.Id, strings.TrimSpace(req.Note))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ah.logger.Println("DecideAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	notPending := err != nil
+
+	absence, err := ah.absenceStore.GetAbsenceRequest(r.Context(), id)
+	if err != nil {
+		ah.logger.Println("GetAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if absence == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "absence not found"})
+		return
+	}
+	if notPending {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "absence is already " + absence.Status})
+		return
+	}
+
+	ah.publish("absence_"+status, absence, u.Id)
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"absence": absence})
+}
+
+// HandleCancelAbsence withdraws a request. Users can cancel their own pending requests,
+// and approved ones that haven't started yet; admins can cancel any open request.
+func (ah *AbsenceHandler) HandleCancelAbsence(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	absence, err := ah.absenceStore.GetAbsenceRequest(r.Context(), id)
+	if err != nil {
+		ah.logger.Println("GetAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if absence == nil || (absence.UserId != u.Id && u.Role != "admin") {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "absence not found"})
+		return
+	}
+
+	if u.Role != "admin" && absence.Status == store.AbsenceApproved &&
+		absence.StartDate <= time.Now().UTC().Format(time.DateOnly) {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "an approved absence can't be cancelled once it has started"})
+		return
+	}
+
+	if err := ah.absenceStore.CancelAbsenceRequest(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "absence is already " + absence.Status})
+			return
+		}
+		ah.logger.Println("CancelAbsenceRequest error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	absence.Status = store.AbsenceCancelled
+
+	ah.publish("absence_cancelled", absence, u.Id)
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"absence": absence})
+}
+
+// HandleListBalances shows allowance balances for a year (default: current year).
+func (ah *AbsenceHandler) HandleListBalances(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	year := utils.ReadInt(r, "year", time.Now().Year())
+	if year < 2000 || year > 9999 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid year"})
+		return
+	}
+
+	userID := u.Id
+	if s := strings.TrimSpace(r.URL.Query().Get("user_id")); s != "" && u.Role == "admin" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return
+		}
+		userID = v
+	}
+
+	balances, err := ah.absenceStore.ListAllowanceBalances(r.Context(), userID, year)
+	if err != nil {
+		ah.logger.Println("ListAllowanceBalances error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"user_id": userID, "year": year, "balances": balances})
+}
+
+// HandleSetAllowance creates or replaces a user's yearly allowance for one absence type.
+func (ah *AbsenceHandler) HandleSetAllowance(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		AbsenceTypeID    int64   `json:"absence_type_id"`
+		Year             int     `json:"year"`
+		Days             float64 `json:"days"`
+		Accrual          string  `json:"accrual"`
+		CarryOverMaxDays float64 `json:"carry_over_max_days"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	req.Accrual = strings.ToLower(strings.TrimSpace(req.Accrual))
+	if req.Accrual == "" {
+		req.Accrual = store.AccrualUpfront
+	}
+
+	switch {
+	case req.AbsenceTypeID <= 0:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "absence_type_id must be positive"})
+		return
+	case req.Year < 2000 || req.Year > 9999:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid year"})
+		return
+	case req.Days < 0 || req.Days > 366:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "days must be between 0 and 366"})
+		return
+	case req.Accrual != store.AccrualUpfront && req.Accrual != store.AccrualMonthly:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "accrual must be 'upfront' or 'monthly'"})
+		return
+	case req.CarryOverMaxDays < 0:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "carry_over_max_days can't be negative"})
+		return
+	}
+
+	allowance := &store.AbsenceAllowance{
+		UserId:           userID,
+		AbsenceTypeId:    req.AbsenceTypeID,
+		Year:             req.Year,
+		Days:             req.Days,
+		Accrual:          req.Accrual,
+		CarryOverMaxDays: req.CarryOverMaxDays,
+	}
+
+	if err := ah.absenceStore.SetAllowance(r.Context(), allowance); err != nil {
+		if strings.Contains(err.Error(), "absence_allowances_user_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "absence_allowances_absence_type_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "absence type not found"})
+			return
+		}
+		ah.logger.Println("SetAllowance error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"allowance": allowance})
+}
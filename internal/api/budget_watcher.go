@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/store"
+)
+
+// BudgetWatcher checks project budgets periodically, since running sessions
+// keep consuming them, and tells admins when a threshold is reached.
+type BudgetWatcher struct {
+	projectStore store.ProjectStore
+	logger       *log.Logger
+	Hub          *Hub
+}
+
+func NewBudgetWatcher(projectStore store.ProjectStore, logger *log.Logger, hub *Hub) *BudgetWatcher {
+	return &BudgetWatcher{
+		projectStore: projectStore,
+		logger:       logger,
+		Hub:          hub,
+	}
+}
+
+// Check publishes a project_budget_alert event (admins only) for every newly reached threshold.
+func (bw *BudgetWatcher) Check(ctx context.Context) {
+	alerts, err := bw.projectStore.RecordBudgetAlerts(ctx)
+	if err != nil {
+		bw.logger.Println("RecordBudgetAlerts error:", err)
+		return
+	}
+
+	for _, a := range alerts {
+		bw.Hub.Publish(Event{
+			Type: "project_budget_alert",
+			Data: map[string]any{
+				"project_id":   a.ProjectId,
+				"project_name": a.ProjectName,
+				"threshold":    a.Threshold,
+				"budget":       a.Budget,
+				"usage":        a.Usage,
+			},
+		})
+	}
+}
+
+// Run checks every interval until ctx is done.
+func (bw *BudgetWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		bw.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
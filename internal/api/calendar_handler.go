@@ -0,0 +1,213 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htojiddinov77-png/worktime/internal/ical"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// how far back a feed goes; calendar apps re-download the whole feed on every refresh
+const calendarFeedWindow = 365 * 24 * time.Hour
+
+type CalendarHandler struct {
+	calendarStore store.CalendarStore
+	logger        *log.Logger
+}
+
+func NewCalendarHandler(calendarStore store.CalendarStore, logger *log.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		calendarStore: calendarStore,
+		logger:        logger,
+	}
+}
+
+func readCalendarScope(r *http.Request) (string, error) {
+	scope := strings.ToLower(utils.ReadString(r, "scope", store.CalendarScopeUser))
+	if scope != store.CalendarScopeUser && scope != store.CalendarScopeTeam {
+		return "", errors.New("scope must be 'user' or 'team'")
+	}
+	return scope, nil
+}
+
+func (ch *CalendarHandler) HandleListCalendarTokens(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	tokens, err := ch.calendarStore.ListCalendarTokens(r.Context(), u.Id)
+	if err != nil {
+		ch.logger.Println("ListCalendarTokens error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"tokens": tokens})
+}
+
+// HandleCreateCalendarToken creates (or regenerates) the caller's feed token.
+// The plain token is returned only once; only its hash is stored.
+func (ch *CalendarHandler) HandleCreateCalendarToken(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	scope, err := readCalendarScope(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if scope == store.CalendarScopeTeam && u.Role != "admin" {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "only admin can create a team feed"})
+		return
+	}
+
+	plain, err := generateShortToken(32)
+	if err != nil {
+		ch.logger.Println("generateShortToken error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token := &store.CalendarToken{
+		UserId:    u.Id,
+		Scope:     scope,
+		TokenHash: hashToken(plain),
+	}
+
+	if err := ch.calendarStore.CreateCalendarToken(r.Context(), token); err != nil {
+		ch.logger.Println("CreateCalendarToken error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{
+		"token":    token,
+		"feed_url": utils.PublicURL("/api/v1/calendar/" + plain + ".ics"),
+	})
+}
+
+func (ch *CalendarHandler) HandleRevokeCalendarToken(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	scope, err := readCalendarScope(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ch.calendarStore.RevokeCalendarToken(r.Context(), u.Id, scope)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "no active calendar token"})
+			return
+		}
+		ch.logger.Println("RevokeCalendarToken error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "calendar token revoked"})
+}
+
+// HandleCalendarFeed serves GET /calendar/{token}.ics. It is public: the token is the credential.
+func (ch *CalendarHandler) HandleCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	plain := chi.URLParam(r, "token")
+	if plain == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	token, err := ch.calendarStore.GetCalendarTokenByHash(r.Context(), hashToken(plain))
+	if err != nil {
+		ch.logger.Println("GetCalendarTokenByHash error:", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// a team feed stops working once its owner is no longer admin
+	if token == nil || (token.Scope == store.CalendarScopeTeam && token.UserRole != "admin") {
+		http.NotFound(w, r)
+		return
+	}
+
+	filter := store.CalendarSessionFilter{
+		Since: time.Now().Add(-calendarFeedWindow),
+	}
+
+	if s := strings.TrimSpace(r.URL.Query().Get("project_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			http.Error(w, "invalid project_id", http.StatusBadRequest)
+			return
+		}
+		filter.ProjectID = &v
+	}
+
+	name := "Worktime"
+	if token.Scope == store.CalendarScopeUser {
+		uid := token.UserId
+		filter.UserID = &uid
+	} else {
+		name = "Worktime (team)"
+	}
+
+	sessions, err := ch.calendarStore.ListCalendarSessions(r.Context(), filter)
+	if err != nil {
+		ch.logger.Println("ListCalendarSessions error:", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	events := make([]ical.Event, 0, len(sessions))
+
+	for _, s := range sessions {
+		summary := s.ProjectName
+		if token.Scope == store.CalendarScopeTeam {
+			summary = fmt.Sprintf("%s (%s)", s.ProjectName, s.UserName)
+		}
+
+		end := now
+		if s.EndAt != nil {
+			end = *s.EndAt
+		} else {
+			summary += " - running"
+		}
+
+		events = append(events, ical.Event{
+			UID:         fmt.Sprintf("work-session-%d@worktime", s.SessionId),
+			Start:       s.StartAt,
+			End:         end,
+			Summary:     summary,
+			Description: s.Note,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := ical.Write(w, name, events); err != nil {
+		ch.logger.Println("ical write error:", err)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+type ClientHandler struct {
+	clientStore store.ClientStore
+	logger      *log.Logger
+}
+
+func NewClientHandler(clientStore store.ClientStore, logger *log.Logger) *ClientHandler {
+	return &ClientHandler{
+		clientStore: clientStore,
+		logger:      logger,
+	}
+}
+
+func (ch *ClientHandler) HandleListClients(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	clients, err := ch.clientStore.ListClients(r.Context())
+	if err != nil {
+		ch.logger.Println("ListClients error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"clients": clients})
+}
+
+// readNameBody decodes {"name": ...} for creating and renaming clients and tasks.
+func readNameBody(r *http.Request) (string, string) {
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		return "", "invalid JSON body"
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", "name can't be empty"
+	}
+	return name, ""
+}
+
+func (ch *ClientHandler) HandleCreateClient(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name, msg := readNameBody(r)
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	c := &store.Client{Name: name}
+	if err := ch.clientStore.CreateClient(r.Context(), c); err != nil {
+		if strings.Contains(err.Error(), "clients_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "client already exists"})
+			return
+		}
+		ch.logger.Println("CreateClient error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"client": c})
+}
+
+func (ch *ClientHandler) HandleRenameClient(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	name, msg := readNameBody(r)
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	if err := ch.clientStore.RenameClient(r.Context(), id, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "client not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "clients_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "client already exists"})
+			return
+		}
+		ch.logger.Println("RenameClient error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "client renamed"})
+}
+
+// HandleDeleteClient removes a client. Its projects and their sessions stay, without a client.
+func (ch *ClientHandler) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := ch.clientStore.DeleteClient(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "client not found"})
+			return
+		}
+		ch.logger.Println("DeleteClient error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "client deleted"})
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// maxCustomTextLength bounds text values and enum options.
+const maxCustomTextLength = 500
+
+type CustomFieldHandler struct {
+	customFieldStore store.CustomFieldStore
+	logger           *log.Logger
+}
+
+func NewCustomFieldHandler(customFieldStore store.CustomFieldStore, logger *log.Logger) *CustomFieldHandler {
+	return &CustomFieldHandler{
+		customFieldStore: customFieldStore,
+		logger:           logger,
+	}
+}
+
+// HandleListCustomFields lists the fields of ?entity=project|session, or all of them.
+func (ch *CustomFieldHandler) HandleListCustomFields(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	entity := strings.TrimSpace(r.URL.Query().Get("entity"))
+	if entity != "" && !validCustomFieldEntity(entity) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "entity must be project or session"})
+		return
+	}
+
+	fields, err := ch.customFieldStore.ListCustomFields(r.Context(), entity)
+	if err != nil {
+		ch.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"custom_fields": fields})
+}
+
+func (ch *CustomFieldHandler) HandleCreateCustomField(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Entity   string   `json:"entity"`
+		Key      string   `json:"key"`
+		Name     string   `json:"name"`
+		Type     string   `json:"type"`
+		Options  []string `json:"options"`
+		Required bool     `json:"required"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	f := &store.CustomField{
+		Entity:   strings.TrimSpace(req.Entity),
+		Key:      strings.TrimSpace(req.Key),
+		Name:     strings.TrimSpace(req.Name),
+		Type:     strings.TrimSpace(req.Type),
+		Required: req.Required,
+	}
+
+	var msg string
+	switch {
+	case !validCustomFieldEntity(f.Entity):
+		msg = "entity must be project or session"
+	case !store.ValidCustomFieldKey(f.Key):
+		msg = "key must be 1 to 50 lowercase letters, digits or underscores, starting with a letter"
+	case f.Name == "":
+		msg = "name can't be empty"
+	}
+	if msg == "" {
+		switch f.Type {
+		case store.CustomFieldEnum:
+			f.Options, msg = normalizeOptions(req.Options)
+		case store.CustomFieldText, store.CustomFieldNumber, store.CustomFieldDate:
+			if len(req.Options) > 0 {
+				msg = "only enum fields have options"
+			}
+		default:
+			msg = "type must be text, number, enum or date"
+		}
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	if err := ch.customFieldStore.CreateCustomField(r.Context(), f); err != nil {
+		if strings.Contains(err.Error(), "custom_fields_entity_key_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "custom field already exists"})
+			return
+		}
+		ch.logger.Println("CreateCustomField error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"custom_field": f})
+}
+
+// HandleUpdateCustomField renames a field, replaces an enum's options or changes whether it is required.
+// The entity, key and type are fixed; stored values are kept as they are.
+func (ch *CustomFieldHandler) HandleUpdateCustomField(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		Name     *string   `json:"name"`
+		Options  *[]string `json:"options"`
+		Required *bool     `json:"required"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.Name == nil && req.Options == nil && req.Required == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "at least one field is required: name, options or required"})
+		return
+	}
+
+	if req.Name != nil {
+		n := strings.TrimSpace(*req.Name)
+		if n == "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+			return
+		}
+		req.Name = &n
+	}
+
+	f, err := ch.customFieldStore.GetCustomField(r.Context(), id)
+	if err != nil {
+		ch.logger.Println("GetCustomField error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if f == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "custom field not found"})
+		return
+	}
+
+	var options []string
+	if req.Options != nil {
+		if f.Type != store.CustomFieldEnum {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "only enum fields have options"})
+			return
+		}
+		var msg string
+		options, msg = normalizeOptions(*req.Options)
+		if msg != "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+			return
+		}
+	}
+
+	if err := ch.customFieldStore.UpdateCustomField(r.Context(), id, req.Name, options, req.Required); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "custom field not found"})
+			return
+		}
+		ch.logger.Println("UpdateCustomField error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "custom field updated"})
+}
+
+// HandleDeleteCustomField deletes a field along with its values.
+func (ch *CustomFieldHandler) HandleDeleteCustomField(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := ch.customFieldStore.DeleteCustomField(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "custom field not found"})
+			return
+		}
+		ch.logger.Println("DeleteCustomField error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "custom field deleted"})
+}
+
+func validCustomFieldEntity(entity string) bool {
+	return entity == store.CustomFieldEntityProject || entity == store.CustomFieldEntitySession
+}
+
+// normalizeOptions trims an enum's options; there must be at least one, without duplicates.
+func normalizeOptions(options []string) ([]string, string) {
+	out := make([]string, 0, len(options))
+	for _, o := range options {
+		o = strings.TrimSpace(o)
+		if o == "" || len(o) > maxCustomTextLength {
+			return nil, fmt.Sprintf("options must be 1 to %d characters", maxCustomTextLength)
+		}
+		if slices.Contains(out, o) {
+			return nil, "duplicate option: " + o
+		}
+		out = append(out, o)
+	}
+	if len(out) == 0 {
+		return nil, "enum fields need at least one option"
+	}
+	return out, ""
+}
+
+// applyCustomValues validates the custom values sent for a project or session against the
+// entity's fields and applies them to current; null removes a value. A required field can't be
+// removed, and with creating it must be sent. Records that predate a required field keep working.
+func applyCustomValues(fields []store.CustomField, current store.CustomValues, sent map[string]json.RawMessage, creating bool) (store.CustomValues, string) {
+	out := store.CustomValues{}
+	for k, v := range current {
+		out[k] = v
+	}
+
+	for key, raw := range sent {
+		i := slices.IndexFunc(fields, func(f store.CustomField) bool { return f.Key == key })
+		if i < 0 {
+			return nil, "unknown custom field: " + key
+		}
+		f := fields[i]
+
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if f.Required {
+				return nil, "custom field " + key + " is required"
+			}
+			delete(out, key)
+			continue
+		}
+
+		v, msg := parseCustomValue(f, raw)
+		if msg != "" {
+			return nil, msg
+		}
+		out[key] = v
+	}
+
+	if creating {
+		for _, f := range fields {
+			if _, ok := out[f.Key]; f.Required && !ok {
+				return nil, "custom field " + f.Key + " is required"
+			}
+		}
+	}
+	return out, ""
+}
+
+// parseCustomValue checks a JSON value against the field's type.
+func parseCustomValue(f store.CustomField, raw json.RawMessage) (any, string) {
+	if f.Type == store.CustomFieldNumber {
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, "custom field " + f.Key + " must be a number"
+		}
+		return n, ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, "custom field " + f.Key + " must be a string"
+	}
+	return parseCustomString(f, s)
+}
+
+// parseCustomString checks a text, enum or date value, or reads a number from a query string.
+func parseCustomString(f store.CustomField, s string) (any, string) {
+	s = strings.TrimSpace(s)
+
+	switch f.Type {
+	case store.CustomFieldNumber:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, "custom field " + f.Key + " must be a number"
+		}
+		return n, ""
+	case store.CustomFieldEnum:
+		if !slices.Contains(f.Options, s) {
+			return nil, "custom field " + f.Key + " must be one of: " + strings.Join(f.Options, ", ")
+		}
+	case store.CustomFieldDate:
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, "custom field " + f.Key + " must be a YYYY-MM-DD date"
+		}
+	default:
+		if s == "" || len(s) > maxCustomTextLength {
+			return nil, fmt.Sprintf("custom field %s must be 1 to %d characters", f.Key, maxCustomTextLength)
+		}
+	}
+	return s, ""
+}
+
+// readCustomFilters reads the prefix<key>=value query parameters, such as field.ticket=ABC-1,
+// into the values a record must have.
+func readCustomFilters(q url.Values, prefix string, fields []store.CustomField) (store.CustomValues, string) {
+	out := store.CustomValues{}
+	for param, values := range q {
+		key, ok := strings.CutPrefix(param, prefix)
+		if !ok {
+			continue
+		}
+
+		i := slices.IndexFunc(fields, func(f store.CustomField) bool { return f.Key == key })
+		if i < 0 {
+			return nil, "unknown custom field: " + key
+		}
+
+		v, msg := parseCustomString(fields[i], values[0])
+		if msg != "" {
+			return nil, msg
+		}
+		out[key] = v
+	}
+	return out, ""
+}
+
+// readCustomValues loads the entity's fields and applies the values sent in a request with
+// applyCustomValues. A message means the client sent something wrong.
+func readCustomValues(ctx context.Context, fieldStore store.CustomFieldStore, entity string, current store.CustomValues, sent map[string]json.RawMessage, creating bool) (store.CustomValues, string, error) {
+	fields, err := fieldStore.ListCustomFields(ctx, entity)
+	if err != nil {
+		return nil, "", err
+	}
+	values, msg := applyCustomValues(fields, current, sent, creating)
+	return values, msg, nil
+}
+
+// checkReportFields returns a message for the first custom field dimension of a report,
+// such as "field.ticket", whose field isn't defined.
+func checkReportFields(ctx context.Context, fieldStore store.CustomFieldStore, dimensions []string) (string, error) {
+	var fields []store.CustomField
+	for _, d := range dimensions {
+		entity, key, ok := store.CustomFieldDimension(d)
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			var err error
+			if fields, err = fieldStore.ListCustomFields(ctx, ""); err != nil {
+				return "", err
+			}
+		}
+		if !slices.ContainsFunc(fields, func(f store.CustomField) bool { return f.Entity == entity && f.Key == key }) {
+			return "unknown custom field: " + d, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,536 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/ical"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// holidays longer than this in one ICS event are almost certainly not public holidays
+const maxHolidayEventDays = 14
+
+type HolidayHandler struct {
+	holidayStore store.HolidayStore
+	logger       *log.Logger
+}
+
+func NewHolidayHandler(holidayStore store.HolidayStore, logger *log.Logger) *HolidayHandler {
+	return &HolidayHandler{
+		holidayStore: holidayStore,
+		logger:       logger,
+	}
+}
+
+func (hh *HolidayHandler) HandleListCalendars(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	calendars, err := hh.holidayStore.ListCalendars(r.Context())
+	if err != nil {
+		hh.logger.Println("ListCalendars error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"holiday_calendars": calendars})
+}
+
+func (hh *HolidayHandler) HandleCreateCalendar(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	c := &store.HolidayCalendar{Name: strings.TrimSpace(req.Name)}
+	if c.Name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+
+	if err := hh.holidayStore.CreateCalendar(r.Context(), c); err != nil {
+		if strings.Contains(err.Error(), "holiday_calendars_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "holiday calendar already exists"})
+			return
+		}
+		hh.logger.Println("CreateCalendar error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"holiday_calendar": c})
+}
+
+// HandleDeleteCalendar removes a calendar with its holidays.
+// Users and offices that used it are left without a calendar.
+func (hh *HolidayHandler) HandleDeleteCalendar(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := hh.holidayStore.DeleteCalendar(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday calendar not found"})
+			return
+		}
+		hh.logger.Println("DeleteCalendar error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "holiday calendar deleted"})
+}
+
+// readYearRange turns ?year= (default: current year) into a date range.
+func readYearRange(r *http.Request) (string, string, error) {
+	year := utils.ReadInt(r, "year", time.Now().Year())
+	if year < 2000 || year > 9999 {
+		return "", "", errors.New("invalid year")
+	}
+	return fmt.Sprintf("%d-01-01", year), fmt.Sprintf("%d-12-31", year), nil
+}
+
+func (hh *HolidayHandler) HandleListHolidays(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	from, to, err := readYearRange(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	holidays, err := hh.holidayStore.ListHolidays(r.Context(), id, from, to)
+	if err != nil {
+		hh.logger.Println("ListHolidays error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"holidays": holidays})
+}
+
+// HandleListUserHolidays returns the holidays that apply to a user (default: the caller)
+// through their own calendar or their office's.
+func (hh *HolidayHandler) HandleListUserHolidays(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	from, to, err := readYearRange(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	userID := u.Id
+	if s := strings.TrimSpace(r.URL.Query().Get("user_id")); s != "" && u.Role == "admin" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return
+		}
+		userID = v
+	}
+
+	holidays, err := hh.holidayStore.ListUserHolidays(r.Context(), userID, from, to)
+	if err != nil {
+		hh.logger.Println("ListUserHolidays error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"user_id": userID, "holidays": holidays})
+}
+
+func (hh *HolidayHandler) HandleCreateHoliday(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	calendarID, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	h := &store.Holiday{
+		CalendarId: calendarID,
+		Date:       strings.TrimSpace(req.Date),
+		Name:       strings.TrimSpace(req.Name),
+	}
+
+	if _, err := time.Parse(time.DateOnly, h.Date); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	if h.Name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+
+	if err := hh.holidayStore.CreateHoliday(r.Context(), h); err != nil {
+		if strings.Contains(err.Error(), "one_holiday_per_calendar_day") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "calendar already has a holiday on that day"})
+			return
+		}
+		if strings.Contains(err.Error(), "holidays_calendar_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday calendar not found"})
+			return
+		}
+		hh.logger.Println("CreateHoliday error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"holiday": h})
+}
+
+func (hh *HolidayHandler) HandleDeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := hh.holidayStore.DeleteHoliday(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday not found"})
+			return
+		}
+		hh.logger.Println("DeleteHoliday error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "holiday deleted"})
+}
+
+// HandleImportHolidays adds the events of an uploaded .ics file (e.g. a public
+// holidays feed) to a calendar. All-day events cover each of their days;
+// timed events count for the day they start on, in ?tz=.
+func (hh *HolidayHandler) HandleImportHolidays(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	calendarID, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalendarUploadSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	events, err := ical.Parse(body, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid calendar: " + err.Error()})
+		return
+	}
+
+	var holidays []store.Holiday
+	skipped := 0
+
+	for _, ev := range events {
+		name := strings.TrimSpace(ev.Summary)
+		if name == "" {
+			name = "Holiday"
+		}
+
+		if !ev.AllDay {
+			holidays = append(holidays, store.Holiday{Date: ev.Start.In(loc).Format(time.DateOnly), Name: name})
+			continue
+		}
+
+		// all-day events end on the (exclusive) next day
+		var days []store.Holiday
+		for d := ev.Start; d.Before(ev.End) || d.Equal(ev.Start); d = d.AddDate(0, 0, 1) {
+			days = append(days, store.Holiday{Date: d.Format(time.DateOnly), Name: name})
+			if len(days) > maxHolidayEventDays {
+				break
+			}
+		}
+		if len(days) > maxHolidayEventDays {
+			skipped++
+			continue
+		}
+		holidays = append(holidays, days...)
+	}
+
+	created, err := hh.holidayStore.ImportHolidays(r.Context(), calendarID, holidays)
+	if err != nil {
+		if strings.Contains(err.Error(), "holidays_calendar_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday calendar not found"})
+			return
+		}
+		hh.logger.Println("ImportHolidays error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{
+		"events":  len(events),
+		"created": created,
+		"updated": len(holidays) - created,
+		"skipped": skipped,
+	})
+}
+
+func (hh *HolidayHandler) HandleListOffices(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	offices, err := hh.holidayStore.ListOffices(r.Context())
+	if err != nil {
+		hh.logger.Println("ListOffices error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"offices": offices})
+}
+
+func (hh *HolidayHandler) HandleCreateOffice(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Name              string `json:"name"`
+		HolidayCalendarID *int64 `json:"holiday_calendar_id"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	o := &store.Office{
+		Name:              strings.TrimSpace(req.Name),
+		HolidayCalendarId: req.HolidayCalendarID,
+	}
+	if o.Name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+
+	if err := hh.holidayStore.CreateOffice(r.Context(), o); err != nil {
+		hh.writeOfficeError(w, "CreateOffice", err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"office": o})
+}
+
+// HandleUpdateOffice renames an office or changes its holiday calendar.
+// "holiday_calendar_id": null removes the calendar.
+func (hh *HolidayHandler) HandleUpdateOffice(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		Name              *string         `json:"name"`
+		HolidayCalendarID json.RawMessage `json:"holiday_calendar_id"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	offices, err := hh.holidayStore.ListOffices(r.Context())
+	if err != nil {
+		hh.logger.Println("ListOffices error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	var o *store.Office
+	for i := range offices {
+		if offices[i].Id == id {
+			o = &offices[i]
+		}
+	}
+	if o == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "office not found"})
+		return
+	}
+
+	if req.Name != nil {
+		o.Name = strings.TrimSpace(*req.Name)
+		if o.Name == "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+			return
+		}
+	}
+
+	// absent: keep, null: clear, number: set
+	if len(req.HolidayCalendarID) > 0 {
+		var calendarID *int64
+		if err := json.Unmarshal(req.HolidayCalendarID, &calendarID); err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "holiday_calendar_id must be a number or null"})
+			return
+		}
+		o.HolidayCalendarId = calendarID
+	}
+
+	if err := hh.holidayStore.UpdateOffice(r.Context(), o); err != nil {
+		hh.writeOfficeError(w, "UpdateOffice", err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"office": o})
+}
+
+func (hh *HolidayHandler) writeOfficeError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "offices_name_key"):
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "office already exists"})
+	case strings.Contains(err.Error(), "offices_holiday_calendar_id_fkey"):
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday calendar not found"})
+	default:
+		hh.logger.Println(op+" error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	}
+}
+
+// HandleAssignUser sets a user's office and personal holiday calendar.
+// A personal calendar wins over the office's; null clears either.
+func (hh *HolidayHandler) HandleAssignUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		OfficeID          *int64 `json:"office_id"`
+		HolidayCalendarID *int64 `json:"holiday_calendar_id"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	err = hh.holidayStore.AssignUser(r.Context(), userID, req.OfficeID, req.HolidayCalendarID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		case strings.Contains(err.Error(), "users_office_id_fkey"):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "office not found"})
+		case strings.Contains(err.Error(), "users_holiday_calendar_id_fkey"):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "holiday calendar not found"})
+		default:
+			hh.logger.Println("AssignUser error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		}
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"user_id":             userID,
+		"office_id":           req.OfficeID,
+		"holiday_calendar_id": req.HolidayCalendarID,
+	})
+}
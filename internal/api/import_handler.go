@@ -0,0 +1,100 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/htojiddinov77-png/worktime/internal/importer"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const maxImportSize = 20 << 20 // 20 MB
+
+type ImportHandler struct {
+	importStore store.ImportStore
+	logger      *log.Logger
+}
+
+func NewImportHandler(importStore store.ImportStore, logger *log.Logger) *ImportHandler {
+	return &ImportHandler{
+		importStore: importStore,
+		logger:      logger,
+	}
+}
+
+// HandleImportSessions imports sessions from a CSV export (admin-only).
+// The CSV is either the raw request body or the "file" field of a multipart form.
+// dry_run defaults to true: nothing is written unless dry_run=false is sent.
+func (ih *ImportHandler) HandleImportSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	preset, ok := importer.GetPreset(utils.ReadString(r, "preset", "generic"))
+	if !ok {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{
+			"error":   "unknown preset",
+			"presets": importer.PresetNames(),
+		})
+		return
+	}
+
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	dryRun, err := utils.ReadBool(r, "dry_run")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "dry_run must be true or false"})
+		return
+	}
+
+	createProjects, err := utils.ReadBool(r, "create_projects")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "create_projects must be true or false"})
+		return
+	}
+
+	opts := store.ImportOptions{
+		DryRun:         dryRun == nil || *dryRun,
+		CreateProjects: createProjects != nil && *createProjects,
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	rows, rowErrors, err := importer.ParseCSV(body, preset, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	report, err := importer.Import(r.Context(), ih.importStore, rows, rowErrors, opts)
+	if err != nil {
+		ih.logger.Println("ImportSessions error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	status := http.StatusOK
+	if !opts.DryRun && len(report.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	utils.WriteJson(w, status, utils.Envelope{"report": report})
+}
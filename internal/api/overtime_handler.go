@@ -0,0 +1,374 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// longest period one overtime report may cover
+const maxOvertimePeriodDays = 366
+
+// largest adjustment, either way; the balance is stored in an INTEGER of seconds
+const maxAdjustmentHours = 10000
+
+type OvertimeHandler struct {
+	overtimeStore store.OvertimeStore
+	logger        *log.Logger
+}
+
+func NewOvertimeHandler(overtimeStore store.OvertimeStore, logger *log.Logger) *OvertimeHandler {
+	return &OvertimeHandler{
+		overtimeStore: overtimeStore,
+		logger:        logger,
+	}
+}
+
+// readUserIDParam reads the {user_id} URL param of the admin schedule/adjustment routes.
+func readUserIDParam(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "user_id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid user id")
+	}
+	return id, nil
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return false
+	}
+	if u.Role != "admin" {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "forbidden"})
+		return false
+	}
+	return true
+}
+
+func (oh *OvertimeHandler) HandleListSchedules(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	schedules, err := oh.overtimeStore.ListSchedules(r.Context(), userID)
+	if err != nil {
+		oh.logger.Println("ListSchedules error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"schedules": schedules})
+}
+
+// HandleCreateSchedule adds a schedule that applies from effective_from until the next one.
+// Hours are given per weekday, Monday first.
+func (oh *OvertimeHandler) HandleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		EffectiveFrom string    `json:"effective_from"`
+		WeekdayHours  []float64 `json:"weekday_hours"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if _, err := time.Parse(time.DateOnly, strings.TrimSpace(req.EffectiveFrom)); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "effective_from must be YYYY-MM-DD"})
+		return
+	}
+
+	if len(req.WeekdayHours) != 7 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "weekday_hours must have 7 values, Monday first"})
+		return
+	}
+
+	schedule := &store.WorkSchedule{
+		UserId:        userID,
+		EffectiveFrom: strings.TrimSpace(req.EffectiveFrom),
+	}
+	for i, h := range req.WeekdayHours {
+		if h < 0 || h > 24 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "weekday_hours must be between 0 and 24"})
+			return
+		}
+		schedule.WeekdaySeconds[i] = int64(h * 3600)
+	}
+
+	if err := oh.overtimeStore.CreateSchedule(r.Context(), schedule); err != nil {
+		if strings.Contains(err.Error(), "one_schedule_per_user_and_day") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "user already has a schedule starting on that day"})
+			return
+		}
+		if strings.Contains(err.Error(), "work_schedules_user_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+			return
+		}
+		oh.logger.Println("CreateSchedule error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"schedule": schedule})
+}
+
+func (oh *OvertimeHandler) HandleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := oh.overtimeStore.DeleteSchedule(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "schedule not found"})
+			return
+		}
+		oh.logger.Println("DeleteSchedule error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "schedule deleted"})
+}
+
+func (oh *OvertimeHandler) HandleListAdjustments(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	adjustments, err := oh.overtimeStore.ListAdjustments(r.Context(), userID)
+	if err != nil {
+		oh.logger.Println("ListAdjustments error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"adjustments": adjustments})
+}
+
+// HandleCreateAdjustment records a manual correction of a user's overtime balance.
+// Positive hours add to the balance (e.g. approved extra work), negative hours take
+// from it (e.g. overtime paid out or taken as time off).
+func (oh *OvertimeHandler) HandleCreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	u, _ := middleware.GetUser(r)
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		EffectiveDate string  `json:"effective_date"`
+		Hours         float64 `json:"hours"`
+		Reason        string  `json:"reason"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	req.EffectiveDate = strings.TrimSpace(req.EffectiveDate)
+	if _, err := time.Parse(time.DateOnly, req.EffectiveDate); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "effective_date must be YYYY-MM-DD"})
+		return
+	}
+
+	if math.Abs(req.Hours) > maxAdjustmentHours {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "hours must be between -10000 and 10000"})
+		return
+	}
+
+	seconds := int64(req.Hours * 3600)
+	if seconds == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "hours can't be zero"})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "reason is required"})
+		return
+	}
+
+	createdBy := u.Id
+	adj := &store.OvertimeAdjustment{
+		UserId:        userID,
+		EffectiveDate: req.EffectiveDate,
+		Seconds:       seconds,
+		Reason:        req.Reason,
+		CreatedBy:     &createdBy,
+	}
+
+	if err := oh.overtimeStore.CreateAdjustment(r.Context(), adj); err != nil {
+		if strings.Contains(err.Error(), "overtime_adjustments_user_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+			return
+		}
+		oh.logger.Println("CreateAdjustment error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"adjustment": adj})
+}
+
+func (oh *OvertimeHandler) HandleDeleteAdjustment(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := oh.overtimeStore.DeleteAdjustment(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "adjustment not found"})
+			return
+		}
+		oh.logger.Println("DeleteAdjustment error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "adjustment deleted"})
+}
+
+// HandleGetOvertimeReport serves GET /overtime/.
+// Users get their own report. Admins get one user's report with user_id,
+// otherwise a report for every active user that has a schedule.
+func (oh *OvertimeHandler) HandleGetOvertimeReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	fromStr := strings.TrimSpace(q.Get("from"))
+	toStr := strings.TrimSpace(q.Get("to"))
+
+	if fromStr == "" || toStr == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from and to are required"})
+		return
+	}
+
+	from, err := time.ParseInLocation(time.DateOnly, fromStr, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+		return
+	}
+
+	to, err := time.ParseInLocation(time.DateOnly, toStr, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+		return
+	}
+
+	if to.Before(from) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxOvertimePeriodDays-1)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period can't be longer than 366 days"})
+		return
+	}
+
+	var userIDs []int64
+	if s := strings.TrimSpace(q.Get("user_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return
+		}
+		userIDs = []int64{v}
+	}
+
+	if u.Role != "admin" {
+		// normal user: always their own report
+		userIDs = []int64{u.Id}
+	} else if userIDs == nil {
+		userIDs, err = oh.overtimeStore.ListScheduledUserIDs(r.Context())
+		if err != nil {
+			oh.logger.Println("ListScheduledUserIDs error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	reports := []store.OvertimeReport{}
+	for _, id := range userIDs {
+		report, err := oh.overtimeStore.GetOvertimeReport(r.Context(), id, from, to)
+		if err != nil {
+			oh.logger.Println("GetOvertimeReport error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if report == nil {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+			return
+		}
+		reports = append(reports, *report)
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"overtime": reports})
+}
@@ -1,35 +1,138 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/htojiddinov77-png/worktime/internal/auth"
 	"github.com/htojiddinov77-png/worktime/internal/middleware"
 	"github.com/htojiddinov77-png/worktime/internal/store"
 	"github.com/htojiddinov77-png/worktime/internal/utils"
 )
 
+const (
+	defaultBudgetAlertLimit = 50
+	maxBudgetAlertLimit     = 500
+)
+
 type ProjectHandler struct {
-	projectStore store.ProjectStore
-	userStore    store.UserStore
-	logger       *log.Logger
+	projectStore     store.ProjectStore
+	memberStore      store.ProjectMemberStore
+	userStore        store.UserStore
+	customFieldStore store.CustomFieldStore
+	logger           *log.Logger
+	Hub              *Hub
 }
 
-func NewProjectHandler(projectStore store.ProjectStore, userStore store.UserStore, logger *log.Logger) *ProjectHandler {
+func NewProjectHandler(projectStore store.ProjectStore, memberStore store.ProjectMemberStore, userStore store.UserStore, customFieldStore store.CustomFieldStore, logger *log.Logger, hub *Hub) *ProjectHandler {
 	return &ProjectHandler{
-		projectStore: projectStore,
-		userStore:    userStore,
-		logger:       logger,
+		projectStore:     projectStore,
+		memberStore:      memberStore,
+		userStore:        userStore,
+		customFieldStore: customFieldStore,
+		logger:           logger,
+		Hub:              hub,
+	}
+}
+
+// readBudget parses a budget object from a request. null means no budget.
+func readBudget(raw json.RawMessage) (*store.ProjectBudget, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+
+	var b store.ProjectBudget
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		return nil, errors.New("invalid budget")
+	}
+
+	if b.Period == "" {
+		b.Period = store.BudgetPeriodTotal
+	}
+	switch {
+	case b.Period != store.BudgetPeriodTotal && b.Period != store.BudgetPeriodMonthly:
+		return nil, errors.New("budget period must be total or monthly")
+	case b.Hours == nil && b.Amount == nil:
+		return nil, errors.New("budget needs hours or amount")
+	case b.Hours != nil && *b.Hours <= 0, b.Amount != nil && *b.Amount <= 0:
+		return nil, errors.New("budget hours and amount must be positive")
+	case b.HourlyRate != nil && *b.HourlyRate < 0:
+		return nil, errors.New("hourly_rate can't be negative")
+	case b.Amount != nil && b.HourlyRate == nil:
+		return nil, errors.New("a money budget needs an hourly_rate")
+	}
+
+	return &b, nil
+}
+
+// readEstimate parses an estimate object from a request. null means no estimate.
+func readEstimate(raw json.RawMessage) (*store.ProjectEstimate, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+
+	var e store.ProjectEstimate
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		return nil, errors.New("invalid estimate")
+	}
+
+	switch {
+	case e.Hours == nil && e.TargetEndDate == nil:
+		return nil, errors.New("estimate needs hours or target_end_date")
+	case e.Hours != nil && *e.Hours <= 0:
+		return nil, errors.New("estimate hours must be positive")
 	}
+	if e.TargetEndDate != nil {
+		d, err := time.Parse(time.DateOnly, strings.TrimSpace(*e.TargetEndDate))
+		if err != nil {
+			return nil, errors.New("target_end_date must be YYYY-MM-DD")
+		}
+		target := d.Format(time.DateOnly)
+		e.TargetEndDate = &target
+	}
+
+	return &e, nil
+}
+
+// readNullableID parses an optional reference such as client_id; null (or absent) means none.
+func readNullableID(raw json.RawMessage, field string) (*int64, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+
+	var id int64
+	if err := json.Unmarshal(raw, &id); err != nil || id <= 0 {
+		return nil, fmt.Errorf("%s must be a positive integer or null", field)
+	}
+	return &id, nil
+}
+
+func isClientNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "projects_client_id_fkey")
 }
 
 func (ph *ProjectHandler) HandleCreateProject(w http.ResponseWriter, r *http.Request) {
 	type projectRequest struct {
-		Name     string `json:"name"`
-		StatusId int64  `json:"status_id"`
+		Name         string                     `json:"name"`
+		StatusId     int64                      `json:"status_id"`
+		ClientId     json.RawMessage            `json:"client_id"`
+		Billable     *bool                      `json:"billable"`
+		Budget       json.RawMessage            `json:"budget"`
+		Estimate     json.RawMessage            `json:"estimate"`
+		CustomFields map[string]json.RawMessage `json:"custom_fields"`
 	}
 
 	var req projectRequest
@@ -54,6 +157,24 @@ func (ph *ProjectHandler) HandleCreateProject(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	budget, err := readBudget(req.Budget)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	estimate, err := readEstimate(req.Estimate)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	clientID, err := readNullableID(req.ClientId, "client_id")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
 	u, ok := middleware.GetUser(r)
 	if !ok || u.Id <= 0 {
 		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
@@ -76,12 +197,35 @@ func (ph *ProjectHandler) HandleCreateProject(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	custom, msg, err := readCustomValues(r.Context(), ph.customFieldStore, store.CustomFieldEntityProject, nil, req.CustomFields, true)
+	if err != nil {
+		ph.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
 	pj := &store.Project{
-		ProjectName: req.Name,
-		StatusId:    req.StatusId,
+		ProjectName:  req.Name,
+		StatusId:     req.StatusId,
+		ClientId:     clientID,
+		Billable:     true,
+		Budget:       budget,
+		Estimate:     estimate,
+		CustomFields: custom,
+	}
+	if req.Billable != nil {
+		pj.Billable = *req.Billable
 	}
 
 	if err := ph.projectStore.CreateProject(r.Context(), pj); err != nil {
+		if isClientNotFound(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "client not found"})
+			return
+		}
 		ph.logger.Println("error while creating project:", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
@@ -93,37 +237,108 @@ func (ph *ProjectHandler) HandleCreateProject(w http.ResponseWriter, r *http.Req
 	})
 }
 
+// activeSessionsByProject groups the running sessions by project.
+func (ph *ProjectHandler) activeSessionsByProject(ctx context.Context) (map[int64][]store.ActiveSessionRow, error) {
+	active, err := ph.projectStore.ListActiveSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	activeByProject := map[int64][]store.ActiveSessionRow{}
+	for _, a := range active {
+		a.ActiveMinutes = a.ActiveSeconds / 60
+		activeByProject[a.ProjectId] = append(activeByProject[a.ProjectId], a)
+	}
+	return activeByProject, nil
+}
+
+// HandleListProjects lists all projects for admins, and the projects they are assigned to for everyone else.
 func (ph *ProjectHandler) HandleListProjects(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	projects, err := ph.projectStore.ListProjects(ctx)
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+	isAdmin := u.Role == "admin"
+
+	includeArchived, err := utils.ReadBool(r, "include_archived")
 	if err != nil {
-		ph.logger.Println("ListProjects error:", err)
-		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "include_archived must be true or false"})
 		return
 	}
 
-	u, ok := middleware.GetUser(r)
+	hasActive, err := utils.ReadBool(r, "has_active_sessions")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "has_active_sessions must be true or false"})
+		return
+	}
 
-	isAdmin := false
-	if ok && u != nil && u.Role == "admin" {
-		isAdmin = true
+	filter := store.ProjectListFilter{
+		IncludeArchived:   includeArchived != nil && *includeArchived,
+		Status:            utils.ReadString(r, "status", ""),
+		Search:            utils.ReadString(r, "search", ""),
+		HasActiveSessions: hasActive,
+	}
+	if !isAdmin {
+		filter.MemberID = u.Id
+	}
+
+	filter.Page = utils.ReadInt(r, "page", 1)
+	filter.PageSize = utils.ReadInt(r, "page_size", 50)
+	// page_size=all keeps the one-list behaviour clients had before paging
+	if utils.ReadString(r, "page_size", "") == "all" {
+		filter.All = true
+		filter.Page, filter.PageSize = 1, 50
+	}
+	filter.Sort = utils.ReadString(r, "sort", "name")
+	filter.SortSafeList = store.ProjectSortSafeList
+
+	if err := filter.Validate(); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	// Optional from/to: the range the totals cover
+	q := r.URL.Query()
+	if s := strings.TrimSpace(q.Get("from")); s != "" {
+		from, err := parseTimeParam(s)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+			return
+		}
+		filter.From = &from
+	}
+	if s := strings.TrimSpace(q.Get("to")); s != "" {
+		to, err := parseTimeParam(s)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+			return
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+
+	projects, total, err := ph.projectStore.ListProjects(ctx, filter)
+	if err != nil {
+		ph.logger.Println("ListProjects error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
 	}
 
 	activeByProject := map[int64][]store.ActiveSessionRow{}
 
 	if isAdmin {
-		active, err := ph.projectStore.ListActiveSessions(ctx)
+		activeByProject, err = ph.activeSessionsByProject(ctx)
 		if err != nil {
 			ph.logger.Println("ListActiveSessions error:", err)
 			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 			return
 		}
-
-		for _, a := range active {
-			a.ActiveMinutes = a.ActiveSeconds / 60
-			activeByProject[a.ProjectId] = append(activeByProject[a.ProjectId], a)
-		}
 	}
 
 	for i := range projects {
@@ -139,7 +354,16 @@ func (ph *ProjectHandler) HandleListProjects(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	utils.WriteJson(w, http.StatusOK, utils.Envelope{"count": len(projects), "projects": projects})
+	meta := store.CalculateMetadata(total, filter.Page, filter.PageSize)
+	if filter.All {
+		meta = store.CalculateMetadata(total, 1, 0)
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"count":    len(projects),
+		"projects": projects,
+		"metadata": meta,
+	})
 }
 
 // func (ph *ProjectHandler) HandleListProjects(w http.ResponseWriter, r *http.Request) {
@@ -186,8 +410,15 @@ func (ph *ProjectHandler) HandleUpdateProject(w http.ResponseWriter, r *http.Req
 	}
 
 	var req struct {
-		Name     *string `json:"name"`
-		StatusId *int64  `json:"status_id"`
+		Name         *string                    `json:"name"`
+		StatusId     *int64                     `json:"status_id"`
+		ClientId     json.RawMessage            `json:"client_id"`
+		Billable     *bool                      `json:"billable"`
+		Budget       json.RawMessage            `json:"budget"`
+		Estimate     json.RawMessage            `json:"estimate"`
+		CustomFields map[string]json.RawMessage `json:"custom_fields"`
+
+		StopActiveSessions bool `json:"stop_active_sessions"`
 	}
 
 	dec := json.NewDecoder(r.Body)
@@ -198,8 +429,8 @@ func (ph *ProjectHandler) HandleUpdateProject(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if req.Name == nil && req.StatusId == nil {
-		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "at least one field is required: name or status_id"})
+	if req.Name == nil && req.StatusId == nil && req.Billable == nil && req.Budget == nil && req.ClientId == nil && req.Estimate == nil && req.CustomFields == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "at least one field is required: name, status_id, client_id, billable, budget, estimate or custom_fields"})
 		return
 	}
 
@@ -217,20 +448,597 @@ func (ph *ProjectHandler) HandleUpdateProject(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if req.StopActiveSessions && req.StatusId == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "stop_active_sessions requires status_id"})
+		return
+	}
+
+	upd := store.ProjectUpdate{
+		Name:         req.Name,
+		StatusID:     req.StatusId,
+		Billable:     req.Billable,
+		SetBudget:    req.Budget != nil,
+		SetClient:    req.ClientId != nil,
+		SetEstimate:  req.Estimate != nil,
+		StopSessions: req.StopActiveSessions,
+		ChangedBy:    user.Id,
+	}
+
+	budget, err := readBudget(req.Budget)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	upd.Budget = budget
+
+	upd.Estimate, err = readEstimate(req.Estimate)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	upd.ClientID, err = readNullableID(req.ClientId, "client_id")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
 	projectId, err := utils.ReadIdParam(r)
 	if err != nil {
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
 		return
 	}
 
-	err = ph.projectStore.UpdateProject(r.Context(), projectId, req.Name, req.StatusId)
+	// custom values are merged into the project's current ones by the update itself
+	if req.CustomFields != nil {
+		custom, msg, err := readCustomValues(r.Context(), ph.customFieldStore, store.CustomFieldEntityProject, nil, req.CustomFields, false)
+		if err != nil {
+			ph.logger.Println("ListCustomFields error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if msg != "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+			return
+		}
+		upd.CustomFields = custom
+
+		// the values sent as null are the ones left out
+		for key := range req.CustomFields {
+			if _, ok := custom[key]; !ok {
+				upd.RemovedCustomFields = append(upd.RemovedCustomFields, key)
+			}
+		}
+	}
+
+	stopped, err := ph.projectStore.UpdateProject(r.Context(), projectId, upd)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+		if isClientNotFound(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "client not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "projects_status_id_fkey") {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "projects_status_transition") {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status transition not allowed"})
+			return
+		}
 		ph.logger.Println("error updating project:", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
-	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "project updated successfully"})
+	// let the owners know their timers were stopped for them
+	if ph.Hub != nil {
+		for _, st := range stopped {
+			ph.Hub.Publish(Event{
+				Type:      "session_stopped",
+				UserID:    st.UserId,
+				ProjectID: projectId,
+				Data: map[string]any{
+					"session_id": st.SessionId,
+					"user_id":    st.UserId,
+					"stopped_by": user.Id,
+					"project_id": projectId,
+					"end_at":     st.EndAt,
+					"reason":     "project_status",
+				},
+			})
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"message":          "project updated successfully",
+		"stopped_sessions": stopped,
+	})
+}
+
+// HandleGetProject returns one project with its totals; admins also get its running sessions.
+func (ph *ProjectHandler) HandleGetProject(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	// other people's projects don't exist as far as a user is concerned
+	if u.Role != "admin" {
+		member, err := ph.memberStore.IsProjectMember(r.Context(), projectId, u.Id)
+		if err != nil {
+			ph.logger.Println("IsProjectMember error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if !member {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+	}
+
+	project, err := ph.projectStore.GetProject(r.Context(), projectId)
+	if err != nil {
+		ph.logger.Println("GetProject error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if project == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		return
+	}
+
+	project.TotalDurations = formatDuration(project.TotalSeconds)
+	project.ActiveSessions = []store.ActiveSessionRow{}
+
+	if u.Role == "admin" {
+		activeByProject, err := ph.activeSessionsByProject(r.Context())
+		if err != nil {
+			ph.logger.Println("ListActiveSessions error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if active := activeByProject[project.Id]; active != nil {
+			project.ActiveSessions = active
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"project": project})
+}
+
+// HandleGetProjectForecast compares a project's logged time with its estimate and forecasts
+// when it will be done. Like the project itself, it's for admins and the project's members.
+func (ph *ProjectHandler) HandleGetProjectForecast(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if u.Role != "admin" {
+		member, err := ph.memberStore.IsProjectMember(r.Context(), projectId, u.Id)
+		if err != nil {
+			ph.logger.Println("IsProjectMember error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if !member {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+	}
+
+	forecast, err := ph.projectStore.GetProjectForecast(r.Context(), projectId)
+	if err != nil {
+		ph.logger.Println("GetProjectForecast error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if forecast == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"forecast": forecast})
+}
+
+// HandleListBudgetAlerts lists the recorded budget alerts, newest first (admin-only), so admins
+// who weren't connected when an alert went out still see it.
+func (ph *ProjectHandler) HandleListBudgetAlerts(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var projectID *int64
+	if s := strings.TrimSpace(r.URL.Query().Get("project_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid project_id"})
+			return
+		}
+		projectID = &v
+	}
+
+	limit := utils.ReadInt(r, "limit", defaultBudgetAlertLimit)
+	if limit < 1 || limit > maxBudgetAlertLimit {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	alerts, err := ph.projectStore.ListBudgetAlerts(r.Context(), projectID, limit)
+	if err != nil {
+		ph.logger.Println("ListBudgetAlerts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"budget_alerts": alerts})
+}
+
+// HandleArchiveProject archives a project: its history stays, but no new sessions can be logged on it.
+func (ph *ProjectHandler) HandleArchiveProject(w http.ResponseWriter, r *http.Request) {
+	ph.setArchived(w, r, true)
+}
+
+func (ph *ProjectHandler) HandleUnarchiveProject(w http.ResponseWriter, r *http.Request) {
+	ph.setArchived(w, r, false)
+}
+
+func (ph *ProjectHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	u, _ := middleware.GetUser(r)
+	if err := ph.projectStore.SetProjectArchived(r.Context(), projectId, archived, u.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+		ph.logger.Println("SetProjectArchived error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	project, err := ph.projectStore.GetProject(r.Context(), projectId)
+	if err != nil || project == nil {
+		ph.logger.Println("GetProject error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	project.TotalDurations = formatDuration(project.TotalSeconds)
+	project.ActiveSessions = []store.ActiveSessionRow{}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"project": project})
+}
+
+// HandleDeleteProject deletes a project. A project with sessions is only deleted with
+// ?reassign_to={project_id}, which moves its sessions to another project first.
+func (ph *ProjectHandler) HandleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var reassignTo *int64
+	if s := strings.TrimSpace(r.URL.Query().Get("reassign_to")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid reassign_to"})
+			return
+		}
+		if v == projectId {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "reassign_to must be another project"})
+			return
+		}
+
+		target, err := ph.projectStore.GetProject(r.Context(), v)
+		if err != nil {
+			ph.logger.Println("GetProject error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if target == nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "reassign_to project not found"})
+			return
+		}
+		if target.ArchivedAt != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "reassign_to project is archived"})
+			return
+		}
+		reassignTo = &v
+	}
+
+	moved, err := ph.projectStore.DeleteProject(r.Context(), projectId, reassignTo)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		case errors.Is(err, store.ErrProjectHasSessions):
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{
+				"error": "project has work sessions; archive it, or delete it with reassign_to to move them to another project",
+			})
+		case isProjectArchived(err):
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "reassign_to project is archived"})
+		case isProjectStatusClosed(err):
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{
+				"error": "reassign_to project status doesn't accept time; stop the running sessions first",
+			})
+		default:
+			ph.logger.Println("DeleteProject error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		}
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"message":             "project deleted successfully",
+		"reassigned_sessions": moved,
+	})
+}
+
+// HandleListProjectHistory lists a project's changes, newest first, for admins and its managers.
+func (ph *ProjectHandler) HandleListProjectHistory(w http.ResponseWriter, r *http.Request) {
+	_, projectId, ok := ph.requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := ph.projectStore.ListProjectHistory(r.Context(), projectId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+		ph.logger.Println("ListProjectHistory error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"history": history})
+}
+
+// requireManager reads the project id and checks that the user is an admin or manages the project.
+func (ph *ProjectHandler) requireManager(w http.ResponseWriter, r *http.Request) (*auth.UserClaims, int64, bool) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return nil, 0, false
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return nil, 0, false
+	}
+
+	allowed, err := canManageProject(r.Context(), ph.memberStore, u, projectId)
+	if err != nil {
+		ph.logger.Println("IsProjectManager error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, 0, false
+	}
+	if !allowed {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "forbidden"})
+		return nil, 0, false
+	}
+	return u, projectId, true
+}
+
+func (ph *ProjectHandler) HandleListProjectMembers(w http.ResponseWriter, r *http.Request) {
+	_, projectId, ok := ph.requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := ph.memberStore.ListProjectMembers(r.Context(), projectId)
+	if err != nil {
+		ph.logger.Println("ListProjectMembers error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"members": members})
+}
+
+// publishMembership tells the affected user that they were added to or removed from a project.
+func (ph *ProjectHandler) publishMembership(eventType string, projectID, userID, by int64) {
+	ph.Hub.Publish(Event{
+		Type:      eventType,
+		UserID:    userID,
+		ProjectID: projectID,
+		Data: map[string]any{
+			"project_id": projectID,
+			"user_id":    userID,
+			"by":         by,
+		},
+	})
+}
+
+// HandleAddProjectMember assigns a user to a project. Adding an existing member is not an error.
+// Admins and the project's managers can add members; only admins can make them managers.
+func (ph *ProjectHandler) HandleAddProjectMember(w http.ResponseWriter, r *http.Request) {
+	u, projectId, ok := ph.requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserId    int64 `json:"user_id"`
+		IsManager bool  `json:"is_manager"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+	if req.UserId <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "user_id must be positive"})
+		return
+	}
+	if req.IsManager && u.Role != "admin" {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "only admins can appoint project managers"})
+		return
+	}
+
+	member := &store.ProjectMember{ProjectId: projectId, UserId: req.UserId, IsManager: req.IsManager, AddedBy: &u.Id}
+	added, err := ph.memberStore.AddProjectMember(r.Context(), member)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "project_members_project_id_fkey"):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		case strings.Contains(err.Error(), "project_members_user_id_fkey"):
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "user not found"})
+		default:
+			ph.logger.Println("AddProjectMember error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+		ph.publishMembership("project_member_added", projectId, member.UserId, u.Id)
+	}
+
+	utils.WriteJson(w, status, utils.Envelope{"member": member})
+}
+
+// HandleRemoveProjectMember unassigns a user. Their sessions on the project stay, and a running
+// one can still be stopped, but they can't log new time on it.
+// Managers can remove members of their projects, but not other managers.
+func (ph *ProjectHandler) HandleRemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+	u, projectId, ok := ph.requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	userId, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if u.Role != "admin" {
+		isManager, err := ph.memberStore.IsProjectManager(r.Context(), projectId, userId)
+		if err != nil {
+			ph.logger.Println("IsProjectManager error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if isManager {
+			utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "only admins can remove a project manager"})
+			return
+		}
+	}
+
+	if err := ph.memberStore.RemoveProjectMember(r.Context(), projectId, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user is not a member of this project"})
+			return
+		}
+		ph.logger.Println("RemoveProjectMember error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ph.publishMembership("project_member_removed", projectId, userId, u.Id)
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "member removed"})
+}
+
+// HandleSetProjectManager makes a member a manager of the project, or takes it back (admin-only).
+func (ph *ProjectHandler) HandleSetProjectManager(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	u, _ := middleware.GetUser(r)
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+	userId, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		IsManager *bool `json:"is_manager"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+	if req.IsManager == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "is_manager is required"})
+		return
+	}
+
+	if err := ph.memberStore.SetProjectManager(r.Context(), projectId, userId, *req.IsManager); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user is not a member of this project"})
+			return
+		}
+		ph.logger.Println("SetProjectManager error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ph.Hub.Publish(Event{
+		Type:      "project_manager_changed",
+		UserID:    userId,
+		ProjectID: projectId,
+		Data: map[string]any{
+			"project_id": projectId,
+			"user_id":    userId,
+			"is_manager": *req.IsManager,
+			"by":         u.Id,
+		},
+	})
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"project_id": projectId,
+		"user_id":    userId,
+		"is_manager": *req.IsManager,
+	})
 }
 
 func formatDuration(totalSeconds int64) string {
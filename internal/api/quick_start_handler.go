@@ -0,0 +1,122 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const (
+	defaultQuickStartLimit = 5
+	maxQuickStartLimit     = 20
+)
+
+type QuickStartHandler struct {
+	quickStartStore store.QuickStartStore
+	memberStore     store.ProjectMemberStore
+	logger          *log.Logger
+}
+
+func NewQuickStartHandler(quickStartStore store.QuickStartStore, memberStore store.ProjectMemberStore, logger *log.Logger) *QuickStartHandler {
+	return &QuickStartHandler{
+		quickStartStore: quickStartStore,
+		memberStore:     memberStore,
+		logger:          logger,
+	}
+}
+
+// HandleGetQuickStart lists the caller's favorite, recent and frequent projects with the
+// last note, task and tags they used on each, for restarting a timer in one click.
+func (qh *QuickStartHandler) HandleGetQuickStart(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	limit := utils.ReadInt(r, "limit", defaultQuickStartLimit)
+	if limit < 1 || limit > maxQuickStartLimit {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be between 1 and 20"})
+		return
+	}
+
+	// admins can log time on any project, everyone else only on their own
+	quickStart, err := qh.quickStartStore.GetQuickStart(r.Context(), u.Id, u.Role != "admin", limit)
+	if err != nil {
+		qh.logger.Println("GetQuickStart error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"quick_start": quickStart})
+}
+
+// HandleAddFavorite pins a project the caller can log time on.
+func (qh *QuickStartHandler) HandleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	allowed, err := canLogOnProject(r.Context(), qh.memberStore, u, projectId)
+	if err != nil {
+		qh.logger.Println("IsProjectMember error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !allowed {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		return
+	}
+
+	if err := qh.quickStartStore.AddFavorite(r.Context(), u.Id, projectId); err != nil {
+		if strings.Contains(err.Error(), "favorite_projects_project_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+		qh.logger.Println("AddFavorite error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "project added to favorites"})
+}
+
+func (qh *QuickStartHandler) HandleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := qh.quickStartStore.RemoveFavorite(r.Context(), u.Id, projectId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project is not a favorite"})
+			return
+		}
+		qh.logger.Println("RemoveFavorite error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "project removed from favorites"})
+}
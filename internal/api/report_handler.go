@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const (
+	defaultReportLimit = 1000
+	maxReportLimit     = 10000
+)
+
+// reportQueryInput is the report builder request without its dates.
+// Saved reports keep it as their definition.
+type reportQueryInput struct {
+	Dimensions []string `json:"dimensions"`
+	Metrics    []string `json:"metrics"`
+	Filters    struct {
+		UserIDs    []int64  `json:"user_ids"`
+		ProjectIDs []int64  `json:"project_ids"`
+		TeamIDs    []int64  `json:"team_ids"`
+		Tags       []string `json:"tags"`
+		Billable   *bool    `json:"billable"`
+	} `json:"filters"`
+	Format string `json:"format"`
+	Sort   string `json:"sort"`
+	Limit  *int   `json:"limit"`
+}
+
+// toQuery validates the input and builds the query for [from, to).
+// Normal users only ever see their own sessions.
+func (in *reportQueryInput) toQuery(userID int64, isAdmin bool, from, to time.Time) (store.ReportQuery, error) {
+	limit := defaultReportLimit
+	if in.Limit != nil {
+		if *in.Limit < 1 || *in.Limit > maxReportLimit {
+			return store.ReportQuery{}, errors.New("limit must be between 1 and 10000")
+		}
+		limit = *in.Limit
+	}
+
+	tags, msg := normalizeTags(in.Filters.Tags)
+	if msg != "" {
+		return store.ReportQuery{}, errors.New(msg)
+	}
+
+	query := store.ReportQuery{
+		Dimensions: in.Dimensions,
+		Metrics:    in.Metrics,
+		From:       from,
+		To:         to,
+		UserIDs:    in.Filters.UserIDs,
+		ProjectIDs: in.Filters.ProjectIDs,
+		TeamIDs:    in.Filters.TeamIDs,
+		Tags:       tags,
+		Billable:   in.Filters.Billable,
+		Format:     in.Format,
+		Sort:       strings.TrimSpace(in.Sort),
+		Limit:      limit,
+	}
+	if query.Format == "" {
+		query.Format = store.ReportFormatFlat
+	}
+
+	if !isAdmin {
+		query.UserIDs = []int64{userID}
+		query.TeamIDs = nil
+	}
+
+	return query, query.Validate()
+}
+
+type ReportHandler struct {
+	reportStore      store.ReportStore
+	customFieldStore store.CustomFieldStore
+	logger           *log.Logger
+}
+
+func NewReportHandler(reportStore store.ReportStore, customFieldStore store.CustomFieldStore, logger *log.Logger) *ReportHandler {
+	return &ReportHandler{
+		reportStore:      reportStore,
+		customFieldStore: customFieldStore,
+		logger:           logger,
+	}
+}
+
+// HandleRunReportQuery runs a custom report.
+func (rh *ReportHandler) HandleRunReportQuery(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		TimeZone string `json:"tz"`
+		reportQueryInput
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = "UTC"
+	}
+	loc, err := utils.LoadTimeZone(req.TimeZone)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	if strings.TrimSpace(req.From) == "" || strings.TrimSpace(req.To) == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from and to are required"})
+		return
+	}
+
+	from, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(req.From), loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+		return
+	}
+
+	to, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(req.To), loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+		return
+	}
+
+	if to.Before(from) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxOvertimePeriodDays-1)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period can't be longer than 366 days"})
+		return
+	}
+
+	query, err := req.toQuery(u.Id, u.Role == "admin", from, to.AddDate(0, 0, 1))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	msg, err := checkReportFields(r.Context(), rh.customFieldStore, query.Dimensions)
+	if err != nil {
+		rh.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	result, err := rh.reportStore.RunReportQuery(r.Context(), query)
+	if err != nil {
+		rh.logger.Println("RunReportQuery error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"report": result})
+}
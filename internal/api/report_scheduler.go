@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/cron"
+	"github.com/htojiddinov77-png/worktime/internal/notify"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+)
+
+// ReportScheduler renders saved reports when their cron schedule is due and
+// delivers them through the notifier. Without a notifier runs are only kept in the history.
+type ReportScheduler struct {
+	savedReportStore store.SavedReportStore
+	reportStore      store.ReportStore
+	notifier         notify.Notifier
+	logger           *log.Logger
+}
+
+func NewReportScheduler(savedReportStore store.SavedReportStore, reportStore store.ReportStore, notifier notify.Notifier, logger *log.Logger) *ReportScheduler {
+	return &ReportScheduler{
+		savedReportStore: savedReportStore,
+		reportStore:      reportStore,
+		notifier:         notifier,
+		logger:           logger,
+	}
+}
+
+// nextReportRun returns the next run after now in the report's time zone, or nil without a schedule.
+func nextReportRun(schedule *string, loc *time.Location, now time.Time) (*time.Time, error) {
+	if schedule == nil {
+		return nil, nil
+	}
+
+	s, err := cron.Parse(*schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	next := s.Next(now.In(loc))
+	if next.IsZero() {
+		return nil, errors.New("schedule never runs")
+	}
+	return &next, nil
+}
+
+// RunDue runs every saved report whose schedule is due. A report that was down
+// for several runs is run once, then moved to its next run after now.
+func (rs *ReportScheduler) RunDue(ctx context.Context, now time.Time) {
+	due, err := rs.savedReportStore.ListDueSavedReports(ctx, now)
+	if err != nil {
+		rs.logger.Println("ListDueSavedReports error:", err)
+		return
+	}
+
+	for i := range due {
+		sr := &due[i]
+
+		loc, err := time.LoadLocation(sr.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		next, err := nextReportRun(sr.Schedule, loc, now)
+		if err != nil {
+			rs.logger.Printf("saved report %d: invalid schedule: %v", sr.Id, err)
+			next = nil
+		}
+
+		if err := rs.savedReportStore.ClaimSavedReportRun(ctx, sr.Id, *sr.NextRunAt, next); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				rs.logger.Println("ClaimSavedReportRun error:", err)
+			}
+			continue
+		}
+
+		if _, err := rs.Execute(ctx, sr, store.ReportRunSchedule, now, true); err != nil {
+			rs.logger.Println("saved report run error:", err)
+		}
+	}
+}
+
+// Run checks for due reports every interval until ctx is done.
+func (rs *ReportScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rs.RunDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Execute renders a saved report for its period at now, optionally delivers it,
+// and records the run. A failed query or delivery is recorded on the run;
+// the error is only returned when the run itself couldn't be saved.
+func (rs *ReportScheduler) Execute(ctx context.Context, sr *store.SavedReport, trigger string, now time.Time, deliver bool) (*store.ReportRun, error) {
+	loc, err := time.LoadLocation(sr.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	from, to, _ := store.SavedReportRange(sr.Period, now.In(loc))
+
+	run := &store.ReportRun{
+		SavedReportId: sr.Id,
+		Trigger:       trigger,
+		From:          from.Format(time.DateOnly),
+		To:            to.AddDate(0, 0, -1).Format(time.DateOnly),
+		Status:        "success",
+		Delivery:      "skipped",
+	}
+
+	run.Output, run.ContentType, err = rs.render(ctx, sr, from, to)
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+	} else {
+		run.Filename = fmt.Sprintf("%s_%s_%s.%s", reportFileSlug(sr.Name), run.From, run.To, sr.Output)
+
+		if deliver && rs.notifier != nil {
+			if err := rs.deliver(ctx, sr, run); err != nil {
+				run.Delivery = "failed"
+				run.DeliveryError = err.Error()
+			} else {
+				run.Delivery = "sent"
+			}
+		}
+	}
+
+	if err := rs.savedReportStore.CreateReportRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (rs *ReportScheduler) render(ctx context.Context, sr *store.SavedReport, from, to time.Time) ([]byte, string, error) {
+	var in reportQueryInput
+	if err := json.Unmarshal(sr.Definition, &in); err != nil {
+		return nil, "", fmt.Errorf("invalid definition: %w", err)
+	}
+
+	// csv only has room for one row per combination
+	if sr.Output == store.SavedReportOutputCSV {
+		in.Format = store.ReportFormatFlat
+	}
+
+	query, err := in.toQuery(sr.UserId, sr.OwnerRole == "admin", from, to)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := rs.reportStore.RunReportQuery(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if sr.Output == store.SavedReportOutputJSON {
+		data, err := json.MarshalIndent(result, "", "  ")
+		return data, "application/json", err
+	}
+
+	data, err := reportCSV(result)
+	return data, "text/csv", err
+}
+
+// reportCSV writes the dimension values and metrics of flat rows, then a totals row.
+func reportCSV(result *store.ReportResult) ([]byte, error) {
+	var header []string
+	for _, d := range result.Dimensions {
+		header = append(header, store.ReportDimensionKeys(d)...)
+	}
+	header = append(header, result.Metrics...)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	record := func(row store.ReportRow) []string {
+		out := make([]string, len(header))
+		for i, key := range header {
+			if v := row[key]; v != nil {
+				out[i] = fmt.Sprint(v)
+			}
+		}
+		return out
+	}
+
+	for _, row := range result.Rows {
+		if err := w.Write(record(row)); err != nil {
+			return nil, err
+		}
+	}
+
+	totals := record(result.Totals)
+	if len(result.Dimensions) > 0 {
+		totals[0] = "total"
+	}
+	if err := w.Write(totals); err != nil {
+		return nil, err
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (rs *ReportScheduler) deliver(ctx context.Context, sr *store.SavedReport, run *store.ReportRun) error {
+	to := sr.Recipients
+	if len(to) == 0 {
+		to = []string{sr.OwnerEmail}
+	}
+
+	return rs.notifier.Send(ctx, notify.Message{
+		To:      to,
+		Subject: fmt.Sprintf("%s (%s to %s)", sr.Name, run.From, run.To),
+		Body:    fmt.Sprintf("The report %q for %s to %s is attached.\n", sr.Name, run.From, run.To),
+		Attachment: notify.Attachment{
+			Filename:    run.Filename,
+			ContentType: run.ContentType,
+			Data:        run.Output,
+		},
+	})
+}
+
+// reportFileSlug keeps letters and digits of a report name for file names.
+func reportFileSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "report"
+	}
+	return slug
+}
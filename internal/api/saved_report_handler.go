@@ -0,0 +1,414 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const maxReportRecipients = 20
+
+type SavedReportHandler struct {
+	savedReportStore store.SavedReportStore
+	customFieldStore store.CustomFieldStore
+	scheduler        *ReportScheduler
+	logger           *log.Logger
+}
+
+func NewSavedReportHandler(savedReportStore store.SavedReportStore, customFieldStore store.CustomFieldStore, scheduler *ReportScheduler, logger *log.Logger) *SavedReportHandler {
+	return &SavedReportHandler{
+		savedReportStore: savedReportStore,
+		customFieldStore: customFieldStore,
+		scheduler:        scheduler,
+		logger:           logger,
+	}
+}
+
+// normalizeReportDefinition checks a definition like a report builder request and
+// returns it re-encoded, so only known fields are stored.
+func normalizeReportDefinition(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, errors.New("definition is required")
+	}
+
+	var in reportQueryInput
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		return nil, errors.New("invalid definition")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if _, err := in.toQuery(0, true, today, today.AddDate(0, 0, 1)); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(in)
+}
+
+// validateSavedReport checks the fields of a saved report and sets its next run.
+func validateSavedReport(sr *store.SavedReport) error {
+	if sr.Name == "" {
+		return errors.New("name can't be empty")
+	}
+
+	if _, _, ok := store.SavedReportRange(sr.Period, time.Now()); !ok {
+		return errors.New("period must be previous_week, previous_month, last_7_days, last_30_days, week_to_date or month_to_date")
+	}
+
+	if sr.Output != store.SavedReportOutputCSV && sr.Output != store.SavedReportOutputJSON {
+		return errors.New("output must be csv or json")
+	}
+
+	loc, err := utils.LoadTimeZone(sr.TimeZone)
+	if err != nil {
+		return errors.New("invalid tz")
+	}
+
+	if len(sr.Recipients) > maxReportRecipients {
+		return fmt.Errorf("at most %d recipients are allowed", maxReportRecipients)
+	}
+	for i, to := range sr.Recipients {
+		addr, err := mail.ParseAddress(strings.TrimSpace(to))
+		if err != nil {
+			return fmt.Errorf("invalid recipient: %s", to)
+		}
+		sr.Recipients[i] = addr.Address
+	}
+
+	if sr.Schedule != nil && strings.TrimSpace(*sr.Schedule) == "" {
+		sr.Schedule = nil
+	}
+	sr.NextRunAt, err = nextReportRun(sr.Schedule, loc, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	return nil
+}
+
+// readOwnSavedReport loads the {id} report of the caller. Other users' reports are not found.
+func (sh *SavedReportHandler) readOwnSavedReport(w http.ResponseWriter, r *http.Request) (*store.SavedReport, bool) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return nil, false
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return nil, false
+	}
+
+	sr, err := sh.savedReportStore.GetSavedReport(r.Context(), id)
+	if err != nil {
+		sh.logger.Println("GetSavedReport error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+	if sr == nil || sr.UserId != u.Id {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "saved report not found"})
+		return nil, false
+	}
+
+	return sr, true
+}
+
+// readReportDefinition normalizes a definition and checks its custom field dimensions,
+// writing the error response when it fails.
+func (sh *SavedReportHandler) readReportDefinition(w http.ResponseWriter, r *http.Request, raw json.RawMessage) (json.RawMessage, bool) {
+	definition, err := normalizeReportDefinition(raw)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+
+	var in reportQueryInput
+	if err := json.Unmarshal(definition, &in); err != nil {
+		sh.logger.Println("Unmarshal definition error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+
+	msg, err := checkReportFields(r.Context(), sh.customFieldStore, in.Dimensions)
+	if err != nil {
+		sh.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return nil, false
+	}
+
+	return definition, true
+}
+
+func (sh *SavedReportHandler) writeSaveError(w http.ResponseWriter, op string, err error) {
+	if strings.Contains(err.Error(), "saved_reports_user_name_key") {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "a saved report with this name already exists"})
+		return
+	}
+	sh.logger.Println(op+" error:", err)
+	utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+}
+
+func (sh *SavedReportHandler) HandleListSavedReports(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	reports, err := sh.savedReportStore.ListSavedReports(r.Context(), u.Id)
+	if err != nil {
+		sh.logger.Println("ListSavedReports error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"saved_reports": reports})
+}
+
+func (sh *SavedReportHandler) HandleCreateSavedReport(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		Name       string          `json:"name"`
+		Definition json.RawMessage `json:"definition"`
+		Period     string          `json:"period"`
+		Output     string          `json:"output"`
+		TimeZone   string          `json:"tz"`
+		Schedule   *string         `json:"schedule"`
+		Recipients []string        `json:"recipients"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	definition, ok := sh.readReportDefinition(w, r, req.Definition)
+	if !ok {
+		return
+	}
+
+	sr := &store.SavedReport{
+		UserId:     u.Id,
+		Name:       strings.TrimSpace(req.Name),
+		Definition: definition,
+		Period:     req.Period,
+		Output:     req.Output,
+		TimeZone:   req.TimeZone,
+		Schedule:   req.Schedule,
+		Recipients: req.Recipients,
+	}
+	if sr.Output == "" {
+		sr.Output = store.SavedReportOutputCSV
+	}
+	if sr.TimeZone == "" {
+		sr.TimeZone = "UTC"
+	}
+	if sr.Recipients == nil {
+		sr.Recipients = []string{}
+	}
+
+	if err := validateSavedReport(sr); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err := sh.savedReportStore.CreateSavedReport(r.Context(), sr); err != nil {
+		sh.writeSaveError(w, "CreateSavedReport", err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"saved_report": sr})
+}
+
+func (sh *SavedReportHandler) HandleGetSavedReport(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"saved_report": sr})
+}
+
+func (sh *SavedReportHandler) HandleUpdateSavedReport(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name       *string         `json:"name"`
+		Definition json.RawMessage `json:"definition"`
+		Period     *string         `json:"period"`
+		Output     *string         `json:"output"`
+		TimeZone   *string         `json:"tz"`
+		Schedule   json.RawMessage `json:"schedule"`
+		Recipients *[]string       `json:"recipients"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.Name != nil {
+		sr.Name = strings.TrimSpace(*req.Name)
+	}
+	if len(req.Definition) > 0 {
+		definition, ok := sh.readReportDefinition(w, r, req.Definition)
+		if !ok {
+			return
+		}
+		sr.Definition = definition
+	}
+	if req.Period != nil {
+		sr.Period = *req.Period
+	}
+	if req.Output != nil {
+		sr.Output = *req.Output
+	}
+	if req.TimeZone != nil {
+		sr.TimeZone = *req.TimeZone
+	}
+	if req.Recipients != nil {
+		sr.Recipients = *req.Recipients
+	}
+
+	// absent: keep, null: unschedule, string: set
+	if len(req.Schedule) > 0 {
+		var schedule *string
+		if err := json.Unmarshal(req.Schedule, &schedule); err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "schedule must be a string or null"})
+			return
+		}
+		sr.Schedule = schedule
+	}
+
+	if err := validateSavedReport(sr); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err := sh.savedReportStore.UpdateSavedReport(r.Context(), sr); err != nil {
+		sh.writeSaveError(w, "UpdateSavedReport", err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"saved_report": sr})
+}
+
+func (sh *SavedReportHandler) HandleDeleteSavedReport(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sh.savedReportStore.DeleteSavedReport(r.Context(), sr.Id); err != nil {
+		sh.logger.Println("DeleteSavedReport error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "saved report deleted"})
+}
+
+// HandleRunSavedReport renders the report now. ?deliver=true also sends it through the notifier.
+func (sh *SavedReportHandler) HandleRunSavedReport(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	deliver, err := utils.ReadBool(r, "deliver")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid deliver"})
+		return
+	}
+
+	run, err := sh.scheduler.Execute(r.Context(), sr, store.ReportRunManual, time.Now(), deliver != nil && *deliver)
+	if err != nil {
+		sh.logger.Println("saved report run error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"run": run})
+}
+
+func (sh *SavedReportHandler) HandleListReportRuns(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := sh.savedReportStore.ListReportRuns(r.Context(), sr.Id)
+	if err != nil {
+		sh.logger.Println("ListReportRuns error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"runs": runs})
+}
+
+// HandleDownloadReportRun returns the output of a past run as a file.
+func (sh *SavedReportHandler) HandleDownloadReportRun(w http.ResponseWriter, r *http.Request) {
+	sr, ok := sh.readOwnSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	runID, err := strconv.ParseInt(chi.URLParam(r, "run_id"), 10, 64)
+	if err != nil || runID <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid run id"})
+		return
+	}
+
+	run, err := sh.savedReportStore.GetReportRun(r.Context(), sr.Id, runID)
+	if err != nil {
+		sh.logger.Println("GetReportRun error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if run == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "run not found"})
+		return
+	}
+	if run.Status != "success" {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "run failed: " + run.Error})
+		return
+	}
+
+	w.Header().Set("Content-Type", run.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", run.Filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(run.Output)
+}
@@ -0,0 +1,537 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/ical"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const (
+	maxCalendarUploadSize = 5 << 20 // 5 MB
+	maxDraftBatch         = 500
+)
+
+type SessionDraftHandler struct {
+	draftStore       store.SessionDraftStore
+	memberStore      store.ProjectMemberStore
+	customFieldStore store.CustomFieldStore
+	logger           *log.Logger
+	Hub              *Hub
+}
+
+func NewSessionDraftHandler(draftStore store.SessionDraftStore, memberStore store.ProjectMemberStore, customFieldStore store.CustomFieldStore, logger *log.Logger, hub *Hub) *SessionDraftHandler {
+	return &SessionDraftHandler{
+		draftStore:       draftStore,
+		memberStore:      memberStore,
+		customFieldStore: customFieldStore,
+		logger:           logger,
+		Hub:              hub,
+	}
+}
+
+func (dh *SessionDraftHandler) HandleListImportRules(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	rules, err := dh.draftStore.ListImportRules(r.Context(), u.Id)
+	if err != nil {
+		dh.logger.Println("ListImportRules error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"rules": rules})
+}
+
+func (dh *SessionDraftHandler) HandleCreateImportRule(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		MatchField string `json:"match_field"`
+		Pattern    string `json:"pattern"`
+		ProjectID  int64  `json:"project_id"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	req.MatchField = strings.ToLower(strings.TrimSpace(req.MatchField))
+	if req.MatchField != "summary" && req.MatchField != "category" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "match_field must be 'summary' or 'category'"})
+		return
+	}
+
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	if req.Pattern == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "pattern can't be empty"})
+		return
+	}
+
+	if req.ProjectID <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project_id must be positive"})
+		return
+	}
+
+	rule := &store.CalendarImportRule{
+		UserId:     u.Id,
+		MatchField: req.MatchField,
+		Pattern:    req.Pattern,
+		ProjectId:  req.ProjectID,
+	}
+
+	if err := dh.draftStore.CreateImportRule(r.Context(), rule); err != nil {
+		if strings.Contains(err.Error(), "calendar_import_rules_project_id_fkey") {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project not found"})
+			return
+		}
+		dh.logger.Println("CreateImportRule error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"rule": rule})
+}
+
+func (dh *SessionDraftHandler) HandleDeleteImportRule(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := dh.draftStore.DeleteImportRule(r.Context(), id, u.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "rule not found"})
+			return
+		}
+		dh.logger.Println("DeleteImportRule error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "rule deleted"})
+}
+
+// matchImportRule returns the project of the first rule (by id) that matches the event.
+func matchImportRule(rules []store.CalendarImportRule, ev ical.Event) *int64 {
+	for _, rule := range rules {
+		switch rule.MatchField {
+		case "summary":
+			if strings.Contains(strings.ToLower(ev.Summary), strings.ToLower(rule.Pattern)) {
+				id := rule.ProjectId
+				return &id
+			}
+		case "category":
+			for _, c := range ev.Categories {
+				if strings.EqualFold(c, rule.Pattern) {
+					id := rule.ProjectId
+					return &id
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// HandleImportCalendar turns the events of an uploaded .ics file into drafts.
+// All-day events and events longer than a day are skipped.
+func (dh *SessionDraftHandler) HandleImportCalendar(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalendarUploadSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	events, err := ical.Parse(body, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid calendar: " + err.Error()})
+		return
+	}
+
+	rules, err := dh.draftStore.ListImportRules(r.Context(), u.Id)
+	if err != nil {
+		dh.logger.Println("ListImportRules error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	var drafts []store.SessionDraft
+	skipped, unmatched := 0, 0
+
+	for _, ev := range events {
+		if ev.AllDay || !ev.End.After(ev.Start) || ev.End.Sub(ev.Start) > maxManualSession {
+			skipped++
+			continue
+		}
+
+		projectID := matchImportRule(rules, ev)
+		if projectID == nil {
+			unmatched++
+		}
+
+		drafts = append(drafts, store.SessionDraft{
+			UserId:    u.Id,
+			ProjectId: projectID,
+			StartAt:   ev.Start,
+			EndAt:     ev.End,
+			Note:      strings.TrimSpace(ev.Summary),
+			SourceUID: ev.UID,
+		})
+	}
+
+	created, err := dh.draftStore.CreateDrafts(r.Context(), drafts)
+	if err != nil {
+		dh.logger.Println("CreateDrafts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{
+		"events":          len(events),
+		"created":         created,
+		"already_exists":  len(drafts) - created,
+		"skipped":         skipped,
+		"without_project": unmatched,
+	})
+}
+
+func (dh *SessionDraftHandler) HandleListDrafts(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	drafts, err := dh.draftStore.ListDrafts(r.Context(), u.Id)
+	if err != nil {
+		dh.logger.Println("ListDrafts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"count": len(drafts), "drafts": drafts})
+}
+
+func (dh *SessionDraftHandler) HandleUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		ProjectID *int64  `json:"project_id"`
+		Note      *string `json:"note"`
+		StartAt   *string `json:"start_at"`
+		EndAt     *string `json:"end_at"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	drafts, err := dh.draftStore.GetDrafts(r.Context(), u.Id, []int64{id})
+	if err != nil {
+		dh.logger.Println("GetDrafts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if len(drafts) == 0 {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "draft not found"})
+		return
+	}
+	draft := drafts[0]
+
+	if req.ProjectID != nil {
+		if *req.ProjectID <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project_id must be positive"})
+			return
+		}
+		draft.ProjectId = req.ProjectID
+	}
+	if req.Note != nil {
+		draft.Note = strings.TrimSpace(*req.Note)
+	}
+	if req.StartAt != nil {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(*req.StartAt))
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "start_at must be RFC3339"})
+			return
+		}
+		draft.StartAt = t
+	}
+	if req.EndAt != nil {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(*req.EndAt))
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "end_at must be RFC3339"})
+			return
+		}
+		draft.EndAt = t
+	}
+
+	if !draft.EndAt.After(draft.StartAt) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "end_at must be after start_at"})
+		return
+	}
+
+	if err := dh.draftStore.UpdateDraft(r.Context(), &draft); err != nil {
+		if strings.Contains(err.Error(), "session_drafts_project_id_fkey") {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project not found"})
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "draft not found"})
+			return
+		}
+		dh.logger.Println("UpdateDraft error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"draft": draft})
+}
+
+func readDraftIDs(r *http.Request) ([]int64, error) {
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		return nil, errors.New("invalid JSON body")
+	}
+	return req.IDs, checkDraftIDs(req.IDs)
+}
+
+func checkDraftIDs(ids []int64) error {
+	if len(ids) == 0 {
+		return errors.New("ids can't be empty")
+	}
+	if len(ids) > maxDraftBatch {
+		return fmt.Errorf("at most %d ids per request", maxDraftBatch)
+	}
+	return nil
+}
+
+type draftError struct {
+	Id    int64  `json:"id"`
+	Error string `json:"error"`
+}
+
+// HandleConfirmDrafts turns drafts into work sessions. Each draft goes through the same
+// validation as a manual entry; drafts that fail stay in place and are reported.
+// The custom values sent apply to every confirmed session.
+func (dh *SessionDraftHandler) HandleConfirmDrafts(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		IDs          []int64                    `json:"ids"`
+		CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+	if err := checkDraftIDs(req.IDs); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	ids := req.IDs
+
+	custom, msg, err := readCustomValues(r.Context(), dh.customFieldStore, store.CustomFieldEntitySession, nil, req.CustomFields, true)
+	if err != nil {
+		dh.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	drafts, err := dh.draftStore.GetDrafts(r.Context(), u.Id, ids)
+	if err != nil {
+		dh.logger.Println("GetDrafts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	found := map[int64]bool{}
+	confirmed := []*store.WorkSession{}
+	failed := []draftError{}
+
+	for i := range drafts {
+		draft := &drafts[i]
+		found[draft.Id] = true
+
+		if draft.ProjectId == nil {
+			failed = append(failed, draftError{Id: draft.Id, Error: "draft has no project"})
+			continue
+		}
+
+		end := draft.EndAt
+		ws := &store.WorkSession{
+			UserId:    draft.UserId,
+			ProjectId: *draft.ProjectId,
+			StartAt:   draft.StartAt,
+			EndAt:     &end,
+			Note:      draft.Note,
+		}
+
+		if msg := validateManualSession(ws); msg != "" {
+			failed = append(failed, draftError{Id: draft.Id, Error: msg})
+			continue
+		}
+
+		member, err := canLogOnProject(r.Context(), dh.memberStore, u, ws.ProjectId)
+		if err != nil {
+			dh.logger.Println("IsProjectMember error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if !member {
+			failed = append(failed, draftError{Id: draft.Id, Error: "you are not a member of this project"})
+			continue
+		}
+
+		ws, err = dh.draftStore.ConfirmDraft(r.Context(), draft, custom)
+		if err != nil {
+			if isProjectNotFound(err) {
+				failed = append(failed, draftError{Id: draft.Id, Error: "project not found"})
+				continue
+			}
+			if isProjectArchived(err) {
+				failed = append(failed, draftError{Id: draft.Id, Error: "project is archived"})
+				continue
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				failed = append(failed, draftError{Id: draft.Id, Error: "draft not found"})
+				continue
+			}
+			if errors.Is(err, store.ErrSessionOverlaps) {
+				failed = append(failed, draftError{Id: draft.Id, Error: err.Error()})
+				continue
+			}
+			dh.logger.Println("ConfirmDraft error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+
+		confirmed = append(confirmed, ws)
+
+		dh.Hub.Publish(Event{
+			Type:      "session_created",
+			UserID:    ws.UserId,
+			ProjectID: ws.ProjectId,
+			Data: map[string]any{
+				"session_id": ws.Id,
+				"user_id":    ws.UserId,
+				"project_id": ws.ProjectId,
+				"start_at":   ws.StartAt,
+				"end_at":     ws.EndAt,
+			},
+		})
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			failed = append(failed, draftError{Id: id, Error: "draft not found"})
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"confirmed": confirmed,
+		"errors":    failed,
+	})
+}
+
+func (dh *SessionDraftHandler) HandleDiscardDrafts(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	ids, err := readDraftIDs(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	n, err := dh.draftStore.DeleteDrafts(r.Context(), u.Id, ids)
+	if err != nil {
+		dh.logger.Println("DeleteDrafts error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"discarded": n})
+}
@@ -0,0 +1,449 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htojiddinov77-png/worktime/internal/auth"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+const (
+	defaultShareDays = 30
+	maxShareDays     = 365
+)
+
+type ShareHandler struct {
+	shareStore store.ShareStore
+	jwt        *auth.JWTManager
+	logger     *log.Logger
+}
+
+func NewShareHandler(shareStore store.ShareStore, jwt *auth.JWTManager, logger *log.Logger) *ShareHandler {
+	return &ShareHandler{
+		shareStore: shareStore,
+		jwt:        jwt,
+		logger:     logger,
+	}
+}
+
+type shareResponse struct {
+	store.ReportShare
+	URL string `json:"url"`
+}
+
+// withURL signs the share's link again; the token of a share never changes.
+func (sh *ShareHandler) withURL(s store.ReportShare) (shareResponse, error) {
+	token, err := sh.jwt.CreateShareToken(s.Id, s.ExpiresAt)
+	if err != nil {
+		return shareResponse{}, err
+	}
+	return shareResponse{ReportShare: s, URL: utils.PublicURL("/api/v1/share/" + token)}, nil
+}
+
+// HandleCreateShare creates a public link to one project's hours.
+// A frozen link keeps the report as it is now; a live one is rebuilt on every view.
+func (sh *ShareHandler) HandleCreateShare(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	u, _ := middleware.GetUser(r)
+
+	projectID, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		From          string `json:"from"`
+		To            string `json:"to"`
+		TimeZone      string `json:"tz"`
+		Mode          string `json:"mode"`
+		Title         string `json:"title"`
+		ExpiresInDays *int   `json:"expires_in_days"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = "UTC"
+	}
+	loc, err := utils.LoadTimeZone(req.TimeZone)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
+
+	from, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(req.From), loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(req.To), loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+		return
+	}
+	if to.Before(from) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxOvertimePeriodDays-1)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period can't be longer than 366 days"})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = store.ShareModeLive
+	}
+	if req.Mode != store.ShareModeLive && req.Mode != store.ShareModeFrozen {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "mode must be live or frozen"})
+		return
+	}
+
+	days := defaultShareDays
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays < 1 || *req.ExpiresInDays > maxShareDays {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "expires_in_days must be between 1 and 365"})
+			return
+		}
+		days = *req.ExpiresInDays
+	}
+
+	report, err := sh.shareStore.BuildSharedReport(r.Context(), projectID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		sh.logger.Println("BuildSharedReport error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if report == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		return
+	}
+
+	createdBy := u.Id
+	share := &store.ReportShare{
+		ProjectId:   projectID,
+		ProjectName: report.ProjectName,
+		CreatedBy:   &createdBy,
+		Title:       strings.TrimSpace(req.Title),
+		Mode:        req.Mode,
+		From:        report.From,
+		To:          report.To,
+		TimeZone:    loc.String(),
+		ExpiresAt:   time.Now().UTC().Add(time.Duration(days) * 24 * time.Hour).Truncate(time.Second),
+	}
+
+	if share.Mode == store.ShareModeFrozen {
+		report.Title = share.Title
+		share.Snapshot, err = json.Marshal(report)
+		if err != nil {
+			sh.logger.Println("snapshot error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	if err := sh.shareStore.CreateReportShare(r.Context(), share); err != nil {
+		sh.logger.Println("CreateReportShare error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	resp, err := sh.withURL(*share)
+	if err != nil {
+		sh.logger.Println("CreateShareToken error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"share": resp})
+}
+
+func (sh *ShareHandler) HandleListShares(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	projectID, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	shares, err := sh.shareStore.ListReportShares(r.Context(), projectID)
+	if err != nil {
+		sh.logger.Println("ListReportShares error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	out := make([]shareResponse, 0, len(shares))
+	for _, s := range shares {
+		resp, err := sh.withURL(s)
+		if err != nil {
+			sh.logger.Println("CreateShareToken error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		out = append(out, resp)
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"shares": out})
+}
+
+func (sh *ShareHandler) HandleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := sh.shareStore.RevokeReportShare(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "no active share"})
+			return
+		}
+		sh.logger.Println("RevokeReportShare error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "share revoked"})
+}
+
+func (sh *ShareHandler) HandleListShareAccesses(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	accesses, err := sh.shareStore.ListShareAccesses(r.Context(), id)
+	if err != nil {
+		sh.logger.Println("ListShareAccesses error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"accesses": accesses})
+}
+
+var sharedReportPage = template.Must(template.New("share").Funcs(template.FuncMap{
+	"value": func(f *float64) float64 { return *f },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}{{.ProjectName}}{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: .4rem .6rem; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+tfoot td { font-weight: bold; border-top: 2px solid #222; }
+.muted { color: #777; font-size: .9rem; }
+</style>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}{{.ProjectName}}{{end}}</h1>
+<p>{{.ProjectName}} &middot; {{.From}} to {{.To}} ({{.TimeZone}})</p>
+<table>
+<thead><tr><th>Date</th><th class="num">Hours</th></tr></thead>
+<tbody>
+{{range .Days}}<tr><td>{{.Date}}</td><td class="num">{{printf "%.2f" .Hours}}</td></tr>
+{{else}}<tr><td colspan="2" class="muted">No time logged in this period.</td></tr>
+{{end}}</tbody>
+<tfoot>
+<tr><td>Total</td><td class="num">{{printf "%.2f" .TotalHours}}</td></tr>
+{{if .Amount}}<tr><td>Amount ({{printf "%.2f" (value .HourlyRate)}} per hour)</td><td class="num">{{printf "%.2f" (value .Amount)}}</td></tr>{{end}}
+</tfoot>
+</table>
+<p class="muted">Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</p>
+</body>
+</html>
+`))
+
+// clientIP is the address the request came from. X-Forwarded-For is only believed when the
+// request comes from a proxy listed in WORKTIME_TRUSTED_PROXIES (comma-separated IPs or CIDRs);
+// then the entries are read from the right and the first one that isn't a trusted proxy wins.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	trusted := trustedProxies()
+	if len(trusted) == 0 || !isTrustedProxy(trusted, host) {
+		return host
+	}
+
+	fwd := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(fwd) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(fwd[i])
+		if ip == "" {
+			continue
+		}
+		if !isTrustedProxy(trusted, ip) {
+			return ip
+		}
+		host = ip
+	}
+	return host
+}
+
+// trustedProxies parses WORKTIME_TRUSTED_PROXIES; invalid entries are ignored.
+func trustedProxies() []netip.Prefix {
+	var out []netip.Prefix
+	for _, s := range strings.Split(os.Getenv("WORKTIME_TRUSTED_PROXIES"), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(s); err == nil {
+			out = append(out, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(s); err == nil {
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return out
+}
+
+func isTrustedProxy(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleViewShare serves GET /share/{token}. It is public: the signed token is the credential.
+// ?format=html (or a browser's Accept header) renders a page, otherwise JSON.
+func (sh *ShareHandler) HandleViewShare(w http.ResponseWriter, r *http.Request) {
+	format := utils.ReadString(r, "format", "")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			format = "html"
+		}
+	}
+	if format != "json" && format != "html" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "format must be json or html"})
+		return
+	}
+
+	claims, err := sh.jwt.ParseShareToken(chi.URLParam(r, "token"))
+	if err != nil && !errors.Is(err, auth.ErrShareExpired) {
+		http.NotFound(w, r)
+		return
+	}
+
+	share, err := sh.shareStore.GetReportShare(r.Context(), claims.ShareID)
+	if err != nil {
+		sh.logger.Println("GetReportShare error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if share == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	access := &store.ShareAccess{
+		ShareId:   share.Id,
+		Outcome:   store.ShareAccessOK,
+		Format:    format,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if len(access.UserAgent) > 500 {
+		access.UserAgent = access.UserAgent[:500]
+	}
+
+	switch {
+	case share.RevokedAt != nil:
+		access.Outcome = store.ShareAccessRevoked
+	case !time.Now().Before(share.ExpiresAt):
+		access.Outcome = store.ShareAccessExpired
+	}
+
+	if err := sh.shareStore.LogShareAccess(r.Context(), access); err != nil {
+		sh.logger.Println("LogShareAccess error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if access.Outcome != store.ShareAccessOK {
+		utils.WriteJson(w, http.StatusGone, utils.Envelope{"error": "this link has " + access.Outcome})
+		return
+	}
+
+	var report *store.SharedReport
+	if share.Mode == store.ShareModeFrozen {
+		report = &store.SharedReport{}
+		err = json.Unmarshal(share.Snapshot, report)
+	} else {
+		loc, _ := time.LoadLocation(share.TimeZone)
+		from, _ := time.ParseInLocation(time.DateOnly, share.From, loc)
+		to, _ := time.ParseInLocation(time.DateOnly, share.To, loc)
+
+		report, err = sh.shareStore.BuildSharedReport(r.Context(), share.ProjectId, from, to.AddDate(0, 0, 1))
+		if report != nil {
+			report.Title = share.Title
+		}
+	}
+	if err != nil || report == nil {
+		sh.logger.Println("shared report error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := sharedReportPage.Execute(w, report); err != nil {
+			sh.logger.Println("share page error:", err)
+		}
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"report": report})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,15 +10,18 @@ import (
 	"time"
 
 	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
 )
 
 // Event is a server-side message that should be delivered to:
 // - the affected user (UserID)
+// - the managers of the project it is about (ProjectID), if any
 // - and all admins (optional behavior implemented here)
 type Event struct {
-	Type   string      `json:"type"`
-	UserID int64       `json:"user_id"` // affected user
-	Data   interface{} `json:"data"`
+	Type      string      `json:"type"`
+	UserID    int64       `json:"user_id"` // affected user
+	ProjectID int64       `json:"-"`       // project the event is about, 0 for none
+	Data      interface{} `json:"data"`
 }
 
 type Hub struct {
@@ -36,15 +40,22 @@ type Hub struct {
 	// admins get everything
 	// Also a set: each admin connection has its own channel.
 	adminClients map[chan Event]struct{}
+
+	// members finds the managers of an event's project
+	members store.ProjectMemberStore
 }
 
-func NewHub() *Hub {
+func NewHub(members store.ProjectMemberStore) *Hub {
 	return &Hub{
 		userClients:  make(map[int64]map[chan Event]struct{}),
 		adminClients: make(map[chan Event]struct{}),
+		members:      members,
 	}
 }
 
+// managerLookupTimeout bounds the managers query a Publish makes for project events.
+const managerLookupTimeout = 2 * time.Second
+
 // subscribeUser -> Normal user connected
 func (h *Hub) subscribeUser(userID int64) chan Event {
 	// Each browser/tab gets its own channel ("mailbox").
@@ -113,17 +124,37 @@ func (h *Hub) unsubscribeAdmin(ch chan Event) {
 
 // Publish sends events to:
 // - the affected user (evt.UserID)
+// - the managers of evt.ProjectID
 // - and all admin clients
 func (h *Hub) Publish(evt Event) {
+	// look the managers up before locking, so a slow query doesn't hold up subscribers;
+	// if it fails they miss this event, like a slow client would
+	recipients := []int64{evt.UserID}
+	if evt.ProjectID != 0 && h.members != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), managerLookupTimeout)
+		managers, err := h.members.ProjectManagerIDs(ctx, evt.ProjectID)
+		cancel()
+		if err == nil {
+			for _, id := range managers {
+				if id != evt.UserID {
+					recipients = append(recipients, id)
+				}
+			}
+		}
+	}
+
 	// RLock because we are only READING from the maps (not changing them).
 	// RLock allows multiple readers at once, but blocks writers (subscribe/unsubscribe)
 	// while reading is happening.
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	// Deliver to affected user (if any)
-	if evt.UserID != 0 {
-		if set := h.userClients[evt.UserID]; set != nil {
+	// Deliver to affected user and project managers (if any)
+	for _, userID := range recipients {
+		if userID == 0 {
+			continue
+		}
+		if set := h.userClients[userID]; set != nil {
 			for ch := range set {
 				// Non-blocking send:
 				// - if the client's channel has space, send the event
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/htojiddinov77-png/worktime/internal/middleware"
 	"github.com/htojiddinov77-png/worktime/internal/store"
@@ -10,10 +15,11 @@ import (
 
 type StatusHandler struct {
 	StatusStore store.StatusStore
+	logger      *log.Logger
 }
 
-func NewStatusHandler(statusStore store.StatusStore) *StatusHandler {
-	return &StatusHandler{StatusStore: statusStore}
+func NewStatusHandler(statusStore store.StatusStore, logger *log.Logger) *StatusHandler {
+	return &StatusHandler{StatusStore: statusStore, logger: logger}
 }
 
 func (h *StatusHandler) HandleGetAllStatuses(w http.ResponseWriter, r *http.Request) {
@@ -25,6 +31,7 @@ func (h *StatusHandler) HandleGetAllStatuses(w http.ResponseWriter, r *http.Requ
 
 	statuses, err := h.StatusStore.GetAllStatuses(r.Context())
 	if err != nil {
+		h.logger.Println("GetAllStatuses error:", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{
 			"error": "internal server error",
 		})
@@ -35,3 +42,187 @@ func (h *StatusHandler) HandleGetAllStatuses(w http.ResponseWriter, r *http.Requ
 		"statuses": statuses,
 	})
 }
+
+func (h *StatusHandler) HandleCreateStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		AcceptsTime *bool  `json:"accepts_time"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+
+	status := &store.Status{Name: name, AcceptsTime: true}
+	if req.AcceptsTime != nil {
+		status.AcceptsTime = *req.AcceptsTime
+	}
+
+	if err := h.StatusStore.CreateStatus(r.Context(), status); err != nil {
+		if strings.Contains(err.Error(), "statuses_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "status already exists"})
+			return
+		}
+		h.logger.Println("CreateStatus error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"status": status})
+}
+
+// HandleUpdateStatus renames a status or changes whether it accepts time. Sessions already
+// running on its projects keep running; PATCH a project with stop_active_sessions to stop them.
+func (h *StatusHandler) HandleUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		AcceptsTime *bool   `json:"accepts_time"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.Name == nil && req.AcceptsTime == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "at least one field is required: name or accepts_time"})
+		return
+	}
+
+	if req.Name != nil {
+		n := strings.TrimSpace(*req.Name)
+		if n == "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+			return
+		}
+		req.Name = &n
+	}
+
+	if err := h.StatusStore.UpdateStatus(r.Context(), id, req.Name, req.AcceptsTime); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "status not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "statuses_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "status already exists"})
+			return
+		}
+		h.logger.Println("UpdateStatus error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "status updated"})
+}
+
+// HandleDeleteStatus removes a status no project uses; its transitions go with it.
+func (h *StatusHandler) HandleDeleteStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := h.StatusStore.DeleteStatus(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "status not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "projects_status_id_fkey") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "status is used by projects"})
+			return
+		}
+		h.logger.Println("DeleteStatus error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "status deleted"})
+}
+
+// HandleSetStatusTransitions replaces the statuses a project can move to from this one.
+// An empty list lets projects move to any status.
+func (h *StatusHandler) HandleSetStatusTransitions(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	var req struct {
+		To []int64 `json:"to"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	for _, to := range req.To {
+		if to == id {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "a status can't transition to itself"})
+			return
+		}
+	}
+
+	if err := h.StatusStore.SetStatusTransitions(r.Context(), id, req.To); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "status not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "status_transitions_to_status_id_fkey") {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status not found"})
+			return
+		}
+		h.logger.Println("SetStatusTransitions error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	status, err := h.StatusStore.GetStatusbyId(id)
+	if err != nil || status == nil {
+		h.logger.Println("GetStatusbyId error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"status": status})
+}
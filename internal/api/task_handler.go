@@ -0,0 +1,160 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+type TaskHandler struct {
+	taskStore   store.TaskStore
+	memberStore store.ProjectMemberStore
+	logger      *log.Logger
+}
+
+func NewTaskHandler(taskStore store.TaskStore, memberStore store.ProjectMemberStore, logger *log.Logger) *TaskHandler {
+	return &TaskHandler{
+		taskStore:   taskStore,
+		memberStore: memberStore,
+		logger:      logger,
+	}
+}
+
+// HandleListTasks lists a project's tasks for its members and admins.
+func (th *TaskHandler) HandleListTasks(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	allowed, err := canLogOnProject(r.Context(), th.memberStore, u, projectId)
+	if err != nil {
+		th.logger.Println("IsProjectMember error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !allowed {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+		return
+	}
+
+	tasks, err := th.taskStore.ListTasks(r.Context(), projectId)
+	if err != nil {
+		th.logger.Println("ListTasks error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"tasks": tasks})
+}
+
+func (th *TaskHandler) HandleCreateTask(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	projectId, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	name, msg := readNameBody(r)
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	t := &store.Task{ProjectId: projectId, Name: name}
+	if err := th.taskStore.CreateTask(r.Context(), t); err != nil {
+		if strings.Contains(err.Error(), "tasks_project_id_fkey") {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "project not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "tasks_project_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "task already exists in this project"})
+			return
+		}
+		th.logger.Println("CreateTask error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"task": t})
+}
+
+func (th *TaskHandler) HandleRenameTask(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	name, msg := readNameBody(r)
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	if err := th.taskStore.RenameTask(r.Context(), id, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "task not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "tasks_project_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "task already exists in this project"})
+			return
+		}
+		th.logger.Println("RenameTask error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "task renamed"})
+}
+
+// HandleDeleteTask removes a task. Its sessions stay on the project, without a task.
+func (th *TaskHandler) HandleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	detached, err := th.taskStore.DeleteTask(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "task not found"})
+			return
+		}
+		th.logger.Println("DeleteTask error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"message":           "task deleted",
+		"detached_sessions": detached,
+	})
+}
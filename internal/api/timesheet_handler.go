@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/htojiddinov77-png/worktime/internal/auth"
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// upper bound for cells in one PUT: 7 days for a generous number of projects
+const maxTimesheetCells = 7 * 50
+
+type TimesheetHandler struct {
+	timesheetStore store.TimesheetStore
+	logger         *log.Logger
+	Hub            *Hub
+}
+
+func NewTimesheetHandler(timesheetStore store.TimesheetStore, logger *log.Logger, hub *Hub) *TimesheetHandler {
+	return &TimesheetHandler{
+		timesheetStore: timesheetStore,
+		logger:         logger,
+		Hub:            hub,
+	}
+}
+
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// parseWeek accepts an ISO week ("2026-W07") or any date in the week ("2026-02-11")
+// and returns local midnight of that week's Monday.
+func parseWeek(s string, loc *time.Location) (time.Time, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	if m := isoWeekPattern.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+
+		// January 4th is always in week 1
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+
+		start := monday.AddDate(0, 0, (week-1)*7)
+		if y, w := start.ISOWeek(); week < 1 || y != year || w != week {
+			return time.Time{}, fmt.Errorf("%s has no week %d", m[1], week)
+		}
+		return start, nil
+	}
+
+	d, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return time.Time{}, errors.New("week must look like 2026-W07 or 2026-02-11")
+	}
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7)), nil
+}
+
+// readTimesheetParams resolves whose timesheet is requested, and which week in which time zone.
+// Only admins may pass user_id.
+func readTimesheetParams(w http.ResponseWriter, r *http.Request, u *auth.UserClaims) (int64, time.Time, bool) {
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return 0, time.Time{}, false
+	}
+
+	weekStart, err := parseWeek(chi.URLParam(r, "week"), loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return 0, time.Time{}, false
+	}
+
+	userID := u.Id
+	if s := strings.TrimSpace(r.URL.Query().Get("user_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return 0, time.Time{}, false
+		}
+		if v != u.Id && u.Role != "admin" {
+			utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you can only access your own timesheet"})
+			return 0, time.Time{}, false
+		}
+		userID = v
+	}
+
+	return userID, weekStart, true
+}
+
+func weekLabel(weekStart time.Time) string {
+	y, wk := weekStart.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", y, wk)
+}
+
+func (th *TimesheetHandler) HandleGetTimesheet(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	userID, weekStart, ok := readTimesheetParams(w, r, u)
+	if !ok {
+		return
+	}
+
+	ts, err := th.timesheetStore.GetTimesheet(r.Context(), userID, weekStart)
+	if err != nil {
+		th.logger.Println("GetTimesheet error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"week": weekLabel(weekStart), "timesheet": ts})
+}
+
+// HandleSaveTimesheet applies edited cell totals. Cells left out of the body are not touched.
+// Either every cell is applied or none is; see store.SaveTimesheet for how cells map to sessions.
+func (th *TimesheetHandler) HandleSaveTimesheet(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	userID, weekStart, ok := readTimesheetParams(w, r, u)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Cells []store.TimesheetCellUpdate `json:"cells"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if len(req.Cells) == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "cells can't be empty"})
+		return
+	}
+	if len(req.Cells) > maxTimesheetCells {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("at most %d cells per request", maxTimesheetCells)})
+		return
+	}
+
+	type cellKey struct {
+		projectID int64
+		date      string
+	}
+	seen := map[cellKey]bool{}
+
+	for _, c := range req.Cells {
+		if c.ProjectId <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project_id must be positive"})
+			return
+		}
+		if c.Seconds < 0 || c.Seconds > int64(maxManualSession.Seconds()) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "seconds must be between 0 and 86400"})
+			return
+		}
+		k := cellKey{c.ProjectId, c.Date}
+		if seen[k] {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("cell project_id=%d date=%s sent twice", c.ProjectId, c.Date)})
+			return
+		}
+		seen[k] = true
+	}
+
+	cellErrors, err := th.timesheetStore.SaveTimesheet(r.Context(), userID, weekStart, req.Cells)
+	if err != nil {
+		th.logger.Println("SaveTimesheet error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if len(cellErrors) > 0 {
+		status := http.StatusUnprocessableEntity
+		for _, e := range cellErrors {
+			if e.Error == store.TimesheetRunningCell {
+				status = http.StatusConflict
+			}
+		}
+		utils.WriteJson(w, status, utils.Envelope{"error": "timesheet not saved", "cells": cellErrors})
+		return
+	}
+
+	ts, err := th.timesheetStore.GetTimesheet(r.Context(), userID, weekStart)
+	if err != nil {
+		th.logger.Println("GetTimesheet error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	th.Hub.Publish(Event{
+		Type:   "timesheet_updated",
+		UserID: userID,
+		Data: map[string]any{
+			"week":       weekLabel(weekStart),
+			"updated_by": u.Id,
+		},
+	})
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"week": weekLabel(weekStart), "timesheet": ts})
+}
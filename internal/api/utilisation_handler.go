@@ -0,0 +1,275 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/store"
+	"github.com/htojiddinov77-png/worktime/internal/utils"
+)
+
+// default utilisation thresholds, in percent of capacity
+const (
+	defaultUtilisationLow  = 70.0
+	defaultUtilisationHigh = 100.0
+)
+
+type UtilisationHandler struct {
+	utilisationStore store.UtilisationStore
+	logger           *log.Logger
+}
+
+func NewUtilisationHandler(utilisationStore store.UtilisationStore, logger *log.Logger) *UtilisationHandler {
+	return &UtilisationHandler{
+		utilisationStore: utilisationStore,
+		logger:           logger,
+	}
+}
+
+func (uh *UtilisationHandler) HandleListTeams(w http.ResponseWriter, r *http.Request) {
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	teams, err := uh.utilisationStore.ListTeams(r.Context())
+	if err != nil {
+		uh.logger.Println("ListTeams error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"teams": teams})
+}
+
+func (uh *UtilisationHandler) HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	t := &store.Team{Name: strings.TrimSpace(req.Name)}
+	if t.Name == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "name can't be empty"})
+		return
+	}
+
+	if err := uh.utilisationStore.CreateTeam(r.Context(), t); err != nil {
+		if strings.Contains(err.Error(), "teams_name_key") {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "team already exists"})
+			return
+		}
+		uh.logger.Println("CreateTeam error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"team": t})
+}
+
+func (uh *UtilisationHandler) HandleDeleteTeam(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid id"})
+		return
+	}
+
+	if err := uh.utilisationStore.DeleteTeam(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "team not found"})
+			return
+		}
+		uh.logger.Println("DeleteTeam error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"message": "team deleted"})
+}
+
+// HandleSetCapacity sets a user's weekly capacity and team. "team_id": null removes the user from their team.
+func (uh *UtilisationHandler) HandleSetCapacity(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	userID, err := readUserIDParam(r)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		WeeklyCapacityHours *float64 `json:"weekly_capacity_hours"`
+		TeamID              *int64   `json:"team_id"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&req); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.WeeklyCapacityHours == nil || *req.WeeklyCapacityHours < 0 || *req.WeeklyCapacityHours > 168 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "weekly_capacity_hours must be between 0 and 168"})
+		return
+	}
+
+	err = uh.utilisationStore.SetUserCapacity(r.Context(), userID, *req.WeeklyCapacityHours, req.TeamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		case strings.Contains(err.Error(), "users_team_id_fkey"):
+			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "team not found"})
+		default:
+			uh.logger.Println("SetUserCapacity error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		}
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"user_id":               userID,
+		"weekly_capacity_hours": *req.WeeklyCapacityHours,
+		"team_id":               req.TeamID,
+	})
+}
+
+// readPercentParam reads an optional percentage query param.
+func readPercentParam(r *http.Request, key string, defaultValue float64) (float64, error) {
+	s := strings.TrimSpace(r.URL.Query().Get(key))
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, errors.New("invalid " + key)
+	}
+	return v, nil
+}
+
+func (uh *UtilisationHandler) HandleGetUtilisationReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	u, ok := middleware.GetUser(r)
+	if !ok || u.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
+
+	fromStr := strings.TrimSpace(q.Get("from"))
+	toStr := strings.TrimSpace(q.Get("to"))
+
+	if fromStr == "" || toStr == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from and to are required"})
+		return
+	}
+
+	from, err := time.Parse(time.DateOnly, fromStr)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+		return
+	}
+
+	to, err := time.Parse(time.DateOnly, toStr)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+		return
+	}
+
+	if to.Before(from) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxOvertimePeriodDays-1)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period can't be longer than 366 days"})
+		return
+	}
+
+	filter := store.UtilisationFilter{
+		From:   from,
+		To:     to,
+		Period: utils.ReadString(r, "period", store.UtilisationPeriodWeek),
+	}
+
+	if filter.Period != store.UtilisationPeriodWeek && filter.Period != store.UtilisationPeriodMonth {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period must be week or month"})
+		return
+	}
+
+	filter.LowPercent, err = readPercentParam(r, "low_percent", defaultUtilisationLow)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	filter.HighPercent, err = readPercentParam(r, "high_percent", defaultUtilisationHigh)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if filter.HighPercent < filter.LowPercent {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "high_percent must not be below low_percent"})
+		return
+	}
+
+	if s := strings.TrimSpace(q.Get("user_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return
+		}
+		filter.UserID = &v
+	}
+
+	if s := strings.TrimSpace(q.Get("team_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid team_id"})
+			return
+		}
+		filter.TeamID = &v
+	}
+
+	if u.Role != "admin" {
+		// normal user: always their own figures
+		myID := u.Id
+		filter.UserID = &myID
+		filter.TeamID = nil
+	}
+
+	report, err := uh.utilisationStore.GetUtilisationReport(r.Context(), filter)
+	if err != nil {
+		uh.logger.Println("GetUtilisationReport error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"utilisation": report})
+}
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/htojiddinov77-png/worktime/internal/auth"
 	"github.com/htojiddinov77-png/worktime/internal/middleware"
 	"github.com/htojiddinov77-png/worktime/internal/store"
 	"github.com/htojiddinov77-png/worktime/internal/utils"
@@ -17,16 +20,22 @@ import (
 
 type WorkSessionHandler struct {
 	workSessionStore store.WorkSessionStore
+	memberStore      store.ProjectMemberStore
 	userStore        store.UserStore
+	absenceStore     store.AbsenceStore
+	customFieldStore store.CustomFieldStore
 	logger           *log.Logger
 	Middleware       middleware.Middleware
 	Hub *Hub
 }
 
-func NewWorkSessionHandler(workSessionStore store.WorkSessionStore,userStore store.UserStore,logger *log.Logger,middleware middleware.Middleware, hub *Hub) *WorkSessionHandler {
+func NewWorkSessionHandler(workSessionStore store.WorkSessionStore, memberStore store.ProjectMemberStore, userStore store.UserStore,absenceStore store.AbsenceStore, customFieldStore store.CustomFieldStore, logger *log.Logger,middleware middleware.Middleware, hub *Hub) *WorkSessionHandler {
 	return &WorkSessionHandler{
 		workSessionStore: workSessionStore,
+		memberStore:      memberStore,
 		userStore:        userStore,
+		absenceStore:     absenceStore,
+		customFieldStore: customFieldStore,
 		logger:           logger,
 		Middleware:       middleware,
 		Hub: hub,
@@ -54,8 +63,12 @@ func parseTimeParam(s string) (time.Time, error) {
 
 func (wh *WorkSessionHandler) HandleStartSession(w http.ResponseWriter, r *http.Request) {
 	type sessionRequest struct {
-		ProjectID int64  `json:"project_id"`
-		Note      string `json:"note"`
+		ProjectID       int64                      `json:"project_id"`
+		TaskID          *int64                     `json:"task_id"`
+		Note            string                     `json:"note"`
+		Tags            []string                   `json:"tags"`
+		OverrideAbsence bool                       `json:"override_absence"`
+		CustomFields    map[string]json.RawMessage `json:"custom_fields"`
 	}
 
 	var req sessionRequest
@@ -73,19 +86,68 @@ func (wh *WorkSessionHandler) HandleStartSession(w http.ResponseWriter, r *http.
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project_id must be positive"})
 		return
 	}
+	if req.TaskID != nil && *req.TaskID <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "task_id must be positive"})
+		return
+	}
 	req.Note = strings.TrimSpace(req.Note)
 
+	tags, msg := normalizeTags(req.Tags)
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
 	user, ok := middleware.GetUser(r)
 	if !ok || user == nil {
 		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "Unauthorized"})
 		return
 	}
-	
+
+	if !wh.requireProjectMember(w, r, user, req.ProjectID) {
+		return
+	}
+
+	custom, msg, err := readCustomValues(r.Context(), wh.customFieldStore, store.CustomFieldEntitySession, nil, req.CustomFields, true)
+	if err != nil {
+		wh.logger.Println("ListCustomFields error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if msg != "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+		return
+	}
+
+	// no tracking on a day of approved full-day leave, unless the user insists
+	if !req.OverrideAbsence {
+		loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+			return
+		}
+
+		absent, err := wh.absenceStore.HasFullDayAbsence(r.Context(), user.Id, time.Now().In(loc).Format(time.DateOnly))
+		if err != nil {
+			wh.logger.Println("HasFullDayAbsence error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if absent {
+			utils.WriteJson(w, http.StatusConflict, utils.Envelope{
+				"error": "you are on approved leave today; send override_absence=true to start anyway",
+			})
+			return
+		}
+	}
 
 	ws := &store.WorkSession{
-		UserId:    user.Id,
-		ProjectId: req.ProjectID,
-		Note:      req.Note,
+		UserId:       user.Id,
+		ProjectId:    req.ProjectID,
+		TaskId:       req.TaskID,
+		Note:         req.Note,
+		Tags:         tags,
+		CustomFields: custom,
 	}
 
 	if err := wh.workSessionStore.StartSession(r.Context(), ws); err != nil {
@@ -96,6 +158,22 @@ func (wh *WorkSessionHandler) HandleStartSession(w http.ResponseWriter, r *http.
 			})
 			return
 		}
+		if isProjectNotFound(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project not found"})
+			return
+		}
+		if isProjectArchived(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project is archived"})
+			return
+		}
+		if isProjectStatusClosed(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "project status doesn't accept time"})
+			return
+		}
+		if isTaskNotInProject(err) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "task not found in this project"})
+			return
+		}
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
@@ -103,6 +181,7 @@ func (wh *WorkSessionHandler) HandleStartSession(w http.ResponseWriter, r *http.
 	wh.Hub.Publish(Event{
     Type:   "session_started",
     UserID: ws.UserId,
+    ProjectID: ws.ProjectId,
     Data: map[string]any{
         "session_id": ws.Id,
         "user_id":    ws.UserId,
@@ -119,6 +198,123 @@ func (wh *WorkSessionHandler) HandleStartSession(w http.ResponseWriter, r *http.
 	})
 }
 
+// maxManualSession caps sessions entered by hand; a single block longer than a day is a typo.
+const maxManualSession = 24 * time.Hour
+
+const (
+	maxSessionTags   = 20
+	maxSessionTagLen = 50
+)
+
+// normalizeTags lowercases, trims and de-duplicates session tags.
+// It returns a message for the client when the tags aren't acceptable.
+func normalizeTags(tags []string) (store.Tags, string) {
+	out := store.Tags{}
+	seen := map[string]bool{}
+
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if len([]rune(t)) > maxSessionTagLen {
+			return nil, fmt.Sprintf("tags can't be longer than %d characters", maxSessionTagLen)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+
+	if len(out) > maxSessionTags {
+		return nil, fmt.Sprintf("a session can't have more than %d tags", maxSessionTags)
+	}
+	return out, ""
+}
+
+// validateManualSession checks a finished session entered by hand, such as a confirmed draft.
+// It returns a message for the client. The overlap with the user's other sessions is checked
+// by the store, in the transaction that inserts the session.
+func validateManualSession(ws *store.WorkSession) string {
+	if ws.ProjectId <= 0 {
+		return "project_id must be positive"
+	}
+	if ws.StartAt.IsZero() || ws.EndAt == nil || ws.EndAt.IsZero() {
+		return "start_at and end_at are required"
+	}
+	if !ws.EndAt.After(ws.StartAt) {
+		return "end_at must be after start_at"
+	}
+	if ws.EndAt.Sub(ws.StartAt) > maxManualSession {
+		return "session can't be longer than 24 hours"
+	}
+	if ws.EndAt.After(time.Now()) {
+		return "end_at can't be in the future"
+	}
+	return ""
+}
+
+// canLogOnProject reports whether the user may log time on the project:
+// admins on any project, everyone else on the projects they are assigned to.
+func canLogOnProject(ctx context.Context, members store.ProjectMemberStore, user *auth.UserClaims, projectID int64) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+	return members.IsProjectMember(ctx, projectID, user.Id)
+}
+
+// canManageProject reports whether the user has admin rights over the project's sessions:
+// admins on any project, managers on the projects they manage.
+func canManageProject(ctx context.Context, members store.ProjectMemberStore, user *auth.UserClaims, projectID int64) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+	return members.IsProjectManager(ctx, projectID, user.Id)
+}
+
+// managerScope returns what a non-admin manager may list and report on: their own sessions
+// and the sessions on the projects they manage. It returns nil for users who manage nothing.
+func (wh *WorkSessionHandler) managerScope(ctx context.Context, user *auth.UserClaims) (*store.SessionScope, error) {
+	managed, err := wh.memberStore.ManagedProjectIDs(ctx, user.Id)
+	if err != nil || len(managed) == 0 {
+		return nil, err
+	}
+	return &store.SessionScope{UserID: user.Id, ProjectIDs: managed}, nil
+}
+
+// requireProjectMember writes the response and returns false when the user may not log time on the project.
+func (wh *WorkSessionHandler) requireProjectMember(w http.ResponseWriter, r *http.Request, user *auth.UserClaims, projectID int64) bool {
+	ok, err := canLogOnProject(r.Context(), wh.memberStore, user, projectID)
+	if err != nil {
+		wh.logger.Println("IsProjectMember error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if !ok {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not a member of this project"})
+		return false
+	}
+	return true
+}
+
+func isProjectNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "work_sessions_project_id_fkey")
+}
+
+// isProjectArchived reports the trigger that keeps sessions off archived projects.
+func isProjectArchived(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "work_sessions_project_archived")
+}
+
+// isProjectStatusClosed reports the trigger that keeps running sessions off projects
+// whose status doesn't accept time.
+func isProjectStatusClosed(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "work_sessions_status_closed")
+}
+
+// isTaskNotInProject reports a task_id that isn't a task of the session's project.
+func isTaskNotInProject(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "work_sessions_task_fkey")
+}
+
 func (wh *WorkSessionHandler) HandleStopSession(w http.ResponseWriter, r *http.Request) {
 	sessionId, err := utils.ReadIdParam(r)
 	if err != nil || sessionId <= 0 {
@@ -132,7 +328,7 @@ func (wh *WorkSessionHandler) HandleStopSession(w http.ResponseWriter, r *http.R
 		return
 	}
 	// onwerUserID is id who owns this sessions
-	ownerUserID, endAt, err := wh.workSessionStore.StopSession(r.Context(), sessionId, user.Id)
+	ownerUserID, projectID, endAt, err := wh.workSessionStore.StopSession(r.Context(), sessionId, user.Id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "no active session"})
@@ -148,10 +344,12 @@ func (wh *WorkSessionHandler) HandleStopSession(w http.ResponseWriter, r *http.R
 		wh.Hub.Publish(Event{
 			Type:   "session_stopped",
 			UserID: ownerUserID, 
+			ProjectID: projectID,
 			Data: map[string]any{
 				"session_id": sessionId,
 				"user_id":    ownerUserID,
 				"stopped_by": user.Id, 
+				"project_id": projectID,
 				"end_at":     endAt,
 			},
 		})
@@ -180,11 +378,25 @@ func (wh *WorkSessionHandler) HandleListSessions(w http.ResponseWriter, r *http.
 
 	var filter store.WorkSessionFilter
 
+	// managers also see the sessions on the projects they manage
+	if !isAdmin {
+		scope, err := wh.managerScope(r.Context(), u)
+		if err != nil {
+			wh.logger.Println("ManagedProjectIDs error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		filter.Scope = scope
+	}
+
 	filter.Page = utils.ReadInt(r, "page", 1)
  	filter.PageSize = utils.ReadInt(r, "page_size", 50)
 
 	if s := strings.TrimSpace(q.Get("search")); s != "" {
-		filter.Search = &s
+		search := store.ParseSessionSearch(s)
+		if !search.IsEmpty() {
+			filter.Search = &search
+		}
 	}
 
 	if s := strings.TrimSpace(q.Get("active")); s != "" {
@@ -205,7 +417,58 @@ func (wh *WorkSessionHandler) HandleListSessions(w http.ResponseWriter, r *http.
 		filter.ProjectID = &v
 	}
 
-	if isAdmin {
+	if s := strings.TrimSpace(q.Get("client_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid client_id"})
+			return
+		}
+		filter.ClientID = &v
+	}
+
+	if s := strings.TrimSpace(q.Get("task_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid task_id"})
+			return
+		}
+		filter.TaskID = &v
+	}
+
+	// field.<key>=value and project_field.<key>=value match custom field values
+	for param := range q {
+		if !strings.HasPrefix(param, "field.") && !strings.HasPrefix(param, "project_field.") {
+			continue
+		}
+
+		fields, err := wh.customFieldStore.ListCustomFields(r.Context(), "")
+		if err != nil {
+			wh.logger.Println("ListCustomFields error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+
+		var sessionFields, projectFields []store.CustomField
+		for _, f := range fields {
+			if f.Entity == store.CustomFieldEntityProject {
+				projectFields = append(projectFields, f)
+			} else {
+				sessionFields = append(sessionFields, f)
+			}
+		}
+
+		var msg string
+		if filter.CustomFields, msg = readCustomFilters(q, "field.", sessionFields); msg == "" {
+			filter.ProjectCustomFields, msg = readCustomFilters(q, "project_field.", projectFields)
+		}
+		if msg != "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": msg})
+			return
+		}
+		break
+	}
+
+	if isAdmin || filter.Scope != nil {
 		if s := strings.TrimSpace(q.Get("user_id")); s != "" {
 			v, err := strconv.ParseInt(s, 10, 64)
 			if err != nil || v <= 0 {
@@ -287,6 +550,47 @@ func (wh *WorkSessionHandler) HandleGetSummaryReport(w http.ResponseWriter, r *h
 		requestedProjectID = &v
 	}
 
+	//  Optional client_id and task_id
+	var requestedClientID, requestedTaskID *int64
+	if s := strings.TrimSpace(q.Get("client_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid client_id"})
+			return
+		}
+		requestedClientID = &v
+	}
+	if s := strings.TrimSpace(q.Get("task_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid task_id"})
+			return
+		}
+		requestedTaskID = &v
+	}
+
+	// Optional rollup=client: client -> project -> task breakdown
+	rollup := false
+	switch s := strings.TrimSpace(q.Get("rollup")); s {
+	case "":
+	case "client":
+		rollup = true
+	default:
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "rollup must be client"})
+		return
+	}
+
+	// Optional status_history=true: the statuses each project had during the range
+	statusHistory := false
+	if s := strings.TrimSpace(q.Get("status_history")); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid status_history"})
+			return
+		}
+		statusHistory = v
+	}
+
 	//  Optional user_id (admin only)
 	var requestedUserID *int64
 	if s := strings.TrimSpace(q.Get("user_id")); s != "" {
@@ -298,9 +602,20 @@ func (wh *WorkSessionHandler) HandleGetSummaryReport(w http.ResponseWriter, r *h
 		requestedUserID = &v
 	}
 
+	// managers report like admins, but only over their own sessions and the projects they manage
+	var scope *store.SessionScope
+	if !isAdmin {
+		scope, err = wh.managerScope(r.Context(), authUser)
+		if err != nil {
+			wh.logger.Println("ManagedProjectIDs error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
 	// 5)  which user_id i actually allow for the report
 	var allowedUserID *int64
-	if isAdmin {
+	if isAdmin || scope != nil {
 		// admin:
 		// - if user_id is provided => report for that user
 		// - if user_id is missing  => report for all users (nil)
@@ -315,11 +630,79 @@ func (wh *WorkSessionHandler) HandleGetSummaryReport(w http.ResponseWriter, r *h
 	filter := store.SummaryRangeFilter{
 		UserID:    allowedUserID,
 		ProjectID: requestedProjectID,
+		ClientID:  requestedClientID,
+		TaskID:    requestedTaskID,
+		Scope:     scope,
 		FromDate:  fromDate,
 		ToDate:    toDate,
+		Rollup:    rollup,
+
+		StatusHistory: statusHistory,
+	}
+
+	// 7) Optional comparison range: compare_from + compare_to, or compare=previous
+	// for the same number of days right before from
+	compareFromStr := strings.TrimSpace(q.Get("compare_from"))
+	compareToStr := strings.TrimSpace(q.Get("compare_to"))
+	compare := strings.TrimSpace(q.Get("compare"))
+
+	var previous *store.SummaryRangeFilter
+	switch {
+	case compareFromStr != "" || compareToStr != "":
+		if compareFromStr == "" || compareToStr == "" {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "compare_from and compare_to go together"})
+			return
+		}
+		compareFrom, err := parseTimeParam(compareFromStr)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid compare_from"})
+			return
+		}
+		compareTo, err := parseTimeParam(compareToStr)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid compare_to"})
+			return
+		}
+		previous = &store.SummaryRangeFilter{FromDate: compareFrom, ToDate: compareTo}
+	case compare == "previous":
+		days := int(toDate.Sub(fromDate).Hours()/24) + 1
+		previous = &store.SummaryRangeFilter{
+			FromDate: fromDate.AddDate(0, 0, -days),
+			ToDate:   fromDate.AddDate(0, 0, -1),
+		}
+	case compare != "":
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "compare must be previous"})
+		return
+	}
+
+	if previous != nil && filter.Rollup {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "rollup can't be combined with a comparison"})
+		return
+	}
+	if previous != nil && filter.StatusHistory {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status_history can't be combined with a comparison"})
+		return
+	}
+
+	// 8) Fetch report, or compare it with the previous range
+	if previous != nil {
+		previous.UserID = filter.UserID
+		previous.ProjectID = filter.ProjectID
+		previous.ClientID = filter.ClientID
+		previous.TaskID = filter.TaskID
+		previous.Scope = filter.Scope
+
+		comparison, err := wh.workSessionStore.CompareSummaryReports(r.Context(), filter, *previous)
+		if err != nil {
+			wh.logger.Println("CompareSummaryReports error:", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+
+		utils.WriteJson(w, http.StatusOK, utils.Envelope{"comparison": comparison})
+		return
 	}
 
-	// 7) Fetch report
 	report, err := wh.workSessionStore.GetSummaryReport(r.Context(), filter)
 	if err != nil {
 		wh.logger.Println("GetSummaryReport error:", err)
@@ -330,6 +713,97 @@ func (wh *WorkSessionHandler) HandleGetSummaryReport(w http.ResponseWriter, r *h
 	utils.WriteJson(w, http.StatusOK, utils.Envelope{"report": report})
 }
 
+// HandleGetHeatmap returns logged minutes by weekday and hour of day (7x24), in ?tz=.
+// Normal users always get their own sessions; admins can filter by user, team or project.
+func (wh *WorkSessionHandler) HandleGetHeatmap(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
 
+	authUser, ok := middleware.GetUser(r)
+	if !ok || authUser.IsAnonymous() {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+		return
+	}
 
+	loc, err := utils.LoadTimeZone(utils.ReadString(r, "tz", "UTC"))
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz"})
+		return
+	}
 
+	fromStr := strings.TrimSpace(q.Get("from"))
+	toStr := strings.TrimSpace(q.Get("to"))
+
+	if fromStr == "" || toStr == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from and to are required"})
+		return
+	}
+
+	from, err := time.ParseInLocation(time.DateOnly, fromStr, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid from"})
+		return
+	}
+
+	to, err := time.ParseInLocation(time.DateOnly, toStr, loc)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid to"})
+		return
+	}
+
+	if to.Before(from) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must not be before from"})
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxOvertimePeriodDays-1)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "period can't be longer than 366 days"})
+		return
+	}
+
+	filter := store.HeatmapFilter{
+		From: from,
+		To:   to.AddDate(0, 0, 1),
+	}
+
+	if s := strings.TrimSpace(q.Get("project_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid project_id"})
+			return
+		}
+		filter.ProjectID = &v
+	}
+
+	if s := strings.TrimSpace(q.Get("user_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user_id"})
+			return
+		}
+		filter.UserID = &v
+	}
+
+	if s := strings.TrimSpace(q.Get("team_id")); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid team_id"})
+			return
+		}
+		filter.TeamID = &v
+	}
+
+	if authUser.Role != "admin" {
+		// normal user: force to self no matter what query says
+		myID := authUser.Id
+		filter.UserID = &myID
+		filter.TeamID = nil
+	}
+
+	heatmap, err := wh.workSessionStore.GetHeatmap(r.Context(), filter)
+	if err != nil {
+		wh.logger.Println("GetHeatmap error:", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"heatmap": heatmap})
+}
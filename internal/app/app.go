@@ -8,6 +8,7 @@ import (
 	"github.com/htojiddinov77-png/worktime/internal/api"
 	"github.com/htojiddinov77-png/worktime/internal/auth"
 	"github.com/htojiddinov77-png/worktime/internal/middleware"
+	"github.com/htojiddinov77-png/worktime/internal/notify"
 	"github.com/htojiddinov77-png/worktime/internal/store"
 )
 
@@ -15,16 +16,33 @@ type Application struct {
 	Logger *log.Logger
 	DB     *sql.DB
 
-	UserHandler        *api.UserHandler
-	WorkSessionHandler *api.WorkSessionHandler
-	TokenHandler       *api.TokenHandler
-	ProjectHandler     *api.ProjectHandler
-	StatusHandler      *api.StatusHandler
-	ResetTokenHandler  *api.ResetTokenHandler
-
-	Middleware *middleware.Middleware
-	JWT        *auth.JWTManager
-	EventHub   *api.Hub
+	UserHandler         *api.UserHandler
+	WorkSessionHandler  *api.WorkSessionHandler
+	TokenHandler        *api.TokenHandler
+	ProjectHandler      *api.ProjectHandler
+	StatusHandler       *api.StatusHandler
+	ResetTokenHandler   *api.ResetTokenHandler
+	ImportHandler       *api.ImportHandler
+	CalendarHandler     *api.CalendarHandler
+	SessionDraftHandler *api.SessionDraftHandler
+	TimesheetHandler    *api.TimesheetHandler
+	OvertimeHandler     *api.OvertimeHandler
+	AbsenceHandler      *api.AbsenceHandler
+	HolidayHandler      *api.HolidayHandler
+	UtilisationHandler  *api.UtilisationHandler
+	ReportHandler       *api.ReportHandler
+	SavedReportHandler  *api.SavedReportHandler
+	ShareHandler        *api.ShareHandler
+	ClientHandler       *api.ClientHandler
+	TaskHandler         *api.TaskHandler
+	CustomFieldHandler  *api.CustomFieldHandler
+	QuickStartHandler   *api.QuickStartHandler
+
+	Middleware      *middleware.Middleware
+	JWT             *auth.JWTManager
+	EventHub        *api.Hub
+	BudgetWatcher   *api.BudgetWatcher
+	ReportScheduler *api.ReportScheduler
 }
 
 func NewApplication() (*Application, error) {
@@ -41,18 +59,57 @@ func NewApplication() (*Application, error) {
 	projectStore := store.NewPostgresProjectStore(pgDB)
 	statusStore := store.NewPostgresStatusStore(pgDB)
 	resetTokenStore := store.NewPostgresResetTokenStore(pgDB)
+	importStore := store.NewPostgresImportStore(pgDB)
+	calendarStore := store.NewPostgresCalendarStore(pgDB)
+	sessionDraftStore := store.NewPostgresSessionDraftStore(pgDB)
+	timesheetStore := store.NewPostgresTimesheetStore(pgDB)
+	overtimeStore := store.NewPostgresOvertimeStore(pgDB)
+	absenceStore := store.NewPostgresAbsenceStore(pgDB)
+	holidayStore := store.NewPostgresHolidayStore(pgDB)
+	utilisationStore := store.NewPostgresUtilisationStore(pgDB)
+	reportStore := store.NewPostgresReportStore(pgDB)
+	savedReportStore := store.NewPostgresSavedReportStore(pgDB)
+	shareStore := store.NewPostgresShareStore(pgDB)
+	projectMemberStore := store.NewPostgresProjectMemberStore(pgDB)
+	clientStore := store.NewPostgresClientStore(pgDB)
+	taskStore := store.NewPostgresTaskStore(pgDB)
+	customFieldStore := store.NewPostgresCustomFieldStore(pgDB)
+	quickStartStore := store.NewPostgresQuickStartStore(pgDB)
 	// JWT manager (auth package)
 	jwtManager := auth.NewJWTManager()
 
-	eventHub := api.NewHub()
+	eventHub := api.NewHub(projectMemberStore)
+
+	// report delivery (smtp, file drop or none)
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		return nil, err
+	}
 
 	// Handlers
 	userHandler := api.NewUserHandler(userStore, logger, jwtManager)
-	projectHandler := api.NewProjectHandler(projectStore, userStore, logger)
-	workSessionHandler := api.NewWorkSessionHandler(workSessionStore, userStore, logger, middleware.Middleware{JWT: jwtManager},eventHub)
+	projectHandler := api.NewProjectHandler(projectStore, projectMemberStore, userStore, customFieldStore, logger, eventHub)
+	workSessionHandler := api.NewWorkSessionHandler(workSessionStore, projectMemberStore, userStore, absenceStore, customFieldStore, logger, middleware.Middleware{JWT: jwtManager},eventHub)
 	tokenHandler := api.NewTokenHandler(userStore, jwtManager, logger)
-	statusHandler := api.NewStatusHandler(statusStore)
+	statusHandler := api.NewStatusHandler(statusStore, logger)
 	resetTokenHandler := api.NewResetTokenHandler(resetTokenStore, userStore, logger)
+	importHandler := api.NewImportHandler(importStore, logger)
+	calendarHandler := api.NewCalendarHandler(calendarStore, logger)
+	sessionDraftHandler := api.NewSessionDraftHandler(sessionDraftStore, projectMemberStore, customFieldStore, logger, eventHub)
+	timesheetHandler := api.NewTimesheetHandler(timesheetStore, logger, eventHub)
+	overtimeHandler := api.NewOvertimeHandler(overtimeStore, logger)
+	absenceHandler := api.NewAbsenceHandler(absenceStore, logger, eventHub)
+	holidayHandler := api.NewHolidayHandler(holidayStore, logger)
+	utilisationHandler := api.NewUtilisationHandler(utilisationStore, logger)
+	reportHandler := api.NewReportHandler(reportStore, customFieldStore, logger)
+	reportScheduler := api.NewReportScheduler(savedReportStore, reportStore, notifier, logger)
+	savedReportHandler := api.NewSavedReportHandler(savedReportStore, customFieldStore, reportScheduler, logger)
+	shareHandler := api.NewShareHandler(shareStore, jwtManager, logger)
+	clientHandler := api.NewClientHandler(clientStore, logger)
+	taskHandler := api.NewTaskHandler(taskStore, projectMemberStore, logger)
+	customFieldHandler := api.NewCustomFieldHandler(customFieldStore, logger)
+	quickStartHandler := api.NewQuickStartHandler(quickStartStore, projectMemberStore, logger)
+	budgetWatcher := api.NewBudgetWatcher(projectStore, logger, eventHub)
 
 	// Middleware (depends on auth only)
 	mw := &middleware.Middleware{JWT: jwtManager}
@@ -61,17 +118,34 @@ func NewApplication() (*Application, error) {
 
 
 	app := &Application{
-		Logger:             logger,
-		DB:                 pgDB,
-		UserHandler:        userHandler,
-		WorkSessionHandler: workSessionHandler,
-		ProjectHandler:     projectHandler,
-		StatusHandler:      statusHandler,
-		TokenHandler:       tokenHandler,
-		ResetTokenHandler:  resetTokenHandler,
-		Middleware:         mw,
-		JWT:                jwtManager,
+		Logger:              logger,
+		DB:                  pgDB,
+		UserHandler:         userHandler,
+		WorkSessionHandler:  workSessionHandler,
+		ProjectHandler:      projectHandler,
+		StatusHandler:       statusHandler,
+		TokenHandler:        tokenHandler,
+		ResetTokenHandler:   resetTokenHandler,
+		ImportHandler:       importHandler,
+		CalendarHandler:     calendarHandler,
+		SessionDraftHandler: sessionDraftHandler,
+		TimesheetHandler:    timesheetHandler,
+		OvertimeHandler:     overtimeHandler,
+		AbsenceHandler:      absenceHandler,
+		HolidayHandler:      holidayHandler,
+		UtilisationHandler:  utilisationHandler,
+		ReportHandler:       reportHandler,
+		SavedReportHandler:  savedReportHandler,
+		ShareHandler:        shareHandler,
+		ClientHandler:       clientHandler,
+		TaskHandler:         taskHandler,
+		CustomFieldHandler:  customFieldHandler,
+		QuickStartHandler:   quickStartHandler,
+		Middleware:          mw,
+		JWT:                 jwtManager,
 		EventHub: eventHub,
+		BudgetWatcher: budgetWatcher,
+		ReportScheduler: reportScheduler,
 
 	}
 
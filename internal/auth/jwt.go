@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"os"
@@ -14,6 +15,12 @@ import (
 
 var ErrInvalidToken = errors.New("invalid token")
 
+// ErrShareExpired is returned with the claims of a correctly signed but expired share token.
+var ErrShareExpired = errors.New("share link expired")
+
+// share tokens carry their own audience, so they never pass as login tokens and vice versa
+const shareAudience = "report_share"
+
 type UserClaims struct {
 	Id     int64  `json:"user_id"`
 	Email  string `json:"email"`
@@ -21,6 +28,11 @@ type UserClaims struct {
 	Expiry int64  `json:"exp"`
 	jwt.RegisteredClaims
 }
+type ShareClaims struct {
+	ShareID int64 `json:"share_id"`
+	jwt.RegisteredClaims
+}
+
 type ResetClaims struct {
 	UserID int64  `json:"user_id"`
 	Email  string `json:"email"`
@@ -67,7 +79,7 @@ func (j *JWTManager) VerifyToken(tokenString string) (*UserClaims, error) {
 	claims := &UserClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
 		return (j.secretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -75,6 +87,9 @@ func (j *JWTManager) VerifyToken(tokenString string) (*UserClaims, error) {
 	if !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
+	if claims.Id <= 0 || slices.Contains(claims.Audience, shareAudience) {
+		return nil, ErrInvalidToken
+	}
 
 	return claims, nil
 }
@@ -122,6 +137,35 @@ func (j *JWTManager) ParseResetToken(tokenString string) (*ResetClaims, error) {
 	return claims, nil
 }
 
+// CreateShareToken signs a public report link. The same share always gets the same token.
+func (j *JWTManager) CreateShareToken(shareID int64, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, ShareClaims{
+		ShareID: shareID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{shareAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	return token.SignedString(j.secretKey)
+}
+
+func (j *JWTManager) ParseShareToken(tokenString string) (*ShareClaims, error) {
+	claims := &ShareClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return j.secretKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(shareAudience))
+
+	// the signature is checked before the expiry, so expired claims can be trusted
+	if errors.Is(err, jwt.ErrTokenExpired) && !errors.Is(err, jwt.ErrTokenInvalidAudience) && claims.ShareID > 0 {
+		return claims, ErrShareExpired
+	}
+	if err != nil || claims.ShareID <= 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
 func (j *JWTManager) ExtractBearerToken(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
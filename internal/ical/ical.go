@@ -0,0 +1,89 @@
+// Package ical reads and writes the small subset of iCalendar (RFC 5545)
+// that worktime needs: VEVENTs with a start, an end, a summary and a description.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const utcLayout = "20060102T150405Z"
+
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Description string
+	Categories  []string
+}
+
+// Write renders events as one VCALENDAR. name is shown by most calendar apps as the calendar title.
+func Write(w io.Writer, name string, events []Event) error {
+	bw := bufio.NewWriter(w)
+	now := time.Now().UTC().Format(utcLayout)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:-//worktime//worktime//EN")
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+	writeLine(bw, "X-WR-CALNAME:"+escapeText(name))
+
+	for _, e := range events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+e.UID)
+		writeLine(bw, "DTSTAMP:"+now)
+		writeLine(bw, "DTSTART:"+e.Start.UTC().Format(utcLayout))
+		writeLine(bw, "DTEND:"+e.End.UTC().Format(utcLayout))
+		writeLine(bw, "SUMMARY:"+escapeText(e.Summary))
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			cats := make([]string, len(e.Categories))
+			for i, c := range e.Categories {
+				cats[i] = escapeText(c)
+			}
+			writeLine(bw, "CATEGORIES:"+strings.Join(cats, ","))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeLine folds content lines longer than 75 octets, as the RFC requires.
+// It never splits a multi-byte rune.
+func writeLine(w *bufio.Writer, line string) {
+	const limit = 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 1 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(w, "%s\r\n", line[:cut])
+		line = " " + line[cut:]
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
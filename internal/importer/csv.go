@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/htojiddinov77-png/worktime/internal/store"
+)
+
+// ParseCSV reads a tracker export with the given preset.
+// Times without a zone are read in loc.
+// Row numbers in the returned rows and errors are 1-based file lines (header is row 1).
+func ParseCSV(r io.Reader, p Preset, loc *time.Location) ([]store.ImportSessionRow, []store.ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("csv is empty")
+		}
+		return nil, nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	cols := map[string]int{}
+	for i, h := range header {
+		h = strings.TrimPrefix(h, "\ufeff") // excel BOM
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	required := []string{p.Email, p.Project}
+	if p.Start != "" {
+		required = append(required, p.Start)
+	} else {
+		required = append(required, p.StartDate, p.StartTime)
+	}
+	for _, c := range required {
+		if _, ok := cols[c]; !ok {
+			return nil, nil, fmt.Errorf("missing column %q for preset %s", c, p.Name)
+		}
+	}
+
+	var rows []store.ImportSessionRow
+	var rowErrors []store.ImportRowError
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			rowErrors = append(rowErrors, store.ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		get := func(name string) string {
+			i, ok := cols[name]
+			if name == "" || !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue // blank line
+		}
+
+		row, err := parseRecord(get, p, loc)
+		if err != nil {
+			rowErrors = append(rowErrors, store.ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+		row.Row = line
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func parseRecord(get func(string) string, p Preset, loc *time.Location) (store.ImportSessionRow, error) {
+	row := store.ImportSessionRow{
+		UserEmail:   strings.ToLower(get(p.Email)),
+		ProjectName: get(p.Project),
+		Note:        get(p.Note),
+	}
+
+	if row.UserEmail == "" {
+		return row, errors.New("email is empty")
+	}
+	if row.ProjectName == "" {
+		return row, errors.New("project is empty")
+	}
+
+	var err error
+	if p.Start != "" {
+		row.StartAt, err = parseDateTime(get(p.Start), p.DateTimeLayouts, loc)
+	} else {
+		row.StartAt, err = parseDateAndTime(get(p.StartDate), get(p.StartTime), p, loc)
+	}
+	if err != nil {
+		return row, fmt.Errorf("invalid start: %w", err)
+	}
+
+	switch {
+	case p.End != "" && get(p.End) != "":
+		row.EndAt, err = parseDateTime(get(p.End), p.DateTimeLayouts, loc)
+	case p.EndTime != "" && get(p.EndTime) != "":
+		endDate := get(p.EndDate)
+		if endDate == "" {
+			endDate = get(p.StartDate)
+		}
+		row.EndAt, err = parseDateAndTime(endDate, get(p.EndTime), p, loc)
+	case p.Duration != "" && get(p.Duration) != "":
+		var d time.Duration
+		d, err = parseDuration(get(p.Duration))
+		row.EndAt = row.StartAt.Add(d)
+	default:
+		err = errors.New("missing")
+	}
+	if err != nil {
+		return row, fmt.Errorf("invalid end: %w", err)
+	}
+
+	if !row.EndAt.After(row.StartAt) {
+		return row, errors.New("end must be after start")
+	}
+
+	return row, nil
+}
+
+func parseDateTime(v string, layouts []string, loc *time.Location) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized datetime %q", v)
+}
+
+func parseDateAndTime(date, clock string, p Preset, loc *time.Location) (time.Time, error) {
+	for _, dl := range p.DateLayouts {
+		for _, tl := range p.TimeLayouts {
+			if t, err := time.ParseInLocation(dl+" "+tl, date+" "+clock, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date/time %q %q", date, clock)
+}
+
+// parseDuration accepts HH:MM:SS, HH:MM or decimal hours ("1.5").
+func parseDuration(v string) (time.Duration, error) {
+	if strings.Contains(v, ":") {
+		parts := strings.Split(v, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("unrecognized duration %q", v)
+		}
+		var total time.Duration
+		units := []time.Duration{time.Hour, time.Minute, time.Second}
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("unrecognized duration %q", v)
+			}
+			total += time.Duration(n) * units[i]
+		}
+		return total, nil
+	}
+
+	hours, err := strconv.ParseFloat(strings.ReplaceAll(v, ",", "."), 64)
+	if err != nil || hours < 0 {
+		return 0, fmt.Errorf("unrecognized duration %q", v)
+	}
+	return time.Duration(hours * float64(time.Hour)), nil
+}
+
+// Import hands the parsed rows to the store. Rows that failed to parse count as
+// row errors, so a real run with any bad line is validated but not committed.
+func Import(ctx context.Context, s store.ImportStore, rows []store.ImportSessionRow, rowErrors []store.ImportRowError, opts store.ImportOptions) (*store.ImportReport, error) {
+	storeOpts := opts
+	if len(rowErrors) > 0 {
+		storeOpts.DryRun = true
+	}
+
+	report, err := s.ImportSessions(ctx, rows, storeOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	report.DryRun = opts.DryRun
+	if len(rowErrors) > 0 {
+		report.TotalRows += len(rowErrors)
+		report.Errors = append(rowErrors, report.Errors...)
+		sort.SliceStable(report.Errors, func(i, j int) bool {
+			return report.Errors[i].Row < report.Errors[j].Row
+		})
+		if !opts.DryRun {
+			report.Imported = 0
+			report.Skipped = 0
+			report.CreatedProjects = []string{}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Preset maps the columns of a tracker export onto work session fields.
+// Start/end come either from one datetime column (Start, End)
+// or from separate date and time columns (StartDate+StartTime, EndDate+EndTime).
+// Duration is used when the export has no end time.
+type Preset struct {
+	Name string
+
+	Email   string
+	Project string
+	Note    string
+
+	Start string
+	End   string
+
+	StartDate string
+	StartTime string
+	EndDate   string
+	EndTime   string
+
+	Duration string
+
+	DateTimeLayouts []string
+	DateLayouts     []string
+	TimeLayouts     []string
+}
+
+var presets = map[string]Preset{
+	// our own format, also what the export of ListSessions would look like
+	"generic": {
+		Name:            "generic",
+		Email:           "email",
+		Project:         "project",
+		Note:            "note",
+		Start:           "start_at",
+		End:             "end_at",
+		DateTimeLayouts: []string{"2006-01-02T15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02 15:04"},
+	},
+	// Toggl Track "Detailed report" CSV
+	"toggl": {
+		Name:        "toggl",
+		Email:       "email",
+		Project:     "project",
+		Note:        "description",
+		StartDate:   "start date",
+		StartTime:   "start time",
+		EndDate:     "end date",
+		EndTime:     "end time",
+		Duration:    "duration",
+		DateLayouts: []string{"2006-01-02", "01/02/2006", "02.01.2006"},
+		TimeLayouts: []string{"15:04:05", "15:04", "03:04:05 PM", "03:04 PM"},
+	},
+	// Clockify "Detailed report" CSV
+	"clockify": {
+		Name:        "clockify",
+		Email:       "email",
+		Project:     "project",
+		Note:        "description",
+		StartDate:   "start date",
+		StartTime:   "start time",
+		EndDate:     "end date",
+		EndTime:     "end time",
+		Duration:    "duration (h)",
+		DateLayouts: []string{"01/02/2006", "2006-01-02", "02.01.2006"},
+		TimeLayouts: []string{"03:04:05 PM", "03:04 PM", "15:04:05", "15:04"},
+	},
+}
+
+func GetPreset(name string) (Preset, bool) {
+	p, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
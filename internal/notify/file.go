@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// FileNotifier writes the attachment into Dir, e.g. a folder synced to a shared drive.
+// Recipients are ignored. An existing file of the same name is replaced.
+type FileNotifier struct {
+	Dir string
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(n.Dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(n.Dir, filepath.Base(msg.Attachment.Filename))
+
+	// write then rename, so readers of the drop directory never see half a file
+	tmp, err := os.CreateTemp(n.Dir, ".report-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(msg.Attachment.Data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
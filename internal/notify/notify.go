@@ -0,0 +1,63 @@
+// Package notify delivers rendered reports. The SMTP notifier emails them as
+// attachments; the file notifier drops them into a directory.
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	To         []string
+	Subject    string
+	Body       string
+	Attachment Attachment
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrNoRecipients is returned by notifiers that need at least one address.
+var ErrNoRecipients = errors.New("notify: no recipients")
+
+// FromEnv picks the notifier from WORKTIME_NOTIFIER:
+//
+//	smtp - WORKTIME_SMTP_ADDR (host:port), WORKTIME_SMTP_FROM, and optionally
+//	       WORKTIME_SMTP_USERNAME / WORKTIME_SMTP_PASSWORD
+//	file - WORKTIME_REPORT_DROP_DIR
+//
+// It returns nil when no notifier is configured.
+func FromEnv() (Notifier, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("WORKTIME_NOTIFIER"))) {
+	case "":
+		return nil, nil
+	case "smtp":
+		n := &SMTPNotifier{
+			Addr:     os.Getenv("WORKTIME_SMTP_ADDR"),
+			From:     os.Getenv("WORKTIME_SMTP_FROM"),
+			Username: os.Getenv("WORKTIME_SMTP_USERNAME"),
+			Password: os.Getenv("WORKTIME_SMTP_PASSWORD"),
+		}
+		if n.Addr == "" || n.From == "" {
+			return nil, errors.New("notify: WORKTIME_SMTP_ADDR and WORKTIME_SMTP_FROM are required")
+		}
+		return n, nil
+	case "file":
+		dir := os.Getenv("WORKTIME_REPORT_DROP_DIR")
+		if dir == "" {
+			return nil, errors.New("notify: WORKTIME_REPORT_DROP_DIR is required")
+		}
+		return &FileNotifier{Dir: dir}, nil
+	default:
+		return nil, errors.New("notify: WORKTIME_NOTIFIER must be smtp or file")
+	}
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends each message as one email with the report attached.
+// Without a username it sends unauthenticated, e.g. to a local SMTP sink.
+type SMTPNotifier struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+
+	data, err := buildMail(n.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, _ := net.SplitHostPort(n.Addr)
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	// smtp.SendMail has no context; run it aside so a cancelled ctx doesn't block the caller
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.Addr, auth, n.From, msg.To, data)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// buildMail renders a multipart/mixed message: a plain text body and the attachment in base64.
+func buildMail(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	body, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := body.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+
+	if len(msg.Attachment.Data) > 0 {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {msg.Attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": msg.Attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		enc := base64.StdEncoding.EncodeToString(msg.Attachment.Data)
+		// keep lines under the 998 character limit of RFC 5322
+		for len(enc) > 76 {
+			if _, err := part.Write([]byte(enc[:76] + "\r\n")); err != nil {
+				return nil, err
+			}
+			enc = enc[76:]
+		}
+		if _, err := part.Write([]byte(enc + "\r\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -16,25 +16,135 @@ func SetUpRoutes(app *app.Application) *chi.Mux {
 			r.Post("/login/", app.TokenHandler.LoginHandler)
 			r.Post("/reset-password/{token}", app.ResetTokenHandler.HandleResetPassword)
 		})
+
+		// calendar apps can't send a JWT, the token in the URL is the credential
+		r.Get("/calendar/{token}.ics", app.CalendarHandler.HandleCalendarFeed)
+
+		// client report links, the signed token is the credential
+		r.Get("/share/{token}", app.ShareHandler.HandleViewShare)
 		
 		r.Group(func(r chi.Router) {
 			r.Use(app.Middleware.Authenticate)
 			r.Get("/events/", app.EventHub.ServeSSE)
 
+			r.Get("/calendar/tokens/", app.CalendarHandler.HandleListCalendarTokens)
+			r.Post("/calendar/tokens/", app.CalendarHandler.HandleCreateCalendarToken)
+			r.Delete("/calendar/tokens/", app.CalendarHandler.HandleRevokeCalendarToken)
+
 			r.Get("/statuses/", app.StatusHandler.HandleGetAllStatuses)
+			r.Get("/custom-fields/", app.CustomFieldHandler.HandleListCustomFields)
+
+			r.Get("/me/quick-start/", app.QuickStartHandler.HandleGetQuickStart)
+			r.Put("/me/favorites/{id}/", app.QuickStartHandler.HandleAddFavorite)
+			r.Delete("/me/favorites/{id}/", app.QuickStartHandler.HandleRemoveFavorite)
+
 			r.Get("/projects/", app.ProjectHandler.HandleListProjects)
+			r.Get("/project/{id}/", app.ProjectHandler.HandleGetProject)
 			r.Patch("/project/{id}/", app.ProjectHandler.HandleUpdateProject)
+			r.Delete("/project/{id}/", app.ProjectHandler.HandleDeleteProject)
+			r.Post("/project/{id}/archive/", app.ProjectHandler.HandleArchiveProject)
+			r.Post("/project/{id}/unarchive/", app.ProjectHandler.HandleUnarchiveProject)
+			r.Get("/project/{id}/tasks/", app.TaskHandler.HandleListTasks)
+			r.Get("/project/{id}/members/", app.ProjectHandler.HandleListProjectMembers)
+			r.Post("/project/{id}/members/", app.ProjectHandler.HandleAddProjectMember)
+			r.Delete("/project/{id}/members/{user_id}/", app.ProjectHandler.HandleRemoveProjectMember)
+			r.Patch("/project/{id}/members/{user_id}/", app.ProjectHandler.HandleSetProjectManager)
+			r.Get("/project/{id}/history/", app.ProjectHandler.HandleListProjectHistory)
+			r.Get("/project/{id}/forecast/", app.ProjectHandler.HandleGetProjectForecast)
+			r.Get("/clients/", app.ClientHandler.HandleListClients)
 
 			r.Route("/work-sessions", func(r chi.Router) {
 				r.Post("/start/", app.WorkSessionHandler.HandleStartSession)
 				r.Patch("/stop/{id}/", app.WorkSessionHandler.HandleStopSession)
 				r.Get("/list/", app.WorkSessionHandler.HandleListSessions)
 				r.Get("/reports/", app.WorkSessionHandler.HandleGetSummaryReport)
+				r.Get("/reports/heatmap/", app.WorkSessionHandler.HandleGetHeatmap)
+
+
+				r.Get("/drafts/", app.SessionDraftHandler.HandleListDrafts)
+				r.Post("/drafts/import/", app.SessionDraftHandler.HandleImportCalendar)
+				r.Post("/drafts/confirm/", app.SessionDraftHandler.HandleConfirmDrafts)
+				r.Post("/drafts/discard/", app.SessionDraftHandler.HandleDiscardDrafts)
+				r.Patch("/drafts/{id}/", app.SessionDraftHandler.HandleUpdateDraft)
+
+				r.Get("/draft-rules/", app.SessionDraftHandler.HandleListImportRules)
+				r.Post("/draft-rules/", app.SessionDraftHandler.HandleCreateImportRule)
+				r.Delete("/draft-rules/{id}/", app.SessionDraftHandler.HandleDeleteImportRule)
 			})
 
+			r.Get("/timesheets/{week}", app.TimesheetHandler.HandleGetTimesheet)
+			r.Put("/timesheets/{week}", app.TimesheetHandler.HandleSaveTimesheet)
+
+			r.Get("/overtime/", app.OvertimeHandler.HandleGetOvertimeReport)
+
+			r.Get("/absence-types/", app.AbsenceHandler.HandleListAbsenceTypes)
+			r.Get("/absences/", app.AbsenceHandler.HandleListAbsences)
+			r.Post("/absences/", app.AbsenceHandler.HandleCreateAbsence)
+			r.Post("/absences/{id}/cancel/", app.AbsenceHandler.HandleCancelAbsence)
+			r.Get("/absences/balances/", app.AbsenceHandler.HandleListBalances)
+
+			r.Get("/holiday-calendars/", app.HolidayHandler.HandleListCalendars)
+			r.Get("/holiday-calendars/{id}/holidays/", app.HolidayHandler.HandleListHolidays)
+			r.Get("/holidays/", app.HolidayHandler.HandleListUserHolidays)
+			r.Get("/offices/", app.HolidayHandler.HandleListOffices)
+
+			r.Get("/teams/", app.UtilisationHandler.HandleListTeams)
+			r.Get("/utilisation/", app.UtilisationHandler.HandleGetUtilisationReport)
+
+			r.Post("/reports/query/", app.ReportHandler.HandleRunReportQuery)
+
+			r.Get("/saved-reports/", app.SavedReportHandler.HandleListSavedReports)
+			r.Post("/saved-reports/", app.SavedReportHandler.HandleCreateSavedReport)
+			r.Get("/saved-reports/{id}/", app.SavedReportHandler.HandleGetSavedReport)
+			r.Patch("/saved-reports/{id}/", app.SavedReportHandler.HandleUpdateSavedReport)
+			r.Delete("/saved-reports/{id}/", app.SavedReportHandler.HandleDeleteSavedReport)
+			r.Post("/saved-reports/{id}/run/", app.SavedReportHandler.HandleRunSavedReport)
+			r.Get("/saved-reports/{id}/runs/", app.SavedReportHandler.HandleListReportRuns)
+			r.Get("/saved-reports/{id}/runs/{run_id}/download/", app.SavedReportHandler.HandleDownloadReportRun)
+
 			r.Patch("/users/{id}/", app.UserHandler.HandleUpdateUser)
 			r.Post("/admin/reset-tokens/", app.ResetTokenHandler.HandleGenerateResetLink)
 			r.Get("/admin/users/", app.UserHandler.HandleListUsers)
+			r.Post("/admin/imports/sessions/", app.ImportHandler.HandleImportSessions)
+			r.Get("/admin/users/{user_id}/schedules/", app.OvertimeHandler.HandleListSchedules)
+			r.Post("/admin/users/{user_id}/schedules/", app.OvertimeHandler.HandleCreateSchedule)
+			r.Delete("/admin/schedules/{id}/", app.OvertimeHandler.HandleDeleteSchedule)
+			r.Get("/admin/users/{user_id}/overtime-adjustments/", app.OvertimeHandler.HandleListAdjustments)
+			r.Post("/admin/users/{user_id}/overtime-adjustments/", app.OvertimeHandler.HandleCreateAdjustment)
+			r.Delete("/admin/overtime-adjustments/{id}/", app.OvertimeHandler.HandleDeleteAdjustment)
+			r.Post("/admin/absence-types/", app.AbsenceHandler.HandleCreateAbsenceType)
+			r.Post("/admin/absences/{id}/approve/", app.AbsenceHandler.HandleApproveAbsence)
+			r.Post("/admin/absences/{id}/reject/", app.AbsenceHandler.HandleRejectAbsence)
+			r.Put("/admin/users/{user_id}/allowances/", app.AbsenceHandler.HandleSetAllowance)
+			r.Post("/admin/holiday-calendars/", app.HolidayHandler.HandleCreateCalendar)
+			r.Delete("/admin/holiday-calendars/{id}/", app.HolidayHandler.HandleDeleteCalendar)
+			r.Post("/admin/holiday-calendars/{id}/holidays/", app.HolidayHandler.HandleCreateHoliday)
+			r.Post("/admin/holiday-calendars/{id}/import/", app.HolidayHandler.HandleImportHolidays)
+			r.Delete("/admin/holidays/{id}/", app.HolidayHandler.HandleDeleteHoliday)
+			r.Post("/admin/offices/", app.HolidayHandler.HandleCreateOffice)
+			r.Patch("/admin/offices/{id}/", app.HolidayHandler.HandleUpdateOffice)
+			r.Put("/admin/users/{user_id}/holidays/", app.HolidayHandler.HandleAssignUser)
+			r.Post("/admin/teams/", app.UtilisationHandler.HandleCreateTeam)
+			r.Delete("/admin/teams/{id}/", app.UtilisationHandler.HandleDeleteTeam)
+			r.Put("/admin/users/{user_id}/capacity/", app.UtilisationHandler.HandleSetCapacity)
+			r.Get("/admin/budget-alerts/", app.ProjectHandler.HandleListBudgetAlerts)
+			r.Get("/admin/projects/{id}/shares/", app.ShareHandler.HandleListShares)
+			r.Post("/admin/projects/{id}/shares/", app.ShareHandler.HandleCreateShare)
+			r.Delete("/admin/shares/{id}/", app.ShareHandler.HandleRevokeShare)
+			r.Get("/admin/shares/{id}/accesses/", app.ShareHandler.HandleListShareAccesses)
+			r.Post("/admin/clients/", app.ClientHandler.HandleCreateClient)
+			r.Patch("/admin/clients/{id}/", app.ClientHandler.HandleRenameClient)
+			r.Delete("/admin/clients/{id}/", app.ClientHandler.HandleDeleteClient)
+			r.Post("/admin/projects/{id}/tasks/", app.TaskHandler.HandleCreateTask)
+			r.Patch("/admin/tasks/{id}/", app.TaskHandler.HandleRenameTask)
+			r.Delete("/admin/tasks/{id}/", app.TaskHandler.HandleDeleteTask)
+			r.Post("/admin/statuses/", app.StatusHandler.HandleCreateStatus)
+			r.Patch("/admin/statuses/{id}/", app.StatusHandler.HandleUpdateStatus)
+			r.Delete("/admin/statuses/{id}/", app.StatusHandler.HandleDeleteStatus)
+			r.Put("/admin/statuses/{id}/transitions/", app.StatusHandler.HandleSetStatusTransitions)
+			r.Post("/admin/custom-fields/", app.CustomFieldHandler.HandleCreateCustomField)
+			r.Patch("/admin/custom-fields/{id}/", app.CustomFieldHandler.HandleUpdateCustomField)
+			r.Delete("/admin/custom-fields/{id}/", app.CustomFieldHandler.HandleDeleteCustomField)
 			r.Post("/projects/", app.ProjectHandler.HandleCreateProject)
 
 		})
@@ -0,0 +1,628 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	AbsencePending   = "pending"
+	AbsenceApproved  = "approved"
+	AbsenceRejected  = "rejected"
+	AbsenceCancelled = "cancelled"
+
+	AccrualUpfront = "upfront"
+	AccrualMonthly = "monthly"
+)
+
+type PostgresAbsenceStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAbsenceStore(db *sql.DB) *PostgresAbsenceStore {
+	return &PostgresAbsenceStore{db: db}
+}
+
+type AbsenceType struct {
+	Id            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Paid          bool      `json:"paid"`
+	UsesAllowance bool      `json:"uses_allowance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AbsenceRequest struct {
+	Id              int64      `json:"id"`
+	UserId          int64      `json:"user_id"`
+	UserName        string     `json:"user_name"`
+	AbsenceTypeId   int64      `json:"absence_type_id"`
+	AbsenceTypeName string     `json:"absence_type"`
+	StartDate       string     `json:"start_date"`
+	EndDate         string     `json:"end_date"`
+	HalfDay         bool       `json:"half_day"`
+	Days            float64    `json:"days"` // working days covered
+	Status          string     `json:"status"`
+	Note            string     `json:"note"`
+	DecidedBy       *int64     `json:"decided_by"`
+	DecidedAt       *time.Time `json:"decided_at"`
+	DecisionNote    string     `json:"decision_note"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type AbsenceFilter struct {
+	UserID *int64
+	Status string
+	From   string // requests ending on or after this date
+	To     string // requests starting on or before this date
+}
+
+type AbsenceAllowance struct {
+	Id               int64     `json:"id"`
+	UserId           int64     `json:"user_id"`
+	AbsenceTypeId    int64     `json:"absence_type_id"`
+	Year             int       `json:"year"`
+	Days             float64   `json:"days"`
+	Accrual          string    `json:"accrual"`
+	CarryOverMaxDays float64   `json:"carry_over_max_days"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AllowanceBalance is where a user stands with one absence type in one year.
+// Available = Accrued + CarriedOver - Used. Pending requests are not deducted yet.
+type AllowanceBalance struct {
+	AbsenceTypeId   int64   `json:"absence_type_id"`
+	AbsenceTypeName string  `json:"absence_type"`
+	Year            int     `json:"year"`
+	Days            float64 `json:"days"`
+	Accrual         string  `json:"accrual"`
+	Accrued         float64 `json:"accrued"`
+	CarriedOver     float64 `json:"carried_over"`
+	Used            float64 `json:"used"`
+	Pending         float64 `json:"pending"`
+	Available       float64 `json:"available"`
+}
+
+type AbsenceStore interface {
+	ListAbsenceTypes(ctx context.Context) ([]AbsenceType, error)
+	CreateAbsenceType(ctx context.Context, t *AbsenceType) error
+	GetAbsenceType(ctx context.Context, id int64) (*AbsenceType, error)
+
+	CreateAbsenceRequest(ctx context.Context, req *AbsenceRequest) error
+	GetAbsenceRequest(ctx context.Context, id int64) (*AbsenceRequest, error)
+	ListAbsenceRequests(ctx context.Context, filter AbsenceFilter) ([]AbsenceRequest, error)
+	DecideAbsenceRequest(ctx context.Context, id int64, status string, decidedBy int64, note string) error
+	CancelAbsenceRequest(ctx context.Context, id int64) error
+	HasOverlappingAbsence(ctx context.Context, userID int64, startDate, endDate string) (bool, error)
+	HasFullDayAbsence(ctx context.Context, userID int64, day string) (bool, error)
+	CountAbsenceDays(ctx context.Context, userID int64, startDate, endDate string, halfDay bool) (map[int]float64, error)
+
+	SetAllowance(ctx context.Context, a *AbsenceAllowance) error
+	GetAllowanceBalance(ctx context.Context, userID, absenceTypeID int64, year int) (*AllowanceBalance, error)
+	ListAllowanceBalances(ctx context.Context, userID int64, year int) ([]AllowanceBalance, error)
+}
+
+func (pg *PostgresAbsenceStore) ListAbsenceTypes(ctx context.Context) ([]AbsenceType, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT id, name, paid, uses_allowance, created_at FROM absence_types ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AbsenceType{}
+	for rows.Next() {
+		var t AbsenceType
+		if err := rows.Scan(&t.Id, &t.Name, &t.Paid, &t.UsesAllowance, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresAbsenceStore) CreateAbsenceType(ctx context.Context, t *AbsenceType) error {
+	query := `
+		INSERT INTO absence_types (name, paid, uses_allowance)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return pg.db.QueryRowContext(ctx, query, t.Name, t.Paid, t.UsesAllowance).Scan(&t.Id, &t.CreatedAt)
+}
+
+func (pg *PostgresAbsenceStore) GetAbsenceType(ctx context.Context, id int64) (*AbsenceType, error) {
+	var t AbsenceType
+	err := pg.db.QueryRowContext(ctx,
+		`SELECT id, name, paid, uses_allowance, created_at FROM absence_types WHERE id = $1`, id,
+	).Scan(&t.Id, &t.Name, &t.Paid, &t.UsesAllowance, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (pg *PostgresAbsenceStore) CreateAbsenceRequest(ctx context.Context, req *AbsenceRequest) error {
+	query := `
+		INSERT INTO absence_requests (user_id, absence_type_id, start_date, end_date, half_day, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at`
+
+	return pg.db.QueryRowContext(ctx, query,
+		req.UserId, req.AbsenceTypeId, req.StartDate, req.EndDate, req.HalfDay, req.Note,
+	).Scan(&req.Id, &req.Status, &req.CreatedAt)
+}
+
+const absenceRequestSelect = `
+	SELECT ar.id, ar.user_id, u.name, ar.absence_type_id, t.name,
+	       ar.start_date, ar.end_date, ar.half_day, ar.status, ar.note,
+	       ar.decided_by, ar.decided_at, ar.decision_note, ar.created_at
+	FROM absence_requests ar
+	JOIN users u ON u.id = ar.user_id
+	JOIN absence_types t ON t.id = ar.absence_type_id`
+
+func scanAbsenceRequest(scan func(dest ...any) error) (AbsenceRequest, error) {
+	var r AbsenceRequest
+	var start, end time.Time
+	err := scan(&r.Id, &r.UserId, &r.UserName, &r.AbsenceTypeId, &r.AbsenceTypeName,
+		&start, &end, &r.HalfDay, &r.Status, &r.Note,
+		&r.DecidedBy, &r.DecidedAt, &r.DecisionNote, &r.CreatedAt)
+	r.StartDate = start.Format(time.DateOnly)
+	r.EndDate = end.Format(time.DateOnly)
+	return r, err
+}
+
+func (pg *PostgresAbsenceStore) GetAbsenceRequest(ctx context.Context, id int64) (*AbsenceRequest, error) {
+	r, err := scanAbsenceRequest(pg.db.QueryRowContext(ctx, absenceRequestSelect+` WHERE ar.id = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pg.fillDays(ctx, []*AbsenceRequest{&r}); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (pg *PostgresAbsenceStore) ListAbsenceRequests(ctx context.Context, filter AbsenceFilter) ([]AbsenceRequest, error) {
+	var userID int64
+	if filter.UserID != nil {
+		userID = *filter.UserID
+	}
+
+	query := absenceRequestSelect + `
+		WHERE ($1 = 0 OR ar.user_id = $1)
+		  AND ($2 = '' OR ar.status = $2)
+		  AND ($3 = '' OR ar.end_date >= NULLIF($3, '')::date)
+		  AND ($4 = '' OR ar.start_date <= NULLIF($4, '')::date)
+		ORDER BY ar.start_date DESC, ar.id DESC`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, filter.Status, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AbsenceRequest{}
+	for rows.Next() {
+		r, err := scanAbsenceRequest(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*AbsenceRequest, len(out))
+	for i := range out {
+		ptrs[i] = &out[i]
+	}
+	if err := pg.fillDays(ctx, ptrs); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// fillDays sets the number of working days each request covers.
+func (pg *PostgresAbsenceStore) fillDays(ctx context.Context, reqs []*AbsenceRequest) error {
+	for _, r := range reqs {
+		start, _ := time.Parse(time.DateOnly, r.StartDate)
+		end, _ := time.Parse(time.DateOnly, r.EndDate)
+
+		isWorkingDay, err := userWorkingDays(ctx, pg.db, r.UserId, start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, days := range countAbsenceDays(isWorkingDay, r.StartDate, r.EndDate, r.HalfDay) {
+			r.Days += days
+		}
+	}
+	return nil
+}
+
+// DecideAbsenceRequest approves or rejects a pending request.
+// It returns sql.ErrNoRows when the request doesn't exist or isn't pending anymore.
+func (pg *PostgresAbsenceStore) DecideAbsenceRequest(ctx context.Context, id int64, status string, decidedBy int64, note string) error {
+	query := `
+		UPDATE absence_requests
+		SET status = $2, decided_by = $3, decided_at = NOW(), decision_note = $4
+		WHERE id = $1 AND status = 'pending'`
+
+	return execAffectingOne(ctx, pg.db, query, id, status, decidedBy, note)
+}
+
+// CancelAbsenceRequest withdraws a pending or approved request.
+func (pg *PostgresAbsenceStore) CancelAbsenceRequest(ctx context.Context, id int64) error {
+	query := `
+		UPDATE absence_requests
+		SET status = 'cancelled'
+		WHERE id = $1 AND status IN ('pending', 'approved')`
+
+	return execAffectingOne(ctx, pg.db, query, id)
+}
+
+// HasOverlappingAbsence reports whether the user already has a pending or approved
+// request touching any day of the range.
+func (pg *PostgresAbsenceStore) HasOverlappingAbsence(ctx context.Context, userID int64, startDate, endDate string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM absence_requests
+			WHERE user_id = $1
+			  AND status IN ('pending', 'approved')
+			  AND start_date <= $3::date
+			  AND end_date >= $2::date
+		)`
+
+	var exists bool
+	err := pg.db.QueryRowContext(ctx, query, userID, startDate, endDate).Scan(&exists)
+	return exists, err
+}
+
+// HasFullDayAbsence reports whether the user is on approved, full-day leave on day.
+func (pg *PostgresAbsenceStore) HasFullDayAbsence(ctx context.Context, userID int64, day string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM absence_requests
+			WHERE user_id = $1
+			  AND status = 'approved'
+			  AND NOT half_day
+			  AND start_date <= $2::date
+			  AND end_date >= $2::date
+		)`
+
+	var exists bool
+	err := pg.db.QueryRowContext(ctx, query, userID, day).Scan(&exists)
+	return exists, err
+}
+
+// CountAbsenceDays returns the working days a request would cover, per calendar year.
+func (pg *PostgresAbsenceStore) CountAbsenceDays(ctx context.Context, userID int64, startDate, endDate string, halfDay bool) (map[int]float64, error) {
+	start, err := time.Parse(time.DateOnly, startDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(time.DateOnly, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	isWorkingDay, err := userWorkingDays(ctx, pg.db, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return countAbsenceDays(isWorkingDay, startDate, endDate, halfDay), nil
+}
+
+// workingDayFunc tells whether a user works on a given day. Holidays never are;
+// otherwise, with schedules, a day on which the schedule expects time,
+// and without any schedule, Monday to Friday.
+func workingDayFunc(schedules []WorkSchedule, holidays map[string]bool) func(day time.Time) bool {
+	expected := scheduleExpectation(schedules)
+
+	return func(day time.Time) bool {
+		if holidays[day.Format(time.DateOnly)] {
+			return false
+		}
+		if len(schedules) == 0 {
+			return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+		}
+		return expected(day) > 0
+	}
+}
+
+// userWorkingDays loads the user's schedules and their holidays in [from, to].
+func userWorkingDays(ctx context.Context, db *sql.DB, userID int64, from, to time.Time) (func(day time.Time) bool, error) {
+	schedules, err := listSchedules(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := userHolidayDates(ctx, db, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return workingDayFunc(schedules, holidays), nil
+}
+
+// countWorkingDays counts the working days in [from, to].
+func countWorkingDays(isWorkingDay func(time.Time) bool, from, to time.Time) int {
+	n := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if isWorkingDay(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// countAbsenceDays counts the working days in [startDate, endDate], per year.
+func countAbsenceDays(isWorkingDay func(time.Time) bool, startDate, endDate string, halfDay bool) map[int]float64 {
+	out := map[int]float64{}
+
+	start, err1 := time.Parse(time.DateOnly, startDate)
+	end, err2 := time.Parse(time.DateOnly, endDate)
+	if err1 != nil || err2 != nil {
+		return out
+	}
+
+	perDay := 1.0
+	if halfDay {
+		perDay = 0.5
+	}
+
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if isWorkingDay(d) {
+			out[d.Year()] += perDay
+		}
+	}
+	return out
+}
+
+// approvedAbsences returns, per day in [from, to), the share of the day the user
+// is on approved leave: 1 for a full day, 0.5 for a half day.
+func approvedAbsences(ctx context.Context, db *sql.DB, userID int64, from, to time.Time) (map[string]float64, error) {
+	query := `
+		SELECT start_date, end_date, half_day
+		FROM absence_requests
+		WHERE user_id = $1
+		  AND status = 'approved'
+		  AND start_date < $3::date
+		  AND end_date >= $2::date`
+
+	rows, err := db.QueryContext(ctx, query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]float64{}
+	for rows.Next() {
+		var start, end time.Time
+		var halfDay bool
+		if err := rows.Scan(&start, &end, &halfDay); err != nil {
+			return nil, err
+		}
+
+		share := 1.0
+		if halfDay {
+			share = 0.5
+		}
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			key := d.Format(time.DateOnly)
+			out[key] = math.Min(1, out[key]+share)
+		}
+	}
+
+	return out, rows.Err()
+}
+
+// SetAllowance creates or replaces the user's allowance for a type and year.
+func (pg *PostgresAbsenceStore) SetAllowance(ctx context.Context, a *AbsenceAllowance) error {
+	query := `
+		INSERT INTO absence_allowances (user_id, absence_type_id, year, days, accrual, carry_over_max_days)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, absence_type_id, year) DO UPDATE
+		SET days = EXCLUDED.days,
+		    accrual = EXCLUDED.accrual,
+		    carry_over_max_days = EXCLUDED.carry_over_max_days
+		RETURNING id, created_at`
+
+	return pg.db.QueryRowContext(ctx, query,
+		a.UserId, a.AbsenceTypeId, a.Year, a.Days, a.Accrual, a.CarryOverMaxDays,
+	).Scan(&a.Id, &a.CreatedAt)
+}
+
+func (pg *PostgresAbsenceStore) ListAllowanceBalances(ctx context.Context, userID int64, year int) ([]AllowanceBalance, error) {
+	rows, err := pg.db.QueryContext(ctx,
+		`SELECT absence_type_id FROM absence_allowances WHERE user_id = $1 AND year = $2 ORDER BY absence_type_id`,
+		userID, year,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		typeIDs = append(typeIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := []AllowanceBalance{}
+	for _, typeID := range typeIDs {
+		b, err := pg.GetAllowanceBalance(ctx, userID, typeID, year)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			out = append(out, *b)
+		}
+	}
+	return out, nil
+}
+
+// GetAllowanceBalance works out a year's balance for one absence type.
+// Unused days of a year carry into the next one, capped by that year's
+// carry_over_max_days; a year without an allowance breaks the chain.
+// It returns nil when the user has no allowance for the year.
+func (pg *PostgresAbsenceStore) GetAllowanceBalance(ctx context.Context, userID, absenceTypeID int64, year int) (*AllowanceBalance, error) {
+	query := `
+		SELECT a.year, a.days, a.accrual, a.carry_over_max_days, t.name
+		FROM absence_allowances a
+		JOIN absence_types t ON t.id = a.absence_type_id
+		WHERE a.user_id = $1 AND a.absence_type_id = $2 AND a.year <= $3
+		ORDER BY a.year`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, absenceTypeID, year)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowances []AbsenceAllowance
+	var typeName string
+	for rows.Next() {
+		var a AbsenceAllowance
+		if err := rows.Scan(&a.Year, &a.Days, &a.Accrual, &a.CarryOverMaxDays, &typeName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		allowances = append(allowances, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(allowances) == 0 || allowances[len(allowances)-1].Year != year {
+		return nil, nil
+	}
+
+	first := allowances[0].Year
+	used, pending, err := pg.usedDaysByYear(ctx, userID, absenceTypeID, first, year)
+	if err != nil {
+		return nil, err
+	}
+
+	carry := 0.0
+	for i, a := range allowances {
+		if i > 0 && allowances[i-1].Year != a.Year-1 {
+			carry = 0
+		}
+		if a.Year == year {
+			break
+		}
+		remaining := a.Days + carry - used[a.Year]
+		carry = math.Min(a.CarryOverMaxDays, math.Max(0, remaining))
+	}
+
+	current := allowances[len(allowances)-1]
+	b := &AllowanceBalance{
+		AbsenceTypeId:   absenceTypeID,
+		AbsenceTypeName: typeName,
+		Year:            year,
+		Days:            current.Days,
+		Accrual:         current.Accrual,
+		Accrued:         accruedDays(current, time.Now()),
+		CarriedOver:     carry,
+		Used:            used[year],
+		Pending:         pending[year],
+	}
+	b.Available = b.Accrued + b.CarriedOver - b.Used
+
+	return b, nil
+}
+
+// accruedDays is how much of a year's allowance is available at `now`.
+// Monthly accrual unlocks 1/12 at the start of each month, rounded down to half days.
+func accruedDays(a AbsenceAllowance, now time.Time) float64 {
+	if a.Accrual != AccrualMonthly {
+		return a.Days
+	}
+
+	months := 12
+	switch {
+	case now.Year() < a.Year:
+		months = 0
+	case now.Year() == a.Year:
+		months = int(now.Month())
+	}
+
+	return math.Floor(a.Days*float64(months)/12*2) / 2
+}
+
+// usedDaysByYear sums approved and pending days of one absence type per calendar year.
+func (pg *PostgresAbsenceStore) usedDaysByYear(ctx context.Context, userID, absenceTypeID int64, fromYear, toYear int) (map[int]float64, map[int]float64, error) {
+	query := `
+		SELECT start_date, end_date, half_day, status
+		FROM absence_requests
+		WHERE user_id = $1
+		  AND absence_type_id = $2
+		  AND status IN ('pending', 'approved')
+		  AND end_date >= $3::date
+		  AND start_date <= $4::date`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, absenceTypeID,
+		fmt.Sprintf("%d-01-01", fromYear), fmt.Sprintf("%d-12-31", toYear))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	type span struct {
+		start, end string
+		halfDay    bool
+		status     string
+	}
+	var spans []span
+	for rows.Next() {
+		var start, end time.Time
+		var s span
+		if err := rows.Scan(&start, &end, &s.halfDay, &s.status); err != nil {
+			return nil, nil, err
+		}
+		s.start, s.end = start.Format(time.DateOnly), end.Format(time.DateOnly)
+		spans = append(spans, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	isWorkingDay, err := userWorkingDays(ctx, pg.db, userID,
+		time.Date(fromYear, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(toYear, time.December, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used, pending := map[int]float64{}, map[int]float64{}
+	for _, s := range spans {
+		target := used
+		if s.status == AbsencePending {
+			target = pending
+		}
+		for y, days := range countAbsenceDays(isWorkingDay, s.start, s.end, s.halfDay) {
+			target[y] += days
+		}
+	}
+	return used, pending, nil
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	CalendarScopeUser = "user"
+	CalendarScopeTeam = "team"
+)
+
+type PostgresCalendarStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCalendarStore(db *sql.DB) *PostgresCalendarStore {
+	return &PostgresCalendarStore{db: db}
+}
+
+type CalendarToken struct {
+	Id        int64     `json:"id"`
+	UserId    int64     `json:"user_id"`
+	Scope     string    `json:"scope"`
+	TokenHash []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// owner's current role, filled by GetCalendarTokenByHash
+	UserRole string `json:"-"`
+}
+
+type CalendarSessionFilter struct {
+	UserID    *int64
+	ProjectID *int64
+	Since     time.Time
+}
+
+type CalendarSession struct {
+	SessionId   int64
+	UserName    string
+	ProjectName string
+	StartAt     time.Time
+	EndAt       *time.Time
+	Note        string
+}
+
+type CalendarStore interface {
+	CreateCalendarToken(ctx context.Context, token *CalendarToken) error
+	RevokeCalendarToken(ctx context.Context, userID int64, scope string) error
+	ListCalendarTokens(ctx context.Context, userID int64) ([]CalendarToken, error)
+	GetCalendarTokenByHash(ctx context.Context, tokenHash []byte) (*CalendarToken, error)
+	ListCalendarSessions(ctx context.Context, filter CalendarSessionFilter) ([]CalendarSession, error)
+}
+
+// CreateCalendarToken revokes the user's live token for the same scope and stores the new one.
+func (pg *PostgresCalendarStore) CreateCalendarToken(ctx context.Context, token *CalendarToken) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE calendar_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND scope = $2 AND revoked_at IS NULL`,
+		token.UserId, token.Scope,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO calendar_tokens (user_id, scope, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		token.UserId, token.Scope, token.TokenHash,
+	).Scan(&token.Id, &token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresCalendarStore) RevokeCalendarToken(ctx context.Context, userID int64, scope string) error {
+	query := `
+		UPDATE calendar_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND scope = $2 AND revoked_at IS NULL`
+
+	res, err := pg.db.ExecContext(ctx, query, userID, scope)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresCalendarStore) ListCalendarTokens(ctx context.Context, userID int64) ([]CalendarToken, error) {
+	query := `
+		SELECT id, user_id, scope, created_at
+		FROM calendar_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY scope`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []CalendarToken{}
+	for rows.Next() {
+		var t CalendarToken
+		if err := rows.Scan(&t.Id, &t.UserId, &t.Scope, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+// GetCalendarTokenByHash returns nil when the token doesn't exist, is revoked,
+// or belongs to a user who was deactivated.
+func (pg *PostgresCalendarStore) GetCalendarTokenByHash(ctx context.Context, tokenHash []byte) (*CalendarToken, error) {
+	query := `
+		SELECT ct.id, ct.user_id, ct.scope, ct.created_at, u.role
+		FROM calendar_tokens ct
+		JOIN users u ON u.id = ct.user_id
+		WHERE ct.token_hash = $1
+		  AND ct.revoked_at IS NULL
+		  AND u.is_active = TRUE`
+
+	t := &CalendarToken{}
+	err := pg.db.QueryRowContext(ctx, query, tokenHash).Scan(&t.Id, &t.UserId, &t.Scope, &t.CreatedAt, &t.UserRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (pg *PostgresCalendarStore) ListCalendarSessions(ctx context.Context, filter CalendarSessionFilter) ([]CalendarSession, error) {
+	userID := int64(0)
+	if filter.UserID != nil {
+		userID = *filter.UserID
+	}
+
+	projectID := int64(0)
+	if filter.ProjectID != nil {
+		projectID = *filter.ProjectID
+	}
+
+	query := `
+		SELECT ws.id, u.name, COALESCE(p.name, ''), ws.start_at, ws.end_at, COALESCE(ws.note, '')
+		FROM work_sessions ws
+		JOIN users u ON u.id = ws.user_id
+		LEFT JOIN projects p ON p.id = ws.project_id
+		WHERE ws.start_at >= $1
+		  AND ($2 = 0 OR ws.user_id = $2)
+		  AND ($3 = 0 OR ws.project_id = $3)
+		ORDER BY ws.start_at`
+
+	rows, err := pg.db.QueryContext(ctx, query, filter.Since, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []CalendarSession
+	for rows.Next() {
+		var s CalendarSession
+		if err := rows.Scan(&s.SessionId, &s.UserName, &s.ProjectName, &s.StartAt, &s.EndAt, &s.Note); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
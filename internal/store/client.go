@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Client struct {
+	Id           int64     `json:"id"`
+	Name         string    `json:"name"`
+	ProjectCount int64     `json:"project_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ClientRef is a client as shown on a project.
+type ClientRef struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type ClientStore interface {
+	ListClients(ctx context.Context) ([]Client, error)
+	CreateClient(ctx context.Context, client *Client) error
+	RenameClient(ctx context.Context, id int64, name string) error
+	DeleteClient(ctx context.Context, id int64) error
+}
+
+type PostgresClientStore struct {
+	db *sql.DB
+}
+
+func NewPostgresClientStore(db *sql.DB) *PostgresClientStore {
+	return &PostgresClientStore{db: db}
+}
+
+func (pg *PostgresClientStore) ListClients(ctx context.Context) ([]Client, error) {
+	query := `
+		SELECT c.id, c.name, COUNT(p.id), c.created_at
+		FROM clients c
+		LEFT JOIN projects p ON p.client_id = c.id
+		GROUP BY c.id
+		ORDER BY LOWER(c.name), c.id`
+
+	rows, err := pg.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Client{}
+	for rows.Next() {
+		var c Client
+		if err := rows.Scan(&c.Id, &c.Name, &c.ProjectCount, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresClientStore) CreateClient(ctx context.Context, client *Client) error {
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO clients (name) VALUES ($1) RETURNING id, created_at`,
+		client.Name,
+	).Scan(&client.Id, &client.CreatedAt)
+}
+
+// RenameClient returns sql.ErrNoRows when the client doesn't exist.
+func (pg *PostgresClientStore) RenameClient(ctx context.Context, id int64, name string) error {
+	return execAffectingOne(ctx, pg.db, `UPDATE clients SET name = $2 WHERE id = $1`, id, name)
+}
+
+// DeleteClient deletes a client; its projects stay, without a client.
+// It returns sql.ErrNoRows when the client doesn't exist.
+func (pg *PostgresClientStore) DeleteClient(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM clients WHERE id = $1`, id)
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	CustomFieldEntityProject = "project"
+	CustomFieldEntitySession = "session"
+
+	CustomFieldText   = "text"
+	CustomFieldNumber = "number"
+	CustomFieldEnum   = "enum"
+	CustomFieldDate   = "date"
+)
+
+// customFieldKey is the format of field keys. Keys are safe to put in SQL as literals,
+// which is how reports group by them.
+var customFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ValidCustomFieldKey reports whether key can name a custom field.
+func ValidCustomFieldKey(key string) bool {
+	return customFieldKey.MatchString(key)
+}
+
+// CustomField is an admin-defined field of projects or sessions. Options are the
+// allowed values of an enum field.
+type CustomField struct {
+	Id        int64     `json:"id"`
+	Entity    string    `json:"entity"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options"`
+	Required  bool      `json:"required"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomValues are the custom field values of a project or session by key,
+// read from a JSONB column.
+type CustomValues map[string]any
+
+func (v *CustomValues) Scan(src any) error {
+	var b []byte
+	switch s := src.(type) {
+	case nil:
+		*v = CustomValues{}
+		return nil
+	case []byte:
+		b = s
+	case string:
+		b = []byte(s)
+	default:
+		return fmt.Errorf("custom fields: unsupported type %T", src)
+	}
+
+	out := CustomValues{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	*v = out
+	return nil
+}
+
+// customValuesArg never passes NULL for the NOT NULL custom_fields columns.
+func customValuesArg(v CustomValues) ([]byte, error) {
+	if v == nil {
+		return []byte(`{}`), nil
+	}
+	return json.Marshal(v)
+}
+
+// requiredSessionField returns the key of a required session field, or "" when there is none.
+// Timesheet cells and imports can't set custom values, so they create no sessions while one exists.
+func requiredSessionField(ctx context.Context, q queryer) (string, error) {
+	var key string
+	err := q.QueryRowContext(ctx, `
+		SELECT key FROM custom_fields
+		WHERE entity = $1 AND required
+		ORDER BY key
+		LIMIT 1`, CustomFieldEntitySession).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return key, err
+}
+
+type CustomFieldStore interface {
+	ListCustomFields(ctx context.Context, entity string) ([]CustomField, error)
+	GetCustomField(ctx context.Context, id int64) (*CustomField, error)
+	CreateCustomField(ctx context.Context, field *CustomField) error
+	UpdateCustomField(ctx context.Context, id int64, name *string, options []string, required *bool) error
+	DeleteCustomField(ctx context.Context, id int64) error
+}
+
+type PostgresCustomFieldStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCustomFieldStore(db *sql.DB) *PostgresCustomFieldStore {
+	return &PostgresCustomFieldStore{db: db}
+}
+
+const customFieldColumns = `id, entity, key, name, type, to_json(options), required, created_at`
+
+func scanCustomField(scan func(dest ...any) error) (CustomField, error) {
+	var f CustomField
+	var options Tags
+	err := scan(&f.Id, &f.Entity, &f.Key, &f.Name, &f.Type, &options, &f.Required, &f.CreatedAt)
+	f.Options = options
+	return f, err
+}
+
+// ListCustomFields returns the fields of an entity, or of both when entity is empty.
+func (pg *PostgresCustomFieldStore) ListCustomFields(ctx context.Context, entity string) ([]CustomField, error) {
+	query := `
+		SELECT ` + customFieldColumns + `
+		FROM custom_fields
+		WHERE ($1 = '' OR entity = $1)
+		ORDER BY entity, key`
+
+	rows, err := pg.db.QueryContext(ctx, query, entity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []CustomField{}
+	for rows.Next() {
+		f, err := scanCustomField(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+
+	return out, rows.Err()
+}
+
+// GetCustomField returns a field, or nil if it doesn't exist.
+func (pg *PostgresCustomFieldStore) GetCustomField(ctx context.Context, id int64) (*CustomField, error) {
+	row := pg.db.QueryRowContext(ctx, `SELECT `+customFieldColumns+` FROM custom_fields WHERE id = $1`, id)
+	f, err := scanCustomField(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (pg *PostgresCustomFieldStore) CreateCustomField(ctx context.Context, field *CustomField) error {
+	if field.Options == nil {
+		field.Options = []string{}
+	}
+	return pg.db.QueryRowContext(ctx, `
+		INSERT INTO custom_fields (entity, key, name, type, options, required)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		field.Entity, field.Key, field.Name, field.Type, field.Options, field.Required,
+	).Scan(&field.Id, &field.CreatedAt)
+}
+
+// UpdateCustomField changes the fields that are set; nil options keep the enum's options.
+// Values already stored are kept. It returns sql.ErrNoRows when the field doesn't exist.
+func (pg *PostgresCustomFieldStore) UpdateCustomField(ctx context.Context, id int64, name *string, options []string, required *bool) error {
+	return execAffectingOne(ctx, pg.db, `
+		UPDATE custom_fields
+		SET name = COALESCE($2, name),
+		    options = COALESCE($3, options),
+		    required = COALESCE($4, required)
+		WHERE id = $1`,
+		id, name, options, required)
+}
+
+// DeleteCustomField deletes a field and removes its values from the projects or sessions.
+// It returns sql.ErrNoRows when the field doesn't exist.
+func (pg *PostgresCustomFieldStore) DeleteCustomField(ctx context.Context, id int64) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var entity, key string
+	err = tx.QueryRowContext(ctx, `DELETE FROM custom_fields WHERE id = $1 RETURNING entity, key`, id).Scan(&entity, &key)
+	if err != nil {
+		return err
+	}
+
+	table := "work_sessions"
+	if entity == CustomFieldEntityProject {
+		table = "projects"
+	}
+	query := fmt.Sprintf(`UPDATE %s SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text`, table)
+	if _, err := tx.ExecContext(ctx, query, key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -18,10 +18,10 @@ func (f *Filter) Offset() int {
 }
 
 func (f *Filter) Validate() error {
-	if f.Page < 0 || f.Page > 10_000_000 {
+	if f.Page < 1 || f.Page > 10_000_000 {
 		return errors.New("page must be between 1 and ten million")
 	}
-	if f.PageSize < 0 || f.PageSize > 1000 {
+	if f.PageSize < 1 || f.PageSize > 1000 {
 		return errors.New("page size must be between 1 and 1000")
 	}
 
@@ -60,10 +60,14 @@ type Metadata struct {
 	TotalRecords int `json:"total_records"`
 }
 
+// CalculateMetadata describes a page of totalRecords; a zero pageSize means they all fit on one page.
 func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
 	if totalRecords == 0 {
 		return Metadata{}
 	}
+	if pageSize <= 0 {
+		pageSize = totalRecords
+	}
 
 	return Metadata{
 		CurrentPage:  page,
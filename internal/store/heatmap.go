@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// HeatmapWeekdays labels the rows of Heatmap.Minutes.
+var HeatmapWeekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+type HeatmapFilter struct {
+	UserID    *int64
+	TeamID    *int64
+	ProjectID *int64
+	From      time.Time // local midnight of the first day
+	To        time.Time // local midnight after the last day
+}
+
+// Heatmap holds logged minutes by weekday (Monday first) and hour of day,
+// in the time zone of the filter's dates.
+type Heatmap struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TimeZone string `json:"tz"`
+
+	Filters struct {
+		UserID    *int64 `json:"user_id"`
+		TeamID    *int64 `json:"team_id"`
+		ProjectID *int64 `json:"project_id"`
+	} `json:"filters"`
+
+	Weekdays      []string   `json:"weekdays"`
+	Minutes       [7][24]int `json:"minutes"`
+	WeekdayTotals [7]int     `json:"weekday_totals"`
+	HourTotals    [24]int    `json:"hour_totals"`
+	TotalMinutes  int        `json:"total_minutes"`
+}
+
+// addHeatmapSession spreads [start, end) over the hour cells it touches, in seconds.
+func addHeatmapSession(cells *[7][24]float64, start, end time.Time, loc *time.Location) {
+	start, end = start.In(loc), end.In(loc)
+
+	for t := start; t.Before(end); {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		// on a DST fall-back the same wall hour repeats; always move forward
+		if !next.After(t) {
+			next = t.Truncate(time.Hour).Add(time.Hour)
+		}
+		if next.After(end) {
+			next = end
+		}
+
+		cells[isoWeekday(t)-1][t.Hour()] += next.Sub(t).Seconds()
+		t = next
+	}
+}
+
+// GetHeatmap returns the minutes logged in [From, To) by weekday and hour.
+// Sessions are clipped to the range and split at hour boundaries; running sessions count up to now.
+func (pg *PostgresWorkSessionStore) GetHeatmap(ctx context.Context, filter HeatmapFilter) (*Heatmap, error) {
+	loc := filter.From.Location()
+
+	query := `
+		SELECT ws.start_at, COALESCE(ws.end_at, NOW())
+		FROM work_sessions ws
+		JOIN users u ON u.id = ws.user_id
+		WHERE ws.start_at < $2
+		  AND COALESCE(ws.end_at, NOW()) > $1
+		  AND ($3::bigint IS NULL OR ws.user_id = $3)
+		  AND ($4::bigint IS NULL OR u.team_id = $4)
+		  AND ($5::bigint IS NULL OR ws.project_id = $5)`
+
+	rows, err := pg.db.QueryContext(ctx, query, filter.From, filter.To, filter.UserID, filter.TeamID, filter.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cells [7][24]float64
+	for rows.Next() {
+		var start, end time.Time
+		if err := rows.Scan(&start, &end); err != nil {
+			return nil, err
+		}
+
+		if start.Before(filter.From) {
+			start = filter.From
+		}
+		if end.After(filter.To) {
+			end = filter.To
+		}
+		addHeatmapSession(&cells, start, end, loc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	h := &Heatmap{
+		From:     filter.From.Format(time.DateOnly),
+		To:       filter.To.AddDate(0, 0, -1).Format(time.DateOnly),
+		TimeZone: loc.String(),
+		Weekdays: HeatmapWeekdays,
+	}
+	h.Filters.UserID = filter.UserID
+	h.Filters.TeamID = filter.TeamID
+	h.Filters.ProjectID = filter.ProjectID
+
+	for d := range cells {
+		for hour := range cells[d] {
+			m := int(math.Round(cells[d][hour] / 60))
+			h.Minutes[d][hour] = m
+			h.WeekdayTotals[d] += m
+			h.HourTotals[hour] += m
+			h.TotalMinutes += m
+		}
+	}
+
+	return h, nil
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PostgresHolidayStore struct {
+	db *sql.DB
+}
+
+func NewPostgresHolidayStore(db *sql.DB) *PostgresHolidayStore {
+	return &PostgresHolidayStore{db: db}
+}
+
+type HolidayCalendar struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Holiday struct {
+	Id         int64     `json:"id"`
+	CalendarId int64     `json:"calendar_id"`
+	Date       string    `json:"date"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Office struct {
+	Id                int64     `json:"id"`
+	Name              string    `json:"name"`
+	HolidayCalendarId *int64    `json:"holiday_calendar_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type HolidayStore interface {
+	ListCalendars(ctx context.Context) ([]HolidayCalendar, error)
+	CreateCalendar(ctx context.Context, c *HolidayCalendar) error
+	DeleteCalendar(ctx context.Context, id int64) error
+
+	ListHolidays(ctx context.Context, calendarID int64, from, to string) ([]Holiday, error)
+	CreateHoliday(ctx context.Context, h *Holiday) error
+	DeleteHoliday(ctx context.Context, id int64) error
+	ImportHolidays(ctx context.Context, calendarID int64, holidays []Holiday) (int, error)
+	ListUserHolidays(ctx context.Context, userID int64, from, to string) ([]Holiday, error)
+
+	ListOffices(ctx context.Context) ([]Office, error)
+	CreateOffice(ctx context.Context, o *Office) error
+	UpdateOffice(ctx context.Context, o *Office) error
+	AssignUser(ctx context.Context, userID int64, officeID, calendarID *int64) error
+}
+
+func (pg *PostgresHolidayStore) ListCalendars(ctx context.Context) ([]HolidayCalendar, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT id, name, created_at FROM holiday_calendars ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []HolidayCalendar{}
+	for rows.Next() {
+		var c HolidayCalendar
+		if err := rows.Scan(&c.Id, &c.Name, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresHolidayStore) CreateCalendar(ctx context.Context, c *HolidayCalendar) error {
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO holiday_calendars (name) VALUES ($1) RETURNING id, created_at`, c.Name,
+	).Scan(&c.Id, &c.CreatedAt)
+}
+
+func (pg *PostgresHolidayStore) DeleteCalendar(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM holiday_calendars WHERE id = $1`, id)
+}
+
+func scanHolidays(rows *sql.Rows) ([]Holiday, error) {
+	defer rows.Close()
+
+	out := []Holiday{}
+	for rows.Next() {
+		var h Holiday
+		var date time.Time
+		if err := rows.Scan(&h.Id, &h.CalendarId, &date, &h.Name, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		h.Date = date.Format(time.DateOnly)
+		out = append(out, h)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresHolidayStore) ListHolidays(ctx context.Context, calendarID int64, from, to string) ([]Holiday, error) {
+	query := `
+		SELECT id, calendar_id, date, name, created_at
+		FROM holidays
+		WHERE calendar_id = $1 AND date >= $2::date AND date <= $3::date
+		ORDER BY date`
+
+	rows, err := pg.db.QueryContext(ctx, query, calendarID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanHolidays(rows)
+}
+
+func (pg *PostgresHolidayStore) CreateHoliday(ctx context.Context, h *Holiday) error {
+	query := `
+		INSERT INTO holidays (calendar_id, date, name)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return pg.db.QueryRowContext(ctx, query, h.CalendarId, h.Date, h.Name).Scan(&h.Id, &h.CreatedAt)
+}
+
+func (pg *PostgresHolidayStore) DeleteHoliday(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM holidays WHERE id = $1`, id)
+}
+
+// ImportHolidays adds holidays to a calendar in one transaction and returns how many were new.
+// A day that already has a holiday keeps it, with the imported name.
+func (pg *PostgresHolidayStore) ImportHolidays(ctx context.Context, calendarID int64, holidays []Holiday) (int, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// xmax = 0 only for freshly inserted rows
+	query := `
+		INSERT INTO holidays (calendar_id, date, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (calendar_id, date) DO UPDATE SET name = EXCLUDED.name
+		RETURNING (xmax = 0)`
+
+	created := 0
+	for _, h := range holidays {
+		var inserted bool
+		if err := tx.QueryRowContext(ctx, query, calendarID, h.Date, h.Name).Scan(&inserted); err != nil {
+			return 0, err
+		}
+		if inserted {
+			created++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// userHolidaysQuery selects the holidays that apply to a user:
+// from their own calendar, or else from their office's calendar.
+const userHolidaysQuery = `
+	SELECT h.id, h.calendar_id, h.date, h.name, h.created_at
+	FROM users u
+	LEFT JOIN offices o ON o.id = u.office_id
+	JOIN holidays h ON h.calendar_id = COALESCE(u.holiday_calendar_id, o.holiday_calendar_id)
+	WHERE u.id = $1 AND h.date >= $2::date AND h.date <= $3::date
+	ORDER BY h.date`
+
+func (pg *PostgresHolidayStore) ListUserHolidays(ctx context.Context, userID int64, from, to string) ([]Holiday, error) {
+	rows, err := pg.db.QueryContext(ctx, userHolidaysQuery, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanHolidays(rows)
+}
+
+// userHolidayDates returns the user's holiday dates in [from, to] as a set.
+func userHolidayDates(ctx context.Context, db *sql.DB, userID int64, from, to time.Time) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, userHolidaysQuery, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := scanHolidays(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		out[h.Date] = true
+	}
+	return out, nil
+}
+
+func (pg *PostgresHolidayStore) ListOffices(ctx context.Context) ([]Office, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT id, name, holiday_calendar_id, created_at FROM offices ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Office{}
+	for rows.Next() {
+		var o Office
+		if err := rows.Scan(&o.Id, &o.Name, &o.HolidayCalendarId, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresHolidayStore) CreateOffice(ctx context.Context, o *Office) error {
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO offices (name, holiday_calendar_id) VALUES ($1, $2) RETURNING id, created_at`,
+		o.Name, o.HolidayCalendarId,
+	).Scan(&o.Id, &o.CreatedAt)
+}
+
+func (pg *PostgresHolidayStore) UpdateOffice(ctx context.Context, o *Office) error {
+	err := pg.db.QueryRowContext(ctx,
+		`UPDATE offices SET name = $2, holiday_calendar_id = $3 WHERE id = $1 RETURNING created_at`,
+		o.Id, o.Name, o.HolidayCalendarId,
+	).Scan(&o.CreatedAt)
+	return err
+}
+
+// AssignUser sets the user's office and personal holiday calendar; nil clears either.
+func (pg *PostgresHolidayStore) AssignUser(ctx context.Context, userID int64, officeID, calendarID *int64) error {
+	return execAffectingOne(ctx, pg.db,
+		`UPDATE users SET office_id = $2, holiday_calendar_id = $3, updated_at = NOW() WHERE id = $1`,
+		userID, officeID, calendarID,
+	)
+}
@@ -0,0 +1,466 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type PostgresOvertimeStore struct {
+	db *sql.DB
+}
+
+func NewPostgresOvertimeStore(db *sql.DB) *PostgresOvertimeStore {
+	return &PostgresOvertimeStore{db: db}
+}
+
+// WorkSchedule is the expected working time per weekday, Monday first.
+// It applies from EffectiveFrom until the user's next schedule starts.
+type WorkSchedule struct {
+	Id             int64     `json:"id"`
+	UserId         int64     `json:"user_id"`
+	EffectiveFrom  string    `json:"effective_from"`
+	WeekdaySeconds [7]int64  `json:"weekday_seconds"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OvertimeAdjustment struct {
+	Id            int64     `json:"id"`
+	UserId        int64     `json:"user_id"`
+	EffectiveDate string    `json:"effective_date"`
+	Seconds       int64     `json:"seconds"`
+	Reason        string    `json:"reason"`
+	CreatedBy     *int64    `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type OvertimeWeek struct {
+	Week              string `json:"week"`
+	From              string `json:"from"`
+	To                string `json:"to"`
+	WorkingDays       int    `json:"working_days"`
+	ExpectedSeconds   int64  `json:"expected_seconds"`
+	AbsenceSeconds    int64  `json:"absence_seconds"`
+	ActualSeconds     int64  `json:"actual_seconds"`
+	AdjustmentSeconds int64  `json:"adjustment_seconds"`
+	OvertimeSeconds   int64  `json:"overtime_seconds"`
+	UndertimeSeconds  int64  `json:"undertime_seconds"`
+	BalanceSeconds    int64  `json:"balance_seconds"`
+
+	// days before the user's first schedule, and the time worked on them
+	UnscheduledDays    int   `json:"unscheduled_days"`
+	UnscheduledSeconds int64 `json:"unscheduled_seconds"`
+}
+
+type OvertimeReport struct {
+	UserId   int64  `json:"user_id"`
+	UserName string `json:"user_name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+
+	WorkingDays           int   `json:"working_days"`
+	OpeningBalanceSeconds int64 `json:"opening_balance_seconds"`
+	ExpectedSeconds       int64 `json:"expected_seconds"`
+	AbsenceSeconds        int64 `json:"absence_seconds"`
+	ActualSeconds         int64 `json:"actual_seconds"`
+	AdjustmentSeconds     int64 `json:"adjustment_seconds"`
+	OvertimeSeconds       int64 `json:"overtime_seconds"`
+	UndertimeSeconds      int64 `json:"undertime_seconds"`
+	ClosingBalanceSeconds int64 `json:"closing_balance_seconds"`
+	UnscheduledDays       int   `json:"unscheduled_days"`
+	UnscheduledSeconds    int64 `json:"unscheduled_seconds"`
+
+	Weeks []OvertimeWeek `json:"weeks"`
+}
+
+type OvertimeStore interface {
+	ListSchedules(ctx context.Context, userID int64) ([]WorkSchedule, error)
+	CreateSchedule(ctx context.Context, schedule *WorkSchedule) error
+	DeleteSchedule(ctx context.Context, id int64) error
+
+	ListAdjustments(ctx context.Context, userID int64) ([]OvertimeAdjustment, error)
+	CreateAdjustment(ctx context.Context, adj *OvertimeAdjustment) error
+	DeleteAdjustment(ctx context.Context, id int64) error
+
+	ListScheduledUserIDs(ctx context.Context) ([]int64, error)
+	GetOvertimeReport(ctx context.Context, userID int64, from, to time.Time) (*OvertimeReport, error)
+}
+
+func (pg *PostgresOvertimeStore) ListSchedules(ctx context.Context, userID int64) ([]WorkSchedule, error) {
+	return listSchedules(ctx, pg.db, userID)
+}
+
+func listSchedules(ctx context.Context, db *sql.DB, userID int64) ([]WorkSchedule, error) {
+	query := `
+		SELECT id, user_id, effective_from,
+		       mon_seconds, tue_seconds, wed_seconds, thu_seconds, fri_seconds, sat_seconds, sun_seconds,
+		       created_at
+		FROM work_schedules
+		WHERE user_id = $1
+		ORDER BY effective_from`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []WorkSchedule{}
+	for rows.Next() {
+		var s WorkSchedule
+		var from time.Time
+		d := &s.WeekdaySeconds
+		if err := rows.Scan(&s.Id, &s.UserId, &from, &d[0], &d[1], &d[2], &d[3], &d[4], &d[5], &d[6], &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.EffectiveFrom = from.Format(time.DateOnly)
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresOvertimeStore) CreateSchedule(ctx context.Context, schedule *WorkSchedule) error {
+	query := `
+		INSERT INTO work_schedules (user_id, effective_from,
+			mon_seconds, tue_seconds, wed_seconds, thu_seconds, fri_seconds, sat_seconds, sun_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	d := schedule.WeekdaySeconds
+	return pg.db.QueryRowContext(ctx, query, schedule.UserId, schedule.EffectiveFrom,
+		d[0], d[1], d[2], d[3], d[4], d[5], d[6],
+	).Scan(&schedule.Id, &schedule.CreatedAt)
+}
+
+func (pg *PostgresOvertimeStore) DeleteSchedule(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM work_schedules WHERE id = $1`, id)
+}
+
+func (pg *PostgresOvertimeStore) ListAdjustments(ctx context.Context, userID int64) ([]OvertimeAdjustment, error) {
+	query := `
+		SELECT id, user_id, effective_date, seconds, reason, created_by, created_at
+		FROM overtime_adjustments
+		WHERE user_id = $1
+		ORDER BY effective_date, id`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []OvertimeAdjustment{}
+	for rows.Next() {
+		var a OvertimeAdjustment
+		var date time.Time
+		if err := rows.Scan(&a.Id, &a.UserId, &date, &a.Seconds, &a.Reason, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.EffectiveDate = date.Format(time.DateOnly)
+		out = append(out, a)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresOvertimeStore) CreateAdjustment(ctx context.Context, adj *OvertimeAdjustment) error {
+	query := `
+		INSERT INTO overtime_adjustments (user_id, effective_date, seconds, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	return pg.db.QueryRowContext(ctx, query, adj.UserId, adj.EffectiveDate, adj.Seconds, adj.Reason, adj.CreatedBy).
+		Scan(&adj.Id, &adj.CreatedAt)
+}
+
+func (pg *PostgresOvertimeStore) DeleteAdjustment(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM overtime_adjustments WHERE id = $1`, id)
+}
+
+// ListScheduledUserIDs returns the active users that have at least one work schedule.
+func (pg *PostgresOvertimeStore) ListScheduledUserIDs(ctx context.Context) ([]int64, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		WHERE u.is_active = TRUE
+		  AND EXISTS (SELECT 1 FROM work_schedules s WHERE s.user_id = u.id)
+		ORDER BY u.name, u.id`
+
+	rows, err := pg.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// expectedFunc returns how many seconds a user is expected to work on a local day.
+type expectedFunc func(day time.Time) int64
+
+// scheduleExpectation builds the expected time per day from the user's schedules.
+// Days before the first schedule expect nothing.
+func scheduleExpectation(schedules []WorkSchedule) expectedFunc {
+	return func(day time.Time) int64 {
+		key := day.Format(time.DateOnly)
+
+		// schedules are sorted by effective_from; the last one that started wins
+		i := sort.Search(len(schedules), func(i int) bool {
+			return schedules[i].EffectiveFrom > key
+		})
+		if i == 0 {
+			return 0
+		}
+		// time.Weekday is Sunday first, schedules are Monday first
+		return schedules[i-1].WeekdaySeconds[(int(day.Weekday())+6)%7]
+	}
+}
+
+// dailyActual sums work per local day for sessions started in [from, to).
+// Running sessions count up to now.
+func (pg *PostgresOvertimeStore) dailyActual(ctx context.Context, userID int64, from, to time.Time) (map[string]int64, error) {
+	query := `
+		SELECT (ws.start_at AT TIME ZONE $2)::date AS day,
+		       SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at)))::bigint
+		FROM work_sessions ws
+		WHERE ws.user_id = $1
+		  AND ws.start_at >= $3
+		  AND ws.start_at < $4
+		GROUP BY day`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, from.Location().String(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int64{}
+	for rows.Next() {
+		var day time.Time
+		var secs int64
+		if err := rows.Scan(&day, &secs); err != nil {
+			return nil, err
+		}
+		out[day.Format(time.DateOnly)] = secs
+	}
+
+	return out, rows.Err()
+}
+
+// dailyAdjustments sums manual adjustments per day up to and including `to`.
+func (pg *PostgresOvertimeStore) dailyAdjustments(ctx context.Context, userID int64, to time.Time) (map[string]int64, error) {
+	query := `
+		SELECT effective_date, SUM(seconds)::bigint
+		FROM overtime_adjustments
+		WHERE user_id = $1 AND effective_date <= $2
+		GROUP BY effective_date`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int64{}
+	for rows.Next() {
+		var day time.Time
+		var secs int64
+		if err := rows.Scan(&day, &secs); err != nil {
+			return nil, err
+		}
+		out[day.Format(time.DateOnly)] = secs
+	}
+
+	return out, rows.Err()
+}
+
+// GetOvertimeReport compares worked time with the user's schedules for the days
+// from..to (local midnights; the time zone comes from `from`), week by week.
+//
+// Public holidays expect nothing and aren't working days.
+// Approved absences are taken off the expected time and reported as absence_seconds.
+// The balance is carried from the user's first schedule: the opening balance is
+// everything worked minus everything expected before `from`, plus adjustments.
+// Days after today expect nothing, so an open period doesn't show undertime ahead of time.
+// Days before the first schedule, or all days when the user has none, are unscheduled:
+// the time worked on them is reported apart and never counts as overtime.
+func (pg *PostgresOvertimeStore) GetOvertimeReport(ctx context.Context, userID int64, from, to time.Time) (*OvertimeReport, error) {
+	loc := from.Location()
+
+	report := &OvertimeReport{
+		UserId: userID,
+		From:   from.Format(time.DateOnly),
+		To:     to.Format(time.DateOnly),
+		Weeks:  []OvertimeWeek{},
+	}
+
+	err := pg.db.QueryRowContext(ctx, `SELECT name FROM users WHERE id = $1`, userID).Scan(&report.UserName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	schedules, err := pg.ListSchedules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	expected := scheduleExpectation(schedules)
+
+	histStart := from
+	if len(schedules) > 0 {
+		first, err := time.ParseInLocation(time.DateOnly, schedules[0].EffectiveFrom, loc)
+		if err != nil {
+			return nil, err
+		}
+		if first.Before(histStart) {
+			histStart = first
+		}
+	}
+
+	end := to.AddDate(0, 0, 1)
+
+	actual, err := pg.dailyActual(ctx, userID, histStart, end)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := pg.dailyAdjustments(ctx, userID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	absences, err := approvedAbsences(ctx, pg.db, userID, histStart, end)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := userHolidayDates(ctx, pg.db, userID, histStart, to)
+	if err != nil {
+		return nil, err
+	}
+	isWorkingDay := workingDayFunc(schedules, holidays)
+
+	// adjustments dated before the tracked history still belong to the opening balance
+	histKey := histStart.Format(time.DateOnly)
+	for day, secs := range adjustments {
+		if day < histKey {
+			report.OpeningBalanceSeconds += secs
+		}
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var week *OvertimeWeek
+	closeWeek := func() {
+		if week == nil {
+			return
+		}
+		diff := week.ActualSeconds - week.ExpectedSeconds
+		if diff > 0 {
+			week.OvertimeSeconds = diff
+		} else {
+			week.UndertimeSeconds = -diff
+		}
+		report.OvertimeSeconds += week.OvertimeSeconds
+		report.UndertimeSeconds += week.UndertimeSeconds
+		report.Weeks = append(report.Weeks, *week)
+		week = nil
+	}
+
+	balance := report.OpeningBalanceSeconds
+
+	for day := histStart; day.Before(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(time.DateOnly)
+
+		// holidays expect nothing; approved leave is expected time that doesn't have to be worked
+		var exp, absent int64
+		if !day.After(today) && !holidays[key] {
+			exp = expected(day)
+			absent = int64(float64(exp) * absences[key])
+			exp -= absent
+		}
+		act := actual[key]
+		adj := adjustments[key]
+
+		scheduled := len(schedules) > 0 && key >= schedules[0].EffectiveFrom
+		var unscheduled int64
+		if !scheduled {
+			unscheduled, act = act, 0
+		}
+
+		balance += act - exp + adj
+
+		if day.Before(from) {
+			report.OpeningBalanceSeconds = balance
+			continue
+		}
+
+		if week == nil || day.Weekday() == time.Monday {
+			closeWeek()
+			y, w := day.ISOWeek()
+			week = &OvertimeWeek{Week: fmt.Sprintf("%d-W%02d", y, w), From: key}
+		}
+
+		working := 0
+		if scheduled && isWorkingDay(day) {
+			working = 1
+		}
+		if !scheduled {
+			week.UnscheduledDays++
+			week.UnscheduledSeconds += unscheduled
+			report.UnscheduledDays++
+			report.UnscheduledSeconds += unscheduled
+		}
+
+		week.To = key
+		week.WorkingDays += working
+		week.ExpectedSeconds += exp
+		week.AbsenceSeconds += absent
+		week.ActualSeconds += act
+		week.AdjustmentSeconds += adj
+		week.BalanceSeconds = balance
+
+		report.WorkingDays += working
+		report.ExpectedSeconds += exp
+		report.AbsenceSeconds += absent
+		report.ActualSeconds += act
+		report.AdjustmentSeconds += adj
+	}
+	closeWeek()
+
+	report.ClosingBalanceSeconds = balance
+
+	return report, nil
+}
+
+// execAffectingOne runs a statement and reports sql.ErrNoRows when nothing was changed.
+func execAffectingOne(ctx context.Context, db *sql.DB, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
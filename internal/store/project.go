@@ -3,6 +3,11 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,20 +25,125 @@ type ProjectStatus struct {
 }
 
 type ProjectRow struct {
-	Id     int64         `json:"project_id"`
-	Name   string        `json:"name"`
-	Status ProjectStatus `json:"status"`
+	Id       int64         `json:"project_id"`
+	Name     string        `json:"name"`
+	Status   ProjectStatus `json:"status"`
+	Billable bool          `json:"billable"`
+	Client   *ClientRef    `json:"client"`
 
+	ArchivedAt *time.Time `json:"archived_at"`
+
+	CustomFields CustomValues `json:"custom_fields"`
+
+	SessionCount   int64  `json:"session_count"`
 	TotalSeconds   int64  `json:"-"`
 	TotalDurations string `json:"total_durations"`
 
+	Budget      *ProjectBudget `json:"budget"`
+	BudgetUsage *BudgetUsage   `json:"budget_usage,omitempty"`
+
+	Estimate *ProjectEstimate `json:"estimate"`
+	Health   string           `json:"health"`
+	Forecast *ProjectForecast `json:"-"`
+
 	ActiveSessions []ActiveSessionRow `json:"active_sessions"`
 }
 
 type Project struct {
-	ProjectId   int64  `json:"project_id"`
-	ProjectName string `json:"project_name"`
-	StatusId    int64  `json:"status_id"`
+	ProjectId   int64          `json:"project_id"`
+	ProjectName string         `json:"project_name"`
+	StatusId    int64          `json:"status_id"`
+	ClientId    *int64         `json:"client_id"`
+	Billable    bool           `json:"billable"`
+	Budget      *ProjectBudget `json:"budget"`
+
+	Estimate *ProjectEstimate `json:"estimate"`
+
+	CustomFields CustomValues `json:"custom_fields"`
+}
+
+const (
+	BudgetPeriodTotal   = "total"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// BudgetThresholds are the consumption percentages that raise an alert.
+var BudgetThresholds = []int{50, 80, 100}
+
+// ProjectBudget limits a project in hours, money (hours times the hourly rate), or both,
+// over its whole life or per calendar month.
+type ProjectBudget struct {
+	Hours      *float64 `json:"hours,omitempty"`
+	Amount     *float64 `json:"amount,omitempty"`
+	HourlyRate *float64 `json:"hourly_rate,omitempty"`
+	Period     string   `json:"period"`
+}
+
+// BudgetUsage is how much of the budget the current period has used.
+// Percent is the larger of the hours and money percentages.
+type BudgetUsage struct {
+	Period         string   `json:"period"`
+	ConsumedHours  float64  `json:"consumed_hours"`
+	ConsumedAmount *float64 `json:"consumed_amount,omitempty"`
+	HoursPercent   *float64 `json:"hours_percent,omitempty"`
+	AmountPercent  *float64 `json:"amount_percent,omitempty"`
+	Percent        float64  `json:"percent"`
+}
+
+// ProjectUpdate holds the fields to change; nil fields are kept.
+// With SetBudget, Budget replaces the budget, and nil removes it; the same for SetClient and ClientID
+// and for SetEstimate and Estimate.
+type ProjectUpdate struct {
+	Name     *string
+	StatusID *int64
+	Billable *bool
+
+	SetBudget bool
+	Budget    *ProjectBudget
+
+	SetClient bool
+	ClientID  *int64
+
+	SetEstimate bool
+	Estimate    *ProjectEstimate
+
+	// CustomFields are merged into the project's custom values, after the keys in
+	// RemovedCustomFields are taken out
+	CustomFields        CustomValues
+	RemovedCustomFields []string
+
+	// StopSessions stops the project's running sessions when its new status doesn't accept time
+	StopSessions bool
+
+	// ChangedBy is recorded in the project's history
+	ChangedBy int64
+}
+
+// StoppedSession is a running session stopped on its owner's behalf.
+type StoppedSession struct {
+	SessionId int64     `json:"session_id"`
+	UserId    int64     `json:"user_id"`
+	EndAt     time.Time `json:"end_at"`
+}
+
+type BudgetAlert struct {
+	ProjectId   int64         `json:"project_id"`
+	ProjectName string        `json:"project_name"`
+	Threshold   int           `json:"threshold"`
+	Budget      ProjectBudget `json:"budget"`
+	Usage       BudgetUsage   `json:"usage"`
+}
+
+// RecordedBudgetAlert is a budget alert as it was recorded: the threshold a project's budget
+// period reached and its percent at that moment.
+type RecordedBudgetAlert struct {
+	Id          int64     `json:"id"`
+	ProjectId   int64     `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Threshold   int       `json:"threshold"`
+	Period      string    `json:"period"`
+	Percent     float64   `json:"percent"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type ActiveUser struct {
@@ -51,20 +161,97 @@ type ActiveSessionRow struct {
 }
 
 
+// ProjectListFilter narrows ListProjects. MemberID limits it to the projects a user is assigned to.
+// All lists every matching project, whatever Page and PageSize say.
+type ProjectListFilter struct {
+	Filter
+
+	All               bool
+	IncludeArchived   bool
+	MemberID          int64
+	Status            string // status id or name
+	Search            string // part of the project name
+	HasActiveSessions *bool
+
+	// From and To (dates, To inclusive) limit the sessions counted in the totals; budgets always use their own period
+	From *time.Time
+	To   *time.Time
+}
+
+// ProjectSortSafeList are the sort keys ListProjects accepts.
+var ProjectSortSafeList = []string{"id", "name", "status", "created_at", "session_count", "total_seconds"}
+
+var projectSortColumns = map[string]string{
+	"id":            "p.id",
+	"name":          "p.name",
+	"status":        "s.name",
+	"created_at":    "p.created_at",
+	"session_count": "t.session_count",
+	"total_seconds": "t.total_seconds",
+}
+
+// projectPage orders and cuts the matching projects before their sessions are totalled.
+type projectPage struct {
+	OrderBy string // on p and s, and on t.session_count and t.total_seconds with Totals
+	Totals  bool   // OrderBy needs the range totals of every matching project
+	Limit   string // e.g. LIMIT $8 OFFSET $9; empty for every project
+}
+
+// ErrProjectHasSessions is returned when deleting a project that still has work sessions.
+var ErrProjectHasSessions = errors.New("project has work sessions")
+
 type ProjectStore interface {
 	CreateProject(ctx context.Context, project *Project) error
-	ListProjects(ctx context.Context) ([]ProjectRow, error)
+	GetProject(ctx context.Context, id int64) (*ProjectRow, error)
+	ListProjects(ctx context.Context, filter ProjectListFilter) ([]ProjectRow, int, error)
 	ListActiveSessions(ctx context.Context) ([]ActiveSessionRow, error)
-	UpdateProject(ctx context.Context, id int64, name *string, statusID *int64) error
+	UpdateProject(ctx context.Context, id int64, upd ProjectUpdate) ([]StoppedSession, error)
+	SetProjectArchived(ctx context.Context, id int64, archived bool, by int64) error
+	ListProjectHistory(ctx context.Context, projectID int64) ([]ProjectChange, error)
+	GetProjectForecast(ctx context.Context, id int64) (*ProjectForecast, error)
+	DeleteProject(ctx context.Context, id int64, reassignTo *int64) (int64, error)
+	RecordBudgetAlerts(ctx context.Context) ([]BudgetAlert, error)
+	ListBudgetAlerts(ctx context.Context, projectID *int64, limit int) ([]RecordedBudgetAlert, error)
+}
+
+// budgetColumns splits a budget into the values of the budget_* columns.
+func budgetColumns(b *ProjectBudget) (hours, amount, rate *float64, period string) {
+	if b == nil {
+		return nil, nil, nil, BudgetPeriodTotal
+	}
+	period = b.Period
+	if period == "" {
+		period = BudgetPeriodTotal
+	}
+	return b.Hours, b.Amount, b.HourlyRate, period
+}
+
+// estimateColumns splits an estimate into the values of its columns.
+func estimateColumns(e *ProjectEstimate) (hours *float64, target *string) {
+	if e == nil {
+		return nil, nil
+	}
+	return e.Hours, e.TargetEndDate
 }
 
 func (pg *PostgresProjectStore) CreateProject(ctx context.Context, project *Project) error {
 	query := `
-	INSERT into projects (name, status_id)
-	VALUES($1, $2)
+	INSERT into projects (name, status_id, billable, budget_hours, budget_amount, hourly_rate, budget_period, client_id, custom_fields,
+		estimated_hours, target_end_date)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11::date)
 	RETURNING id`
 
-	err := pg.db.QueryRowContext(ctx, query, project.ProjectName, project.StatusId).Scan(&project.ProjectId)
+	custom, err := customValuesArg(project.CustomFields)
+	if err != nil {
+		return err
+	}
+
+	hours, amount, rate, period := budgetColumns(project.Budget)
+	estimated, target := estimateColumns(project.Estimate)
+	err = pg.db.QueryRowContext(ctx, query,
+		project.ProjectName, project.StatusId, project.Billable, hours, amount, rate, period, project.ClientId, custom,
+		estimated, target,
+	).Scan(&project.ProjectId)
 	if err != nil {
 		return err
 	}
@@ -72,76 +259,491 @@ func (pg *PostgresProjectStore) CreateProject(ctx context.Context, project *Proj
 	return nil
 }
 
-func (pg *PostgresProjectStore) ListProjects(ctx context.Context) ([]ProjectRow, error) {
+// ListProjects returns a page of projects with their totals, by name unless filter.Sort says
+// otherwise, and the number of matching projects. Archived projects are left out unless
+// filter.IncludeArchived is set.
+func (pg *PostgresProjectStore) ListProjects(ctx context.Context, filter ProjectListFilter) ([]ProjectRow, int, error) {
+	where := `
+		($3::boolean OR p.archived_at IS NULL)
+		AND ($4::bigint = 0 OR EXISTS (
+			SELECT 1 FROM project_members pm WHERE pm.project_id = p.id AND pm.user_id = $4
+		))
+		AND ($5 = '' OR s.id::text = $5 OR LOWER(s.name) = LOWER($5))
+		AND ($6 = '' OR p.name ILIKE '%' || $6 || '%')
+		AND ($7::boolean IS NULL OR EXISTS (
+			SELECT 1 FROM work_sessions a WHERE a.project_id = p.id AND a.end_at IS NULL
+		) = $7::boolean)`
+
+	sortKey, order := strings.TrimPrefix(filter.Sort, "-"), "ASC"
+	if strings.HasPrefix(filter.Sort, "-") {
+		order = "DESC"
+	}
+	column, ok := projectSortColumns[sortKey]
+	if !ok {
+		column = projectSortColumns["name"]
+	}
+	page := projectPage{
+		OrderBy: fmt.Sprintf(`%s %s, p.id ASC`, column, order),
+		Totals:  strings.HasPrefix(column, "t."),
+		// LIMIT NULL is no limit
+		Limit: `LIMIT NULLIF($8, 0) OFFSET $9`,
+	}
+
+	limit, offset := filter.Limit(), filter.Offset()
+	if filter.All {
+		limit, offset = 0, 0
+	}
+
+	var from, to *time.Time
+	if filter.From != nil {
+		f := time.Date(filter.From.Year(), filter.From.Month(), filter.From.Day(), 0, 0, 0, 0, time.UTC)
+		from = &f
+	}
+	if filter.To != nil {
+		t := time.Date(filter.To.Year(), filter.To.Month(), filter.To.Day(), 0, 0, 0, 0, time.UTC).Add(24 * time.Hour)
+		to = &t
+	}
+
+	return pg.listProjectRows(ctx, from, to, where, page,
+		filter.IncludeArchived, filter.MemberID, strings.TrimSpace(filter.Status),
+		escapeLike(strings.TrimSpace(filter.Search)), filter.HasActiveSessions,
+		limit, offset)
+}
+
+// GetProject returns one project with its totals, archived or not, or nil if it doesn't exist.
+func (pg *PostgresProjectStore) GetProject(ctx context.Context, id int64) (*ProjectRow, error) {
+	rows, _, err := pg.listProjectRows(ctx, nil, nil, `p.id = $3`, projectPage{OrderBy: "p.id"}, id)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+// listProjectRows runs the projects query. The projects matching where are put in order
+// and paged first, then only the page's sessions are totalled. $1 and $2 are the [from, to)
+// range of the totals, nil for all time; where and page use the args from $3 on.
+func (pg *PostgresProjectStore) listProjectRows(ctx context.Context, from, to *time.Time, where string, page projectPage, args ...any) ([]ProjectRow, int, error) {
+	totals := ""
+	if page.Totals {
+		totals = `
+			LEFT JOIN LATERAL (
+				SELECT COUNT(*) AS session_count,
+					COALESCE(SUM(EXTRACT(EPOCH FROM (w.end_at - w.start_at))) FILTER (WHERE w.end_at IS NOT NULL), 0) AS total_seconds
+				FROM work_sessions w
+				WHERE w.project_id = p.id
+				  AND ($1::timestamptz IS NULL OR w.start_at >= $1) AND ($2::timestamptz IS NULL OR w.start_at < $2)
+			) t ON TRUE`
+	}
+
 	query := `
+		WITH page AS (
+			SELECT p.id,
+				COUNT(*) OVER() AS total_records,
+				ROW_NUMBER() OVER (ORDER BY ` + page.OrderBy + `) AS ord
+			FROM projects p
+			JOIN statuses s ON p.status_id = s.id` + totals + `
+			WHERE ` + where + `
+			ORDER BY ord
+			` + page.Limit + `
+		)
 		SELECT
+			page.total_records,
 			p.id,
 			p.name AS name,
 			s.id,
 			s.name,
+			p.billable,
+			c.id,
+			c.name,
+			p.archived_at,
+			p.custom_fields,
+			COUNT(ws.id) FILTER (WHERE ws.in_range) AS session_count,
 			COALESCE(
-				SUM(EXTRACT(EPOCH FROM (ws.end_at - ws.start_at))),0)::bigint AS total_seconds
-		FROM projects p
+				SUM(EXTRACT(EPOCH FROM (ws.end_at - ws.start_at))) FILTER (WHERE ws.end_at IS NOT NULL AND ws.in_range),0)::bigint AS total_seconds,
+			p.budget_hours,
+			p.budget_amount,
+			p.hourly_rate,
+			p.budget_period,
+			COALESCE(
+				SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))) FILTER (
+					WHERE p.budget_period = 'total'
+					   OR ws.start_at >= date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+				), 0) AS budget_seconds,
+			p.estimated_hours,
+			to_char(p.target_end_date, 'YYYY-MM-DD'),
+			COALESCE(p.created_at, NOW()),
+			COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))), 0) AS logged_seconds,
+			COALESCE(
+				SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))) FILTER (
+					WHERE ws.start_at >= NOW() - make_interval(days => ` + strconv.Itoa(forecastVelocityDays) + `)
+				), 0) AS recent_seconds
+		FROM page
+		JOIN projects p ON p.id = page.id
 		JOIN statuses s ON p.status_id = s.id
-		LEFT JOIN work_sessions ws
-			ON ws.project_id = p.id
-			AND ws.end_at IS NOT NULL
-		GROUP BY p.id, p.name, s.id, s.name
-		ORDER BY p.name ASC, p.id ASC
-	`
+		LEFT JOIN clients c ON c.id = p.client_id
+		LEFT JOIN (
+			SELECT ws.project_id, ws.start_at, ws.end_at,
+				($1::timestamptz IS NULL OR ws.start_at >= $1) AND ($2::timestamptz IS NULL OR ws.start_at < $2) AS in_range
+			FROM work_sessions ws
+			WHERE ws.project_id IN (SELECT id FROM page)
+		) ws ON ws.project_id = p.id
+		GROUP BY page.ord, page.total_records, p.id, s.id, c.id
+		ORDER BY page.ord`
 
-	rows, err := pg.db.QueryContext(ctx, query)
+	rows, err := pg.db.QueryContext(ctx, query, append([]any{from, to}, args...)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var out []ProjectRow
+	out := []ProjectRow{}
+	total := 0
+	now := time.Now().UTC()
 
 	for rows.Next() {
 		var p ProjectRow
+		var b ProjectBudget
+		var budgetSeconds float64
+		var est ProjectEstimate
+		var created time.Time
+		var loggedSeconds, recentSeconds float64
+		var clientID *int64
+		var clientName *string
 		err := rows.Scan(
+			&total,
 			&p.Id,
 			&p.Name,
 			&p.Status.Id,
 			&p.Status.Name,
+			&p.Billable,
+			&clientID,
+			&clientName,
+			&p.ArchivedAt,
+			&p.CustomFields,
+			&p.SessionCount,
 			&p.TotalSeconds,
+			&b.Hours,
+			&b.Amount,
+			&b.HourlyRate,
+			&b.Period,
+			&budgetSeconds,
+			&est.Hours,
+			&est.TargetEndDate,
+			&created,
+			&loggedSeconds,
+			&recentSeconds,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+		if clientID != nil {
+			p.Client = &ClientRef{Id: *clientID, Name: *clientName}
 		}
+		if b.Hours != nil || b.Amount != nil {
+			p.Budget = &b
+			p.BudgetUsage = budgetUsage(b, budgetSeconds, now)
+		}
+		if est.Hours != nil || est.TargetEndDate != nil {
+			p.Estimate = &est
+		}
+		p.Forecast = projectForecast(p.Id, est, created, loggedSeconds, recentSeconds, now)
+		p.Health = p.Forecast.Health
 		out = append(out, p)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return out, nil
+	return out, total, nil
 }
 
-func (pg *PostgresProjectStore) UpdateProject(ctx context.Context, id int64, name *string, statusID *int64) error {
+// UpdateProject records the changed fields in the project's history and returns the
+// sessions it stopped for upd.StopSessions.
+func (pg *PostgresProjectStore) UpdateProject(ctx context.Context, id int64, upd ProjectUpdate) ([]StoppedSession, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockProjectSnapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE projects
 		SET
 			name      = COALESCE($1, name),
-			status_id = COALESCE($2, status_id)
+			status_id = COALESCE($2, status_id),
+			billable  = COALESCE($4, billable),
+			budget_hours  = CASE WHEN $5::boolean THEN $6::double precision ELSE budget_hours END,
+			budget_amount = CASE WHEN $5::boolean THEN $7::double precision ELSE budget_amount END,
+			hourly_rate   = CASE WHEN $5::boolean THEN $8::double precision ELSE hourly_rate END,
+			budget_period = CASE WHEN $5::boolean THEN $9::text ELSE budget_period END,
+			client_id     = CASE WHEN $10::boolean THEN $11::bigint ELSE client_id END,
+			custom_fields = CASE WHEN $12::boolean THEN (custom_fields || $13::jsonb) - $17::text[] ELSE custom_fields END,
+			estimated_hours = CASE WHEN $14::boolean THEN $15::double precision ELSE estimated_hours END,
+			target_end_date = CASE WHEN $14::boolean THEN $16::date ELSE target_end_date END
 		WHERE id = $3
 	`
 
-	res, err := pg.db.ExecContext(ctx, query, name, statusID, id)
+	custom, err := customValuesArg(upd.CustomFields)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	removed := upd.RemovedCustomFields
+	if removed == nil {
+		removed = []string{}
+	}
+
+	hours, amount, rate, period := budgetColumns(upd.Budget)
+	estimated, target := estimateColumns(upd.Estimate)
+	res, err := tx.ExecContext(ctx, query,
+		upd.Name, upd.StatusID, id, upd.Billable, upd.SetBudget, hours, amount, rate, period,
+		upd.SetClient, upd.ClientID, upd.CustomFields != nil || len(upd.RemovedCustomFields) > 0, custom,
+		upd.SetEstimate, estimated, target, removed,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	rows, err := res.RowsAffected()
 	if err == nil && rows == 0 {
-		return sql.ErrNoRows
+		return nil, sql.ErrNoRows
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordProjectChanges(ctx, tx, id, before, upd.ChangedBy); err != nil {
+		return nil, err
+	}
+
+	// a new budget starts its alerts over
+	if upd.SetBudget {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM project_budget_alerts WHERE project_id = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	stopped := []StoppedSession{}
+	if upd.StopSessions {
+		stopped, err = stopClosedProjectSessions(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stopped, tx.Commit()
+}
+
+// stopClosedProjectSessions stops the running sessions on a project whose status doesn't accept time.
+func stopClosedProjectSessions(ctx context.Context, tx *sql.Tx, projectID int64) ([]StoppedSession, error) {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE work_sessions ws
+		SET end_at = NOW()
+		FROM projects p
+		JOIN statuses s ON s.id = p.status_id
+		WHERE p.id = ws.project_id
+		  AND ws.project_id = $1
+		  AND ws.end_at IS NULL
+		  AND NOT s.accepts_time
+		RETURNING ws.id, ws.user_id, ws.end_at`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []StoppedSession{}
+	for rows.Next() {
+		var st StoppedSession
+		if err := rows.Scan(&st.SessionId, &st.UserId, &st.EndAt); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// SetProjectArchived archives or unarchives a project. Archiving an archived project keeps
+// its original archived_at. It returns sql.ErrNoRows when the project doesn't exist.
+func (pg *PostgresProjectStore) SetProjectArchived(ctx context.Context, id int64, archived bool, by int64) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	before, err := lockProjectSnapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE projects
+		SET archived_at = CASE WHEN $2::boolean THEN COALESCE(archived_at, NOW()) END
+		WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id, archived); err != nil {
+		return err
+	}
+
+	if err := recordProjectChanges(ctx, tx, id, before, by); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteProject deletes a project. A project with work sessions is only deleted when
+// reassignTo is given: its sessions (without their tasks) and drafts move to that project
+// first, and the number of moved sessions is returned. Without it ErrProjectHasSessions is returned.
+// The caller checks that the target exists and accepts sessions.
+func (pg *PostgresProjectStore) DeleteProject(ctx context.Context, id int64, reassignTo *int64) (int64, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the row lock keeps sessions from being added until the project is gone
+	var sessions int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM work_sessions WHERE project_id = p.id)
+		FROM projects p
+		WHERE p.id = $1
+		FOR UPDATE`, id,
+	).Scan(&sessions)
+	if err != nil {
+		return 0, err
+	}
+
+	if sessions > 0 {
+		if reassignTo == nil {
+			return 0, ErrProjectHasSessions
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE work_sessions SET project_id = $1, task_id = NULL WHERE project_id = $2`, *reassignTo, id); err != nil {
+			return 0, err
+		}
+	}
+	if reassignTo != nil {
+		if _, err := tx.ExecContext(ctx, `UPDATE session_drafts SET project_id = $1 WHERE project_id = $2`, *reassignTo, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM projects WHERE id = $1`, id); err != nil {
+		return 0, err
+	}
+
+	return sessions, tx.Commit()
+}
+
+// budgetUsage measures seconds logged in the current budget period against the budget.
+func budgetUsage(b ProjectBudget, seconds float64, now time.Time) *BudgetUsage {
+	u := &BudgetUsage{
+		Period:        BudgetPeriodTotal,
+		ConsumedHours: math.Round(seconds/3600*100) / 100,
+	}
+	if b.Period == BudgetPeriodMonthly {
+		u.Period = now.Format("2006-01")
+	}
+
+	percent := func(used, budget float64) *float64 {
+		v := math.Round(used/budget*1000) / 10
+		if v > u.Percent {
+			u.Percent = v
+		}
+		return &v
+	}
+
+	if b.Hours != nil {
+		u.HoursPercent = percent(seconds/3600, *b.Hours)
+	}
+	if b.HourlyRate != nil {
+		amount := math.Round(seconds/3600**b.HourlyRate*100) / 100
+		u.ConsumedAmount = &amount
+		if b.Amount != nil {
+			u.AmountPercent = percent(amount, *b.Amount)
+		}
+	}
+
+	return u
+}
+
+// RecordBudgetAlerts records every threshold the projects' current budget periods have reached
+// and returns the ones that weren't recorded before, so each alert goes out once.
+func (pg *PostgresProjectStore) RecordBudgetAlerts(ctx context.Context) ([]BudgetAlert, error) {
+	projects, _, err := pg.ListProjects(ctx, ProjectListFilter{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO project_budget_alerts (project_id, threshold, period, percent)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, threshold, period) DO NOTHING
+		RETURNING id`
+
+	var out []BudgetAlert
+	for _, p := range projects {
+		if p.BudgetUsage == nil {
+			continue
+		}
+
+		for _, threshold := range BudgetThresholds {
+			if p.BudgetUsage.Percent < float64(threshold) {
+				break
+			}
+
+			var id int64
+			err := pg.db.QueryRowContext(ctx, query, p.Id, threshold, p.BudgetUsage.Period, p.BudgetUsage.Percent).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, BudgetAlert{
+				ProjectId:   p.Id,
+				ProjectName: p.Name,
+				Threshold:   threshold,
+				Budget:      *p.Budget,
+				Usage:       *p.BudgetUsage,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// ListBudgetAlerts returns the recorded alerts, newest first, of one project or of all.
+func (pg *PostgresProjectStore) ListBudgetAlerts(ctx context.Context, projectID *int64, limit int) ([]RecordedBudgetAlert, error) {
+	rows, err := pg.db.QueryContext(ctx, `
+		SELECT a.id, a.project_id, p.name, a.threshold, a.period, a.percent, a.created_at
+		FROM project_budget_alerts a
+		JOIN projects p ON p.id = a.project_id
+		WHERE ($1::bigint IS NULL OR a.project_id = $1)
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $2`, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []RecordedBudgetAlert{}
+	for rows.Next() {
+		var a RecordedBudgetAlert
+		if err := rows.Scan(&a.Id, &a.ProjectId, &a.ProjectName, &a.Threshold, &a.Period, &a.Percent, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
 }
 
 func (pg *PostgresProjectStore) ListActiveSessions(ctx context.Context) ([]ActiveSessionRow, error) {
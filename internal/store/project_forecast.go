@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// forecastVelocityDays is how far back the forecast looks for the project's velocity.
+const forecastVelocityDays = 28
+
+// Project health, from the estimate against the logged time and the forecast.
+const (
+	HealthUnknown  = "unknown"   // no estimated effort
+	HealthOnTrack  = "on_track"  // within the estimate and, at the current velocity, done by the target end date
+	HealthAtRisk   = "at_risk"   // at the current velocity the work ends after the target end date, or doesn't move
+	HealthOffTrack = "off_track" // over the estimate, or past the target end date with work left
+)
+
+// ProjectEstimate is the effort a project is expected to take and when it should be done.
+// Unlike a budget it limits nothing. TargetEndDate is YYYY-MM-DD.
+type ProjectEstimate struct {
+	Hours         *float64 `json:"hours,omitempty"`
+	TargetEndDate *string  `json:"target_end_date,omitempty"`
+}
+
+// ProjectForecast compares a project's logged time with its estimate. The expected burn is
+// linear from the day the project was created to its target end date; the forecast extends
+// the velocity of the last forecastVelocityDays days. Dates are UTC.
+type ProjectForecast struct {
+	ProjectId      int64    `json:"project_id"`
+	EstimatedHours *float64 `json:"estimated_hours"`
+	TargetEndDate  *string  `json:"target_end_date"`
+	StartDate      string   `json:"start_date"`
+
+	ActualHours     float64  `json:"actual_hours"`
+	ExpectedHours   *float64 `json:"expected_hours"`
+	RemainingHours  *float64 `json:"remaining_hours"`
+	PercentComplete *float64 `json:"percent_complete"`
+
+	VelocityHoursPerWeek float64 `json:"velocity_hours_per_week"`
+	// ForecastEndDate is when the estimate is used up at the current velocity;
+	// ForecastHours is the effort logged by the target end date at that velocity.
+	ForecastEndDate *string  `json:"forecast_end_date"`
+	ForecastHours   *float64 `json:"forecast_hours"`
+
+	Health string `json:"health"`
+
+	Burn []BurnPoint `json:"burn,omitempty"`
+}
+
+// BurnPoint is the time expected and logged by the end of Date. Actual is nil for future dates.
+type BurnPoint struct {
+	Date     string   `json:"date"`
+	Expected *float64 `json:"expected_hours"`
+	Actual   *float64 `json:"actual_hours"`
+}
+
+// round2 rounds hours to two decimals.
+func round2(h float64) float64 {
+	return math.Round(h*100) / 100
+}
+
+// expectedHours is the linear burn of the estimate by the end of day.
+func expectedHours(estimate float64, start, target, day time.Time) float64 {
+	if !target.After(start) {
+		return estimate
+	}
+	done := day.AddDate(0, 0, 1).Sub(start).Hours() / target.AddDate(0, 0, 1).Sub(start).Hours()
+	return round2(estimate * math.Max(0, math.Min(1, done)))
+}
+
+// projectForecast works out the forecast from the hours logged on the project, all time and
+// in the last forecastVelocityDays days. created is when the project was created.
+func projectForecast(projectID int64, est ProjectEstimate, created time.Time, seconds, recentSeconds float64, now time.Time) *ProjectForecast {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	created = created.UTC()
+	start := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+
+	f := &ProjectForecast{
+		ProjectId:            projectID,
+		EstimatedHours:       est.Hours,
+		TargetEndDate:        est.TargetEndDate,
+		StartDate:            start.Format(time.DateOnly),
+		ActualHours:          round2(seconds / 3600),
+		VelocityHoursPerWeek: round2(recentSeconds / 3600 / forecastVelocityDays * 7),
+		Health:               HealthUnknown,
+	}
+	perDay := recentSeconds / 3600 / forecastVelocityDays
+
+	var target *time.Time
+	if est.TargetEndDate != nil {
+		if t, err := time.Parse(time.DateOnly, *est.TargetEndDate); err == nil {
+			target = &t
+		}
+	}
+
+	if target != nil {
+		h := f.ActualHours
+		if target.After(today) {
+			h = round2(seconds/3600 + perDay*target.Sub(today).Hours()/24)
+		}
+		f.ForecastHours = &h
+	}
+
+	if est.Hours == nil {
+		return f
+	}
+	estimate := *est.Hours
+
+	remaining := round2(math.Max(0, estimate-seconds/3600))
+	percent := math.Round(seconds/3600/estimate*1000) / 10
+	f.RemainingHours, f.PercentComplete = &remaining, &percent
+
+	if target != nil {
+		expected := expectedHours(estimate, start, *target, today)
+		f.ExpectedHours = &expected
+	}
+
+	var forecastEnd *time.Time
+	if remaining > 0 && perDay > 0 {
+		end := today.AddDate(0, 0, int(math.Ceil(remaining/perDay)))
+		forecastEnd = &end
+		d := end.Format(time.DateOnly)
+		f.ForecastEndDate = &d
+	}
+
+	switch {
+	case seconds/3600 > estimate:
+		f.Health = HealthOffTrack
+	case target == nil || remaining == 0:
+		f.Health = HealthOnTrack
+	case target.Before(today):
+		f.Health = HealthOffTrack
+	case forecastEnd == nil || forecastEnd.After(*target):
+		f.Health = HealthAtRisk
+	default:
+		f.Health = HealthOnTrack
+	}
+	return f
+}
+
+// GetProjectForecast returns the project's forecast with its weekly burn, or nil if the
+// project doesn't exist. The burn runs from the start date to the later of today and the
+// target end date, a point at the end of every week and one on the last day.
+func (pg *PostgresProjectStore) GetProjectForecast(ctx context.Context, id int64) (*ProjectForecast, error) {
+	project, err := pg.GetProject(ctx, id)
+	if err != nil || project == nil {
+		return nil, err
+	}
+	f := project.Forecast
+
+	rows, err := pg.db.QueryContext(ctx, `
+		SELECT to_char(ws.start_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'),
+			SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at)))
+		FROM work_sessions ws
+		WHERE ws.project_id = $1
+		GROUP BY 1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	daily := map[string]float64{}
+	for rows.Next() {
+		var day string
+		var seconds float64
+		if err := rows.Scan(&day, &seconds); err != nil {
+			return nil, err
+		}
+		daily[day] = seconds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start, _ := time.Parse(time.DateOnly, f.StartDate)
+
+	end := today
+	var target *time.Time
+	if f.TargetEndDate != nil {
+		if t, err := time.Parse(time.DateOnly, *f.TargetEndDate); err == nil {
+			target = &t
+			if t.After(end) {
+				end = t
+			}
+		}
+	}
+
+	// time logged before the start date counts from its first day
+	var cumulative float64
+	for day, seconds := range daily {
+		if d, err := time.Parse(time.DateOnly, day); err == nil && d.Before(start) {
+			cumulative += seconds
+		}
+	}
+
+	f.Burn = []BurnPoint{}
+	for i, day := 1, start; !day.After(end); i, day = i+1, day.AddDate(0, 0, 1) {
+		cumulative += daily[day.Format(time.DateOnly)]
+		if i%7 != 0 && !day.Equal(end) {
+			continue
+		}
+
+		p := BurnPoint{Date: day.Format(time.DateOnly)}
+		if f.EstimatedHours != nil && target != nil {
+			e := expectedHours(*f.EstimatedHours, start, *target, day)
+			p.Expected = &e
+		}
+		if !day.After(today) {
+			a := round2(cumulative / 3600)
+			p.Actual = &a
+		}
+		f.Burn = append(f.Burn, p)
+	}
+
+	return f, nil
+}
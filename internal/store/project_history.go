@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ProjectChange is one changed field of a project. Fields are name, status, client,
+// billable, budget, estimate, archived and custom_fields; statuses and clients are recorded as {"id", "name"}.
+type ProjectChange struct {
+	Id        int64           `json:"id"`
+	ProjectId int64           `json:"project_id"`
+	Field     string          `json:"field"`
+	OldValue  json.RawMessage `json:"old_value"`
+	NewValue  json.RawMessage `json:"new_value"`
+	ChangedBy *int64          `json:"changed_by"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// StatusSpan is a status a project had during part of a report's period, [From, To).
+type StatusSpan struct {
+	StatusId int64     `json:"status_id"`
+	Status   string    `json:"status"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+// projectSnapshot is the project as history sees it, one key per recorded field.
+const projectSnapshot = `
+	SELECT jsonb_build_object(
+		'name', p.name,
+		'status', jsonb_build_object('id', s.id, 'name', s.name),
+		'client', CASE WHEN c.id IS NULL THEN NULL ELSE jsonb_build_object('id', c.id, 'name', c.name) END,
+		'billable', p.billable,
+		'budget', CASE WHEN p.budget_hours IS NULL AND p.budget_amount IS NULL THEN NULL ELSE jsonb_strip_nulls(jsonb_build_object(
+			'hours', p.budget_hours,
+			'amount', p.budget_amount,
+			'hourly_rate', p.hourly_rate,
+			'period', p.budget_period)) END,
+		'estimate', CASE WHEN p.estimated_hours IS NULL AND p.target_end_date IS NULL THEN NULL ELSE jsonb_strip_nulls(jsonb_build_object(
+			'hours', p.estimated_hours,
+			'target_end_date', p.target_end_date)) END,
+		'archived', p.archived_at IS NOT NULL,
+		'custom_fields', p.custom_fields
+	)
+	FROM projects p
+	JOIN statuses s ON s.id = p.status_id
+	LEFT JOIN clients c ON c.id = p.client_id
+	WHERE p.id = $1`
+
+// lockProjectSnapshot locks the project for the rest of tx and returns it as it is before the change.
+// A missing project comes back as sql.ErrNoRows.
+func lockProjectSnapshot(ctx context.Context, tx *sql.Tx, id int64) ([]byte, error) {
+	var before []byte
+	err := tx.QueryRowContext(ctx, projectSnapshot+` FOR UPDATE OF p`, id).Scan(&before)
+	return before, err
+}
+
+// recordProjectChanges compares the project with its snapshot from before the change
+// and records a history row per field that differs.
+func recordProjectChanges(ctx context.Context, tx *sql.Tx, id int64, before []byte, by int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO project_history (project_id, field, old_value, new_value, changed_by)
+		SELECT $1, o.key, o.value, n.value, NULLIF($3, 0)
+		FROM jsonb_each($2::jsonb) o
+		JOIN jsonb_each((`+projectSnapshot+`)) n ON n.key = o.key
+		WHERE o.value IS DISTINCT FROM n.value
+		ORDER BY o.key`,
+		id, before, by)
+	return err
+}
+
+// ListProjectHistory returns a project's changes, newest first.
+// It returns sql.ErrNoRows when the project doesn't exist.
+func (pg *PostgresProjectStore) ListProjectHistory(ctx context.Context, projectID int64) ([]ProjectChange, error) {
+	var exists bool
+	if err := pg.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1)`, projectID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := pg.db.QueryContext(ctx, `
+		SELECT id, project_id, field, COALESCE(old_value, 'null'), COALESCE(new_value, 'null'), changed_by, changed_at
+		FROM project_history
+		WHERE project_id = $1
+		ORDER BY changed_at DESC, id DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ProjectChange{}
+	for rows.Next() {
+		var ch ProjectChange
+		var oldValue, newValue []byte
+		if err := rows.Scan(&ch.Id, &ch.ProjectId, &ch.Field, &oldValue, &newValue, &ch.ChangedBy, &ch.ChangedAt); err != nil {
+			return nil, err
+		}
+		ch.OldValue, ch.NewValue = oldValue, newValue
+		out = append(out, ch)
+	}
+	return out, rows.Err()
+}
+
+// projectStatusSpans works out the statuses each project had during [from, to) from the
+// status history. Before its first recorded change a project had that change's old status;
+// a project without changes had its current status all along.
+func projectStatusSpans(ctx context.Context, db *sql.DB, projectIDs []int64, from, to time.Time) (map[int64][]StatusSpan, error) {
+	type statusRef struct {
+		Id   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+
+	current := map[int64]statusRef{}
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, s.id, s.name
+		FROM projects p
+		JOIN statuses s ON s.id = p.status_id
+		WHERE p.id = ANY($1::bigint[])`, nonNilInt64s(projectIDs))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var projectID int64
+		var st statusRef
+		if err := rows.Scan(&projectID, &st.Id, &st.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		current[projectID] = st
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		at       time.Time
+		old, new statusRef
+	}
+	changes := map[int64][]change{}
+	rows, err = db.QueryContext(ctx, `
+		SELECT project_id, old_value, new_value, changed_at
+		FROM project_history
+		WHERE field = 'status' AND project_id = ANY($1::bigint[])
+		ORDER BY project_id, changed_at, id`, nonNilInt64s(projectIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var projectID int64
+		var oldValue, newValue []byte
+		var ch change
+		if err := rows.Scan(&projectID, &oldValue, &newValue, &ch.at); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(oldValue, &ch.old); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(newValue, &ch.new); err != nil {
+			return nil, err
+		}
+		changes[projectID] = append(changes[projectID], ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[int64][]StatusSpan, len(current))
+	for projectID, st := range current {
+		history := changes[projectID]
+		if len(history) > 0 {
+			st = history[0].old
+		}
+
+		start := from
+		var spans []StatusSpan
+		for _, ch := range history {
+			if !ch.at.After(from) {
+				st = ch.new
+				continue
+			}
+			if !ch.at.Before(to) {
+				break
+			}
+			spans = append(spans, StatusSpan{StatusId: st.Id, Status: st.Name, From: start, To: ch.at})
+			st, start = ch.new, ch.at
+		}
+		out[projectID] = append(spans, StatusSpan{StatusId: st.Id, Status: st.Name, From: start, To: to})
+	}
+	return out, nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ProjectMember struct {
+	ProjectId int64     `json:"project_id"`
+	UserId    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	IsManager bool      `json:"is_manager"`
+	AddedBy   *int64    `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProjectMemberStore interface {
+	ListProjectMembers(ctx context.Context, projectID int64) ([]ProjectMember, error)
+	AddProjectMember(ctx context.Context, member *ProjectMember) (bool, error)
+	RemoveProjectMember(ctx context.Context, projectID, userID int64) error
+	IsProjectMember(ctx context.Context, projectID, userID int64) (bool, error)
+	SetProjectManager(ctx context.Context, projectID, userID int64, isManager bool) error
+	IsProjectManager(ctx context.Context, projectID, userID int64) (bool, error)
+	ManagedProjectIDs(ctx context.Context, userID int64) ([]int64, error)
+	ProjectManagerIDs(ctx context.Context, projectID int64) ([]int64, error)
+}
+
+type PostgresProjectMemberStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProjectMemberStore(db *sql.DB) *PostgresProjectMemberStore {
+	return &PostgresProjectMemberStore{db: db}
+}
+
+func (pg *PostgresProjectMemberStore) ListProjectMembers(ctx context.Context, projectID int64) ([]ProjectMember, error) {
+	query := `
+		SELECT pm.project_id, pm.user_id, u.name, u.email, pm.is_manager, pm.added_by, pm.created_at
+		FROM project_members pm
+		JOIN users u ON u.id = pm.user_id
+		WHERE pm.project_id = $1
+		ORDER BY u.name ASC, u.id ASC`
+
+	rows, err := pg.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ProjectMember{}
+	for rows.Next() {
+		var m ProjectMember
+		if err := rows.Scan(&m.ProjectId, &m.UserId, &m.Name, &m.Email, &m.IsManager, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, rows.Err()
+}
+
+// AddProjectMember assigns a user to a project and fills in the member's details.
+// It reports false when the user already was a member; the existing row, manager flag included, is kept.
+func (pg *PostgresProjectMemberStore) AddProjectMember(ctx context.Context, member *ProjectMember) (bool, error) {
+	query := `
+		WITH added AS (
+			INSERT INTO project_members (project_id, user_id, added_by, is_manager)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (project_id, user_id) DO NOTHING
+			RETURNING is_manager, added_by, created_at
+		)
+		SELECT true, a.is_manager, a.added_by, a.created_at FROM added a
+		UNION ALL
+		SELECT false, pm.is_manager, pm.added_by, pm.created_at
+		FROM project_members pm
+		WHERE pm.project_id = $1 AND pm.user_id = $2 AND NOT EXISTS (SELECT 1 FROM added)`
+
+	var added bool
+	err := pg.db.QueryRowContext(ctx, query, member.ProjectId, member.UserId, member.AddedBy, member.IsManager).
+		Scan(&added, &member.IsManager, &member.AddedBy, &member.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	err = pg.db.QueryRowContext(ctx, `SELECT name, email FROM users WHERE id = $1`, member.UserId).
+		Scan(&member.Name, &member.Email)
+	return added, err
+}
+
+// RemoveProjectMember returns sql.ErrNoRows when the user isn't a member.
+func (pg *PostgresProjectMemberStore) RemoveProjectMember(ctx context.Context, projectID, userID int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`, projectID, userID)
+}
+
+func (pg *PostgresProjectMemberStore) IsProjectMember(ctx context.Context, projectID, userID int64) (bool, error) {
+	var member bool
+	err := pg.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM project_members WHERE project_id = $1 AND user_id = $2)`,
+		projectID, userID,
+	).Scan(&member)
+	return member, err
+}
+
+// SetProjectManager makes a member a manager of the project, or takes it back.
+// It returns sql.ErrNoRows when the user isn't a member.
+func (pg *PostgresProjectMemberStore) SetProjectManager(ctx context.Context, projectID, userID int64, isManager bool) error {
+	return execAffectingOne(ctx, pg.db,
+		`UPDATE project_members SET is_manager = $3 WHERE project_id = $1 AND user_id = $2`,
+		projectID, userID, isManager)
+}
+
+func (pg *PostgresProjectMemberStore) IsProjectManager(ctx context.Context, projectID, userID int64) (bool, error) {
+	var manager bool
+	err := pg.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM project_members WHERE project_id = $1 AND user_id = $2 AND is_manager)`,
+		projectID, userID,
+	).Scan(&manager)
+	return manager, err
+}
+
+// ManagedProjectIDs returns the projects the user manages, none for most users.
+func (pg *PostgresProjectMemberStore) ManagedProjectIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return pg.queryIDs(ctx,
+		`SELECT project_id FROM project_members WHERE user_id = $1 AND is_manager ORDER BY project_id`, userID)
+}
+
+// ProjectManagerIDs returns the users who manage the project.
+func (pg *PostgresProjectMemberStore) ProjectManagerIDs(ctx context.Context, projectID int64) ([]int64, error) {
+	return pg.queryIDs(ctx,
+		`SELECT user_id FROM project_members WHERE project_id = $1 AND is_manager ORDER BY user_id`, projectID)
+}
+
+func (pg *PostgresProjectMemberStore) queryIDs(ctx context.Context, query string, args ...any) ([]int64, error) {
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
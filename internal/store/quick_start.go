@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quickStartFrequentDays is how far back the frequent projects are counted.
+const quickStartFrequentDays = 90
+
+// QuickStartProject is a project a user can start a timer on, with what they last did on it,
+// so a client can restart that work in one click.
+type QuickStartProject struct {
+	ProjectId int64      `json:"project_id"`
+	Name      string     `json:"name"`
+	Client    *ClientRef `json:"client"`
+	Favorite  bool       `json:"favorite"`
+
+	LastTask   *TaskRef   `json:"last_task"`
+	LastNote   string     `json:"last_note"`
+	LastTags   Tags       `json:"last_tags"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+
+	// RecentSessions counts the sessions of the last quickStartFrequentDays days
+	RecentSessions int64 `json:"recent_sessions"`
+}
+
+// QuickStart is a user's favorite projects by name, and their most recently and most
+// frequently used ones. Only projects that accept time are listed.
+type QuickStart struct {
+	Favorites []QuickStartProject `json:"favorites"`
+	Recent    []QuickStartProject `json:"recent"`
+	Frequent  []QuickStartProject `json:"frequent"`
+}
+
+type QuickStartStore interface {
+	AddFavorite(ctx context.Context, userID, projectID int64) error
+	RemoveFavorite(ctx context.Context, userID, projectID int64) error
+	GetQuickStart(ctx context.Context, userID int64, membersOnly bool, limit int) (*QuickStart, error)
+}
+
+type PostgresQuickStartStore struct {
+	db *sql.DB
+}
+
+func NewPostgresQuickStartStore(db *sql.DB) *PostgresQuickStartStore {
+	return &PostgresQuickStartStore{db: db}
+}
+
+// AddFavorite pins a project for a user; pinning it again changes nothing.
+func (pg *PostgresQuickStartStore) AddFavorite(ctx context.Context, userID, projectID int64) error {
+	_, err := pg.db.ExecContext(ctx, `
+		INSERT INTO favorite_projects (user_id, project_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, userID, projectID)
+	return err
+}
+
+// RemoveFavorite returns sql.ErrNoRows when the project isn't one of the user's favorites.
+func (pg *PostgresQuickStartStore) RemoveFavorite(ctx context.Context, userID, projectID int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM favorite_projects WHERE user_id = $1 AND project_id = $2`, userID, projectID)
+}
+
+// GetQuickStart lists the projects a user pinned or logged time on that still take time:
+// not archived and with a status that accepts time. With membersOnly, projects the user was
+// taken off are left out. Recent and Frequent hold at most limit projects each.
+func (pg *PostgresQuickStartStore) GetQuickStart(ctx context.Context, userID int64, membersOnly bool, limit int) (*QuickStart, error) {
+	query := `
+		WITH last AS (
+			SELECT DISTINCT ON (ws.project_id) ws.project_id, ws.task_id, ws.note, ws.tags, ws.start_at
+			FROM work_sessions ws
+			WHERE ws.user_id = $1
+			ORDER BY ws.project_id, ws.start_at DESC, ws.id DESC
+		), counts AS (
+			SELECT ws.project_id, COUNT(*) AS n
+			FROM work_sessions ws
+			WHERE ws.user_id = $1
+			  AND ws.start_at >= NOW() - make_interval(days => ` + strconv.Itoa(quickStartFrequentDays) + `)
+			GROUP BY ws.project_id
+		)
+		SELECT
+			p.id,
+			p.name,
+			c.id,
+			c.name,
+			f.user_id IS NOT NULL,
+			tk.id,
+			tk.name,
+			COALESCE(l.note, ''),
+			to_json(l.tags),
+			l.start_at,
+			COALESCE(n.n, 0)
+		FROM projects p
+		JOIN statuses s ON s.id = p.status_id
+		LEFT JOIN clients c ON c.id = p.client_id
+		LEFT JOIN favorite_projects f ON f.project_id = p.id AND f.user_id = $1
+		LEFT JOIN last l ON l.project_id = p.id
+		LEFT JOIN tasks tk ON tk.id = l.task_id
+		LEFT JOIN counts n ON n.project_id = p.id
+		WHERE (f.user_id IS NOT NULL OR l.project_id IS NOT NULL)
+		  AND p.archived_at IS NULL
+		  AND s.accepts_time
+		  AND (NOT $2::boolean OR EXISTS (
+			SELECT 1 FROM project_members pm WHERE pm.project_id = p.id AND pm.user_id = $1
+		  ))`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, membersOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []QuickStartProject
+	for rows.Next() {
+		var p QuickStartProject
+		var clientID, taskID *int64
+		var clientName, taskName *string
+		err := rows.Scan(
+			&p.ProjectId,
+			&p.Name,
+			&clientID,
+			&clientName,
+			&p.Favorite,
+			&taskID,
+			&taskName,
+			&p.LastNote,
+			&p.LastTags,
+			&p.LastUsedAt,
+			&p.RecentSessions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if clientID != nil {
+			p.Client = &ClientRef{Id: *clientID, Name: *clientName}
+		}
+		if taskID != nil {
+			p.LastTask = &TaskRef{Id: *taskID, Name: *taskName}
+		}
+		all = append(all, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := &QuickStart{Favorites: []QuickStartProject{}, Recent: []QuickStartProject{}, Frequent: []QuickStartProject{}}
+	for _, p := range all {
+		if p.Favorite {
+			out.Favorites = append(out.Favorites, p)
+		}
+		if p.LastUsedAt != nil {
+			out.Recent = append(out.Recent, p)
+		}
+		if p.RecentSessions > 0 {
+			out.Frequent = append(out.Frequent, p)
+		}
+	}
+
+	sort.Slice(out.Favorites, func(i, j int) bool {
+		return strings.ToLower(out.Favorites[i].Name) < strings.ToLower(out.Favorites[j].Name)
+	})
+	sort.Slice(out.Recent, func(i, j int) bool {
+		return out.Recent[i].LastUsedAt.After(*out.Recent[j].LastUsedAt)
+	})
+	sort.Slice(out.Frequent, func(i, j int) bool {
+		a, b := out.Frequent[i], out.Frequent[j]
+		if a.RecentSessions != b.RecentSessions {
+			return a.RecentSessions > b.RecentSessions
+		}
+		return a.LastUsedAt.After(*b.LastUsedAt)
+	})
+
+	if len(out.Recent) > limit {
+		out.Recent = out.Recent[:limit]
+	}
+	if len(out.Frequent) > limit {
+		out.Frequent = out.Frequent[:limit]
+	}
+	return out, nil
+}
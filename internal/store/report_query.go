@@ -0,0 +1,435 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ReportFormatFlat   = "flat"
+	ReportFormatNested = "nested"
+
+	maxReportDimensions = 4
+	maxReportMetrics    = 4
+)
+
+// reportDimension is one allowed group-by dimension. Columns are SQL expressions
+// taken from the allow-list only; Keys are the JSON names of their values.
+type reportDimension struct {
+	Columns []string
+	Keys    []string
+}
+
+// ReportDimensions is the allow-list of group-by dimensions.
+// Dates are taken from the session start in the query's time zone ($tz).
+var ReportDimensions = map[string]reportDimension{
+	"user":           {Columns: []string{"u.id", "u.name"}, Keys: []string{"user_id", "user_name"}},
+	"client":         {Columns: []string{"c.id", "c.name"}, Keys: []string{"client_id", "client_name"}},
+	"project":        {Columns: []string{"p.id", "p.name"}, Keys: []string{"project_id", "project_name"}},
+	"task":           {Columns: []string{"tk.id", "tk.name"}, Keys: []string{"task_id", "task_name"}},
+	"project_status": {Columns: []string{"COALESCE(s.name, '')"}, Keys: []string{"project_status"}},
+	"day":            {Columns: []string{"to_char(ws.start_at AT TIME ZONE $tz, 'YYYY-MM-DD')"}, Keys: []string{"day"}},
+	"week":           {Columns: []string{`to_char(date_trunc('week', ws.start_at AT TIME ZONE $tz), 'IYYY-"W"IW')`}, Keys: []string{"week"}},
+	"month":          {Columns: []string{"to_char(ws.start_at AT TIME ZONE $tz, 'YYYY-MM')"}, Keys: []string{"month"}},
+	"tag":            {Columns: []string{"t.tag"}, Keys: []string{"tag"}},
+	"billable":       {Columns: []string{"p.billable"}, Keys: []string{"billable"}},
+}
+
+// customFieldDimensions are the prefixes of the custom field dimensions, such as
+// "field.ticket" for a session field or "project_field.cost_centre" for a project field,
+// with the entity of their fields and the column holding their values.
+var customFieldDimensions = map[string]struct{ entity, column string }{
+	"field.":         {CustomFieldEntitySession, "ws.custom_fields"},
+	"project_field.": {CustomFieldEntityProject, "p.custom_fields"},
+}
+
+// lookupReportDimension finds an allow-listed dimension or builds a custom field one.
+// Field keys are checked against their format, so they can be SQL literals.
+func lookupReportDimension(name string) (reportDimension, bool) {
+	if dim, ok := ReportDimensions[name]; ok {
+		return dim, true
+	}
+	for prefix, dim := range customFieldDimensions {
+		key, ok := strings.CutPrefix(name, prefix)
+		if ok && ValidCustomFieldKey(key) {
+			return reportDimension{Columns: []string{dim.column + "->>'" + key + "'"}, Keys: []string{name}}, true
+		}
+	}
+	return reportDimension{}, false
+}
+
+// CustomFieldDimension returns the entity and key of the field a custom field dimension
+// names. It doesn't check that the field exists.
+func CustomFieldDimension(name string) (entity, key string, ok bool) {
+	for prefix, dim := range customFieldDimensions {
+		key, ok := strings.CutPrefix(name, prefix)
+		if ok && ValidCustomFieldKey(key) {
+			return dim.entity, key, true
+		}
+	}
+	return "", "", false
+}
+
+// ReportDimensionKeys returns the JSON names of a dimension's values.
+func ReportDimensionKeys(name string) []string {
+	dim, _ := lookupReportDimension(name)
+	return dim.Keys
+}
+
+// ReportMetrics is the allow-list of metrics; $seconds is the summed session length.
+var ReportMetrics = map[string]string{
+	"seconds":             `$seconds::bigint`,
+	"sessions":            `COUNT(DISTINCT ws.id)`,
+	"avg_session_seconds": `COALESCE(ROUND($seconds / NULLIF(COUNT(DISTINCT ws.id), 0)), 0)::bigint`,
+	"distinct_users":      `COUNT(DISTINCT ws.user_id)`,
+}
+
+const sessionSecondsSum = `COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at)))%s, 0)`
+
+// sessionSeconds sums each session once. With the tag dimension a session is joined
+// once per tag, so where the tag is rolled up only its first tag row counts.
+func sessionSeconds(byTag bool) string {
+	if !byTag {
+		return fmt.Sprintf(sessionSecondsSum, "")
+	}
+	return fmt.Sprintf("(CASE WHEN GROUPING(t.tag) = 0 THEN %s ELSE %s END)",
+		fmt.Sprintf(sessionSecondsSum, ""),
+		fmt.Sprintf(sessionSecondsSum, " FILTER (WHERE COALESCE(t.ord, 1) = 1)"))
+}
+
+// ReportQuery describes a custom report: sessions started in [From, To) are grouped by
+// Dimensions, in order, and measured by Metrics. Empty filter slices mean "any".
+type ReportQuery struct {
+	Dimensions []string
+	Metrics    []string
+	From       time.Time // local midnight of the first day
+	To         time.Time // local midnight after the last day
+
+	UserIDs    []int64
+	ProjectIDs []int64
+	TeamIDs    []int64
+	Tags       []string // sessions having any of the tags
+	Billable   *bool
+
+	Format string
+	Sort   string // a metric or dimension, "-" prefix for descending
+	Limit  int
+}
+
+// Validate checks dimensions, metrics and sort against the allow-lists.
+func (q *ReportQuery) Validate() error {
+	if len(q.Dimensions) > maxReportDimensions {
+		return fmt.Errorf("at most %d dimensions are allowed", maxReportDimensions)
+	}
+	if len(q.Metrics) == 0 || len(q.Metrics) > maxReportMetrics {
+		return fmt.Errorf("between 1 and %d metrics are required", maxReportMetrics)
+	}
+
+	seen := map[string]bool{}
+	for _, d := range q.Dimensions {
+		if _, ok := lookupReportDimension(d); !ok {
+			return fmt.Errorf("invalid dimension: %s", d)
+		}
+		if seen[d] {
+			return fmt.Errorf("duplicate dimension: %s", d)
+		}
+		seen[d] = true
+	}
+	for _, m := range q.Metrics {
+		if _, ok := ReportMetrics[m]; !ok {
+			return fmt.Errorf("invalid metric: %s", m)
+		}
+		if seen[m] {
+			return fmt.Errorf("duplicate metric: %s", m)
+		}
+		seen[m] = true
+	}
+
+	if q.Format != ReportFormatFlat && q.Format != ReportFormatNested {
+		return errors.New("format must be flat or nested")
+	}
+
+	f := Filter{Sort: q.Sort}
+	f.SortSafeList = append(f.SortSafeList, q.Dimensions...)
+	f.SortSafeList = append(f.SortSafeList, q.Metrics...)
+	return f.validateSort()
+}
+
+// ReportRow holds dimension values and metrics by JSON key. Nested rows keep
+// their sub-groups under "groups".
+type ReportRow map[string]any
+
+type ReportResult struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TimeZone string `json:"tz"`
+
+	Dimensions []string `json:"dimensions"`
+	Metrics    []string `json:"metrics"`
+	Format     string   `json:"format"`
+
+	Rows      []ReportRow `json:"rows"`
+	Totals    ReportRow   `json:"totals"`
+	Truncated bool        `json:"truncated"`
+}
+
+type ReportStore interface {
+	RunReportQuery(ctx context.Context, q ReportQuery) (*ReportResult, error)
+}
+
+type PostgresReportStore struct {
+	db *sql.DB
+}
+
+func NewPostgresReportStore(db *sql.DB) *PostgresReportStore {
+	return &PostgresReportStore{db: db}
+}
+
+// RunReportQuery builds the query from the allow-lists only; every user value is a parameter.
+// GROUP BY ROLLUP gives the subtotals of each dimension prefix and the grand total in one pass.
+func (pg *PostgresReportStore) RunReportQuery(ctx context.Context, q ReportQuery) (*ReportResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	loc := q.From.Location()
+
+	byTag, byDate := false, false
+	for _, d := range q.Dimensions {
+		byTag = byTag || d == "tag"
+		byDate = byDate || d == "day" || d == "week" || d == "month"
+	}
+
+	args := []any{
+		q.From, q.To,
+		nonNilInt64s(q.UserIDs), nonNilInt64s(q.ProjectIDs), nonNilInt64s(q.TeamIDs),
+		tagsArg(q.Tags), q.Billable,
+	}
+	tzParam := ""
+	if byDate {
+		args = append(args, loc.String())
+		tzParam = fmt.Sprintf("$%d", len(args))
+	}
+
+	var selectCols, groupSets, groupings []string
+	for _, d := range q.Dimensions {
+		dim, _ := lookupReportDimension(d)
+		selectCols = append(selectCols, dim.Columns...)
+		groupSets = append(groupSets, "("+strings.Join(dim.Columns, ", ")+")")
+		groupings = append(groupings, "GROUPING("+dim.Columns[0]+")")
+	}
+	for _, m := range q.Metrics {
+		selectCols = append(selectCols, strings.ReplaceAll(ReportMetrics[m], "$seconds", sessionSeconds(byTag)))
+	}
+
+	// the level of a row is how many leading dimensions it is grouped by
+	level := "0"
+	if len(groupings) > 0 {
+		level = fmt.Sprintf("%d - (%s)", len(groupings), strings.Join(groupings, " + "))
+	}
+	selectCols = append(selectCols, level+" AS level")
+
+	groupBy := "GROUP BY ()"
+	if len(groupSets) > 0 {
+		groupBy = "GROUP BY ROLLUP(" + strings.Join(groupSets, ", ") + ")"
+	}
+
+	// a session without tags still gets one row from the lateral join
+	tagJoin := ""
+	if byTag {
+		tagJoin = "LEFT JOIN LATERAL unnest(ws.tags) WITH ORDINALITY AS t(tag, ord) ON TRUE"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM work_sessions ws
+		JOIN users u ON u.id = ws.user_id
+		JOIN projects p ON p.id = ws.project_id
+		LEFT JOIN statuses s ON s.id = p.status_id
+		LEFT JOIN clients c ON c.id = p.client_id
+		LEFT JOIN tasks tk ON tk.id = ws.task_id
+		%s
+		WHERE ws.start_at >= $1 AND ws.start_at < $2
+		  AND (cardinality($3::bigint[]) = 0 OR ws.user_id = ANY($3))
+		  AND (cardinality($4::bigint[]) = 0 OR ws.project_id = ANY($4))
+		  AND (cardinality($5::bigint[]) = 0 OR u.team_id = ANY($5))
+		  AND (cardinality($6::text[]) = 0 OR ws.tags && $6)
+		  AND ($7::boolean IS NULL OR p.billable = $7)
+		%s
+		ORDER BY level`, strings.Join(selectCols, ",\n\t\t\t"), tagJoin, groupBy)
+	query = strings.ReplaceAll(query, "$tz", tzParam)
+
+	for i := range selectCols[:len(selectCols)-len(q.Metrics)-1] {
+		query += fmt.Sprintf(", %d NULLS LAST", i+1)
+	}
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &ReportResult{
+		From:       q.From.Format(time.DateOnly),
+		To:         q.To.AddDate(0, 0, -1).Format(time.DateOnly),
+		TimeZone:   loc.String(),
+		Dimensions: q.Dimensions,
+		Metrics:    q.Metrics,
+		Format:     q.Format,
+		Rows:       []ReportRow{},
+	}
+
+	// nodes by the path of their dimension values, for attaching sub-groups
+	nodes := map[string]ReportRow{}
+
+	for rows.Next() {
+		values := make([]any, len(selectCols))
+		dest := make([]any, len(selectCols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		lvl := int(values[len(values)-1].(int64))
+
+		row := ReportRow{}
+		path := ""
+		parentPath := ""
+		col := 0
+		for i, d := range q.Dimensions {
+			dim, _ := lookupReportDimension(d)
+			for j, key := range dim.Keys {
+				if i < lvl {
+					row[key] = values[col+j]
+					path += fmt.Sprintf("%v\x00", values[col+j])
+				}
+			}
+			if i == lvl-2 {
+				parentPath = path
+			}
+			col += len(dim.Columns)
+		}
+		for i, m := range q.Metrics {
+			row[m] = values[col+i]
+		}
+
+		switch {
+		case lvl == 0:
+			res.Totals = row
+		case q.Format == ReportFormatFlat:
+			if lvl == len(q.Dimensions) {
+				res.Rows = append(res.Rows, row)
+			}
+		default:
+			if lvl < len(q.Dimensions) {
+				row["groups"] = []ReportRow{}
+			}
+			nodes[path] = row
+			if lvl == 1 {
+				res.Rows = append(res.Rows, row)
+			} else if parent := nodes[parentPath]; parent != nil {
+				parent["groups"] = append(parent["groups"].([]ReportRow), row)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if res.Totals == nil {
+		res.Totals = ReportRow{}
+		for _, m := range q.Metrics {
+			res.Totals[m] = int64(0)
+		}
+	}
+
+	if q.Sort != "" {
+		sortReportRows(res.Rows, q.Sort)
+	}
+
+	if q.Limit > 0 && len(res.Rows) > q.Limit {
+		res.Rows = res.Rows[:q.Limit]
+		res.Truncated = true
+	}
+
+	return res, nil
+}
+
+// sortReportRows sorts rows, and their sub-groups, by a metric or by a dimension's first key.
+func sortReportRows(rows []ReportRow, sortBy string) {
+	desc := strings.HasPrefix(sortBy, "-")
+	key := strings.TrimPrefix(sortBy, "-")
+	if dim, ok := lookupReportDimension(key); ok {
+		key = dim.Keys[0]
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := compareReportValues(rows[i][key], rows[j][key])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	for _, row := range rows {
+		if groups, ok := row["groups"].([]ReportRow); ok {
+			sortReportRows(groups, sortBy)
+		}
+	}
+}
+
+// compareReportValues orders the scanned column types; NULLs sort last.
+func compareReportValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return cmpOrdered(av, bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok && av != bv {
+			if av {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+func cmpOrdered(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// nonNilInt64s never passes NULL for an id list.
+func nonNilInt64s(ids []int64) []int64 {
+	if ids == nil {
+		return []int64{}
+	}
+	return ids
+}
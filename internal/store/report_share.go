@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+const (
+	ShareModeLive   = "live"
+	ShareModeFrozen = "frozen"
+
+	ShareAccessOK      = "ok"
+	ShareAccessExpired = "expired"
+	ShareAccessRevoked = "revoked"
+)
+
+type ReportShare struct {
+	Id          int64      `json:"id"`
+	ProjectId   int64      `json:"project_id"`
+	ProjectName string     `json:"project_name"`
+	CreatedBy   *int64     `json:"created_by"`
+	Title       string     `json:"title"`
+	Mode        string     `json:"mode"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	TimeZone    string     `json:"tz"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	Snapshot json.RawMessage `json:"-"`
+}
+
+// SharedReport is what a client sees through a share link: project totals by day,
+// without people, notes or anything else internal.
+type SharedReport struct {
+	Title       string    `json:"title"`
+	ProjectName string    `json:"project_name"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	TimeZone    string    `json:"tz"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	TotalSeconds int64    `json:"total_seconds"`
+	TotalHours   float64  `json:"total_hours"`
+	HourlyRate   *float64 `json:"hourly_rate,omitempty"`
+	Amount       *float64 `json:"amount,omitempty"`
+
+	Days []SharedReportDay `json:"days"`
+}
+
+type SharedReportDay struct {
+	Date    string  `json:"date"`
+	Seconds int64   `json:"seconds"`
+	Hours   float64 `json:"hours"`
+}
+
+type ShareAccess struct {
+	Id         int64     `json:"id"`
+	ShareId    int64     `json:"share_id"`
+	Outcome    string    `json:"outcome"`
+	Format     string    `json:"format"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+type ShareStore interface {
+	CreateReportShare(ctx context.Context, share *ReportShare) error
+	ListReportShares(ctx context.Context, projectID int64) ([]ReportShare, error)
+	GetReportShare(ctx context.Context, id int64) (*ReportShare, error)
+	RevokeReportShare(ctx context.Context, id int64) error
+
+	BuildSharedReport(ctx context.Context, projectID int64, from, to time.Time) (*SharedReport, error)
+
+	LogShareAccess(ctx context.Context, access *ShareAccess) error
+	ListShareAccesses(ctx context.Context, shareID int64) ([]ShareAccess, error)
+}
+
+type PostgresShareStore struct {
+	db *sql.DB
+}
+
+func NewPostgresShareStore(db *sql.DB) *PostgresShareStore {
+	return &PostgresShareStore{db: db}
+}
+
+func roundHours(seconds int64) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
+
+// BuildSharedReport totals the finished sessions of a project that started in [from, to),
+// by day in from's time zone. It returns nil when the project doesn't exist.
+func (pg *PostgresShareStore) BuildSharedReport(ctx context.Context, projectID int64, from, to time.Time) (*SharedReport, error) {
+	loc := from.Location()
+
+	rep := &SharedReport{
+		From:        from.Format(time.DateOnly),
+		To:          to.AddDate(0, 0, -1).Format(time.DateOnly),
+		TimeZone:    loc.String(),
+		GeneratedAt: time.Now().UTC(),
+		Days:        []SharedReportDay{},
+	}
+
+	err := pg.db.QueryRowContext(ctx, `SELECT name, hourly_rate FROM projects WHERE id = $1`, projectID).
+		Scan(&rep.ProjectName, &rep.HourlyRate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			to_char(ws.start_at AT TIME ZONE $4, 'YYYY-MM-DD') AS day,
+			SUM(EXTRACT(EPOCH FROM (ws.end_at - ws.start_at)))::bigint
+		FROM work_sessions ws
+		WHERE ws.project_id = $1
+		  AND ws.end_at IS NOT NULL
+		  AND ws.start_at >= $2 AND ws.start_at < $3
+		GROUP BY day
+		ORDER BY day`
+
+	rows, err := pg.db.QueryContext(ctx, query, projectID, from, to, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d SharedReportDay
+		if err := rows.Scan(&d.Date, &d.Seconds); err != nil {
+			return nil, err
+		}
+		d.Hours = roundHours(d.Seconds)
+		rep.TotalSeconds += d.Seconds
+		rep.Days = append(rep.Days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rep.TotalHours = roundHours(rep.TotalSeconds)
+	if rep.HourlyRate != nil {
+		hours, rate := float64(rep.TotalSeconds)/3600, *rep.HourlyRate
+		amount := math.Round(hours*rate*100) / 100
+		rep.Amount = &amount
+	}
+
+	return rep, nil
+}
+
+func (pg *PostgresShareStore) CreateReportShare(ctx context.Context, share *ReportShare) error {
+	query := `
+		INSERT INTO report_shares (project_id, created_by, title, mode, from_date, to_date, timezone, snapshot, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	var snapshot any // NULL for live shares
+	if len(share.Snapshot) > 0 {
+		snapshot = []byte(share.Snapshot)
+	}
+
+	return pg.db.QueryRowContext(ctx, query,
+		share.ProjectId, share.CreatedBy, share.Title, share.Mode, share.From, share.To,
+		share.TimeZone, snapshot, share.ExpiresAt,
+	).Scan(&share.Id, &share.CreatedAt)
+}
+
+const shareColumns = `
+	s.id, s.project_id, p.name, s.created_by, s.title, s.mode,
+	to_char(s.from_date, 'YYYY-MM-DD'), to_char(s.to_date, 'YYYY-MM-DD'), s.timezone,
+	s.expires_at, s.revoked_at, s.created_at, s.snapshot`
+
+func scanShare(row interface{ Scan(...any) error }) (*ReportShare, error) {
+	var s ReportShare
+	var snapshot []byte
+	err := row.Scan(
+		&s.Id, &s.ProjectId, &s.ProjectName, &s.CreatedBy, &s.Title, &s.Mode,
+		&s.From, &s.To, &s.TimeZone,
+		&s.ExpiresAt, &s.RevokedAt, &s.CreatedAt, &snapshot,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.Snapshot = snapshot
+	return &s, nil
+}
+
+func (pg *PostgresShareStore) ListReportShares(ctx context.Context, projectID int64) ([]ReportShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM report_shares s
+		JOIN projects p ON p.id = s.project_id
+		WHERE s.project_id = $1
+		ORDER BY s.created_at DESC, s.id DESC`
+
+	rows, err := pg.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ReportShare{}
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresShareStore) GetReportShare(ctx context.Context, id int64) (*ReportShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM report_shares s
+		JOIN projects p ON p.id = s.project_id
+		WHERE s.id = $1`
+
+	s, err := scanShare(pg.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// RevokeReportShare returns sql.ErrNoRows when the share doesn't exist or was already revoked.
+func (pg *PostgresShareStore) RevokeReportShare(ctx context.Context, id int64) error {
+	query := `
+		UPDATE report_shares
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	return execAffectingOne(ctx, pg.db, query, id)
+}
+
+func (pg *PostgresShareStore) LogShareAccess(ctx context.Context, access *ShareAccess) error {
+	query := `
+		INSERT INTO report_share_accesses (share_id, outcome, format, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, accessed_at`
+
+	return pg.db.QueryRowContext(ctx, query, access.ShareId, access.Outcome, access.Format, access.IP, access.UserAgent).
+		Scan(&access.Id, &access.AccessedAt)
+}
+
+func (pg *PostgresShareStore) ListShareAccesses(ctx context.Context, shareID int64) ([]ShareAccess, error) {
+	query := `
+		SELECT id, share_id, outcome, format, ip, user_agent, accessed_at
+		FROM report_share_accesses
+		WHERE share_id = $1
+		ORDER BY accessed_at DESC, id DESC`
+
+	rows, err := pg.db.QueryContext(ctx, query, shareID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ShareAccess{}
+	for rows.Next() {
+		var a ShareAccess
+		if err := rows.Scan(&a.Id, &a.ShareId, &a.Outcome, &a.Format, &a.IP, &a.UserAgent, &a.AccessedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+
+	return out, rows.Err()
+}
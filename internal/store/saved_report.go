@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// saved report periods: the dates a run covers, relative to the run time
+const (
+	SavedReportPreviousWeek  = "previous_week"
+	SavedReportPreviousMonth = "previous_month"
+	SavedReportLast7Days     = "last_7_days"
+	SavedReportLast30Days    = "last_30_days"
+	SavedReportWeekToDate    = "week_to_date"
+	SavedReportMonthToDate   = "month_to_date"
+
+	SavedReportOutputCSV  = "csv"
+	SavedReportOutputJSON = "json"
+
+	ReportRunSchedule = "schedule"
+	ReportRunManual   = "manual"
+)
+
+type SavedReport struct {
+	Id         int64           `json:"id"`
+	UserId     int64           `json:"user_id"`
+	Name       string          `json:"name"`
+	Definition json.RawMessage `json:"definition"`
+	Period     string          `json:"period"`
+	Output     string          `json:"output"`
+	TimeZone   string          `json:"tz"`
+	Schedule   *string         `json:"schedule"`
+	Recipients []string        `json:"recipients"`
+	NextRunAt  *time.Time      `json:"next_run_at"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+
+	// the owner, who scheduled runs are made for
+	OwnerRole  string `json:"-"`
+	OwnerEmail string `json:"-"`
+}
+
+// ReportRun is one rendering of a saved report. Output is only loaded for downloads.
+type ReportRun struct {
+	Id            int64     `json:"id"`
+	SavedReportId int64     `json:"saved_report_id"`
+	Trigger       string    `json:"trigger"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	Delivery      string    `json:"delivery"`
+	DeliveryError string    `json:"delivery_error,omitempty"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int       `json:"size"`
+	Output        []byte    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SavedReportStore interface {
+	ListSavedReports(ctx context.Context, userID int64) ([]SavedReport, error)
+	GetSavedReport(ctx context.Context, id int64) (*SavedReport, error)
+	CreateSavedReport(ctx context.Context, sr *SavedReport) error
+	UpdateSavedReport(ctx context.Context, sr *SavedReport) error
+	DeleteSavedReport(ctx context.Context, id int64) error
+
+	ListDueSavedReports(ctx context.Context, now time.Time) ([]SavedReport, error)
+	ClaimSavedReportRun(ctx context.Context, id int64, due time.Time, next *time.Time) error
+
+	CreateReportRun(ctx context.Context, run *ReportRun) error
+	ListReportRuns(ctx context.Context, savedReportID int64) ([]ReportRun, error)
+	GetReportRun(ctx context.Context, savedReportID, runID int64) (*ReportRun, error)
+}
+
+type PostgresSavedReportStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSavedReportStore(db *sql.DB) *PostgresSavedReportStore {
+	return &PostgresSavedReportStore{db: db}
+}
+
+// SavedReportRange returns the local dates [from, to) that a period covers when run at now.
+// Weeks start on Monday. ok is false for an unknown period.
+func SavedReportRange(period string, now time.Time) (from, to time.Time, ok bool) {
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	weekStart := today.AddDate(0, 0, 1-isoWeekday(today))
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+
+	switch period {
+	case SavedReportPreviousWeek:
+		return weekStart.AddDate(0, 0, -7), weekStart, true
+	case SavedReportPreviousMonth:
+		return monthStart.AddDate(0, -1, 0), monthStart, true
+	case SavedReportLast7Days:
+		return today.AddDate(0, 0, -7), today, true
+	case SavedReportLast30Days:
+		return today.AddDate(0, 0, -30), today, true
+	case SavedReportWeekToDate:
+		return weekStart, today.AddDate(0, 0, 1), true
+	case SavedReportMonthToDate:
+		return monthStart, today.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+const savedReportColumns = `
+	sr.id, sr.user_id, sr.name, sr.definition, sr.period, sr.output, sr.timezone,
+	sr.schedule, to_json(sr.recipients), sr.next_run_at, sr.created_at, sr.updated_at,
+	u.role, u.email`
+
+func scanSavedReport(row interface{ Scan(...any) error }) (*SavedReport, error) {
+	var sr SavedReport
+	var definition []byte
+	err := row.Scan(
+		&sr.Id, &sr.UserId, &sr.Name, &definition, &sr.Period, &sr.Output, &sr.TimeZone,
+		&sr.Schedule, (*Tags)(&sr.Recipients), &sr.NextRunAt, &sr.CreatedAt, &sr.UpdatedAt,
+		&sr.OwnerRole, &sr.OwnerEmail,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sr.Definition = definition
+	return &sr, nil
+}
+
+func (pg *PostgresSavedReportStore) listSavedReports(ctx context.Context, query string, args ...any) ([]SavedReport, error) {
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SavedReport{}
+	for rows.Next() {
+		sr, err := scanSavedReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sr)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresSavedReportStore) ListSavedReports(ctx context.Context, userID int64) ([]SavedReport, error) {
+	query := `
+		SELECT ` + savedReportColumns + `
+		FROM saved_reports sr
+		JOIN users u ON u.id = sr.user_id
+		WHERE sr.user_id = $1
+		ORDER BY sr.name, sr.id`
+
+	return pg.listSavedReports(ctx, query, userID)
+}
+
+func (pg *PostgresSavedReportStore) GetSavedReport(ctx context.Context, id int64) (*SavedReport, error) {
+	query := `
+		SELECT ` + savedReportColumns + `
+		FROM saved_reports sr
+		JOIN users u ON u.id = sr.user_id
+		WHERE sr.id = $1`
+
+	sr, err := scanSavedReport(pg.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sr, err
+}
+
+func (pg *PostgresSavedReportStore) CreateSavedReport(ctx context.Context, sr *SavedReport) error {
+	query := `
+		INSERT INTO saved_reports (user_id, name, definition, period, output, timezone, schedule, recipients, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`
+
+	return pg.db.QueryRowContext(ctx, query,
+		sr.UserId, sr.Name, []byte(sr.Definition), sr.Period, sr.Output, sr.TimeZone,
+		sr.Schedule, tagsArg(sr.Recipients), sr.NextRunAt,
+	).Scan(&sr.Id, &sr.CreatedAt, &sr.UpdatedAt)
+}
+
+func (pg *PostgresSavedReportStore) UpdateSavedReport(ctx context.Context, sr *SavedReport) error {
+	query := `
+		UPDATE saved_reports
+		SET name = $2, definition = $3, period = $4, output = $5, timezone = $6,
+		    schedule = $7, recipients = $8, next_run_at = $9, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return pg.db.QueryRowContext(ctx, query,
+		sr.Id, sr.Name, []byte(sr.Definition), sr.Period, sr.Output, sr.TimeZone,
+		sr.Schedule, tagsArg(sr.Recipients), sr.NextRunAt,
+	).Scan(&sr.UpdatedAt)
+}
+
+func (pg *PostgresSavedReportStore) DeleteSavedReport(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM saved_reports WHERE id = $1`, id)
+}
+
+// ListDueSavedReports returns scheduled reports of active users whose next run is due.
+func (pg *PostgresSavedReportStore) ListDueSavedReports(ctx context.Context, now time.Time) ([]SavedReport, error) {
+	query := `
+		SELECT ` + savedReportColumns + `
+		FROM saved_reports sr
+		JOIN users u ON u.id = sr.user_id
+		WHERE sr.next_run_at <= $1
+		  AND u.is_active = TRUE
+		ORDER BY sr.next_run_at, sr.id`
+
+	return pg.listSavedReports(ctx, query, now)
+}
+
+// ClaimSavedReportRun moves a due report to its next run. It returns sql.ErrNoRows
+// when the run was already claimed, so every scheduled run happens once.
+func (pg *PostgresSavedReportStore) ClaimSavedReportRun(ctx context.Context, id int64, due time.Time, next *time.Time) error {
+	query := `
+		UPDATE saved_reports
+		SET next_run_at = $3
+		WHERE id = $1 AND next_run_at = $2`
+
+	return execAffectingOne(ctx, pg.db, query, id, due, next)
+}
+
+func (pg *PostgresSavedReportStore) CreateReportRun(ctx context.Context, run *ReportRun) error {
+	query := `
+		INSERT INTO saved_report_runs
+			(saved_report_id, trigger, from_date, to_date, status, error, delivery, delivery_error, filename, content_type, output)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`
+
+	run.Size = len(run.Output)
+	return pg.db.QueryRowContext(ctx, query,
+		run.SavedReportId, run.Trigger, run.From, run.To, run.Status, run.Error,
+		run.Delivery, run.DeliveryError, run.Filename, run.ContentType, run.Output,
+	).Scan(&run.Id, &run.CreatedAt)
+}
+
+const reportRunColumns = `
+	id, saved_report_id, trigger, to_char(from_date, 'YYYY-MM-DD'), to_char(to_date, 'YYYY-MM-DD'),
+	status, error, delivery, delivery_error, filename, content_type, COALESCE(octet_length(output), 0), created_at`
+
+func (pg *PostgresSavedReportStore) ListReportRuns(ctx context.Context, savedReportID int64) ([]ReportRun, error) {
+	query := `
+		SELECT ` + reportRunColumns + `
+		FROM saved_report_runs
+		WHERE saved_report_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := pg.db.QueryContext(ctx, query, savedReportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ReportRun{}
+	for rows.Next() {
+		var run ReportRun
+		if err := rows.Scan(
+			&run.Id, &run.SavedReportId, &run.Trigger, &run.From, &run.To,
+			&run.Status, &run.Error, &run.Delivery, &run.DeliveryError,
+			&run.Filename, &run.ContentType, &run.Size, &run.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+
+	return out, rows.Err()
+}
+
+// GetReportRun returns a run with its output, or nil when it isn't a run of the report.
+func (pg *PostgresSavedReportStore) GetReportRun(ctx context.Context, savedReportID, runID int64) (*ReportRun, error) {
+	query := `
+		SELECT ` + reportRunColumns + `, output
+		FROM saved_report_runs
+		WHERE saved_report_id = $1 AND id = $2`
+
+	var run ReportRun
+	err := pg.db.QueryRowContext(ctx, query, savedReportID, runID).Scan(
+		&run.Id, &run.SavedReportId, &run.Trigger, &run.From, &run.To,
+		&run.Status, &run.Error, &run.Delivery, &run.DeliveryError,
+		&run.Filename, &run.ContentType, &run.Size, &run.CreatedAt, &run.Output,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type PostgresSessionDraftStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSessionDraftStore(db *sql.DB) *PostgresSessionDraftStore {
+	return &PostgresSessionDraftStore{db: db}
+}
+
+type CalendarImportRule struct {
+	Id         int64     `json:"id"`
+	UserId     int64     `json:"user_id"`
+	MatchField string    `json:"match_field"` // summary | category
+	Pattern    string    `json:"pattern"`
+	ProjectId  int64     `json:"project_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type SessionDraft struct {
+	Id        int64     `json:"id"`
+	UserId    int64     `json:"user_id"`
+	ProjectId *int64    `json:"project_id"` // nil when no rule matched
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Note      string    `json:"note"`
+	SourceUID string    `json:"source_uid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SessionDraftStore interface {
+	ListImportRules(ctx context.Context, userID int64) ([]CalendarImportRule, error)
+	CreateImportRule(ctx context.Context, rule *CalendarImportRule) error
+	DeleteImportRule(ctx context.Context, id, userID int64) error
+
+	CreateDrafts(ctx context.Context, drafts []SessionDraft) (int, error)
+	ListDrafts(ctx context.Context, userID int64) ([]SessionDraft, error)
+	GetDrafts(ctx context.Context, userID int64, ids []int64) ([]SessionDraft, error)
+	UpdateDraft(ctx context.Context, draft *SessionDraft) error
+	DeleteDrafts(ctx context.Context, userID int64, ids []int64) (int, error)
+	ConfirmDraft(ctx context.Context, draft *SessionDraft, custom CustomValues) (*WorkSession, error)
+}
+
+func (pg *PostgresSessionDraftStore) ListImportRules(ctx context.Context, userID int64) ([]CalendarImportRule, error) {
+	query := `
+		SELECT id, user_id, match_field, pattern, project_id, created_at
+		FROM calendar_import_rules
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []CalendarImportRule{}
+	for rows.Next() {
+		var rule CalendarImportRule
+		if err := rows.Scan(&rule.Id, &rule.UserId, &rule.MatchField, &rule.Pattern, &rule.ProjectId, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, rule)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresSessionDraftStore) CreateImportRule(ctx context.Context, rule *CalendarImportRule) error {
+	query := `
+		INSERT INTO calendar_import_rules (user_id, match_field, pattern, project_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return pg.db.QueryRowContext(ctx, query, rule.UserId, rule.MatchField, rule.Pattern, rule.ProjectId).
+		Scan(&rule.Id, &rule.CreatedAt)
+}
+
+func (pg *PostgresSessionDraftStore) DeleteImportRule(ctx context.Context, id, userID int64) error {
+	res, err := pg.db.ExecContext(ctx, `DELETE FROM calendar_import_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateDrafts inserts drafts in one transaction and returns how many were new.
+// Drafts already imported from the same event (source_uid + start_at) are skipped.
+func (pg *PostgresSessionDraftStore) CreateDrafts(ctx context.Context, drafts []SessionDraft) (int, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO session_drafts (user_id, project_id, start_at, end_at, note, source_uid)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, source_uid, start_at) DO NOTHING`
+
+	created := 0
+	for _, d := range drafts {
+		res, err := tx.ExecContext(ctx, query, d.UserId, d.ProjectId, d.StartAt, d.EndAt, d.Note, d.SourceUID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		created += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+const draftColumns = `id, user_id, project_id, start_at, end_at, note, source_uid, created_at`
+
+func scanDrafts(rows *sql.Rows) ([]SessionDraft, error) {
+	defer rows.Close()
+
+	out := []SessionDraft{}
+	for rows.Next() {
+		var d SessionDraft
+		if err := rows.Scan(&d.Id, &d.UserId, &d.ProjectId, &d.StartAt, &d.EndAt, &d.Note, &d.SourceUID, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresSessionDraftStore) ListDrafts(ctx context.Context, userID int64) ([]SessionDraft, error) {
+	query := `SELECT ` + draftColumns + ` FROM session_drafts WHERE user_id = $1 ORDER BY start_at, id`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanDrafts(rows)
+}
+
+func (pg *PostgresSessionDraftStore) GetDrafts(ctx context.Context, userID int64, ids []int64) ([]SessionDraft, error) {
+	query := `SELECT ` + draftColumns + ` FROM session_drafts WHERE user_id = $1 AND id = ANY($2) ORDER BY start_at, id`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	return scanDrafts(rows)
+}
+
+func (pg *PostgresSessionDraftStore) UpdateDraft(ctx context.Context, draft *SessionDraft) error {
+	query := `
+		UPDATE session_drafts
+		SET project_id = $1, note = $2, start_at = $3, end_at = $4
+		WHERE id = $5 AND user_id = $6`
+
+	res, err := pg.db.ExecContext(ctx, query, draft.ProjectId, draft.Note, draft.StartAt, draft.EndAt, draft.Id, draft.UserId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresSessionDraftStore) DeleteDrafts(ctx context.Context, userID int64, ids []int64) (int, error) {
+	res, err := pg.db.ExecContext(ctx, `DELETE FROM session_drafts WHERE user_id = $1 AND id = ANY($2)`, userID, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := res.RowsAffected()
+	return int(rows), err
+}
+
+// ErrSessionOverlaps is returned when a confirmed draft would overlap another session of its user.
+var ErrSessionOverlaps = errors.New("session overlaps another session")
+
+// ConfirmDraft turns a draft into a work session with the given custom values and deletes
+// the draft, atomically. The caller validates the session first; the overlap with the
+// user's other sessions is checked here, in the same transaction as the insert.
+func (pg *PostgresSessionDraftStore) ConfirmDraft(ctx context.Context, draft *SessionDraft, custom CustomValues) (*WorkSession, error) {
+	if draft.ProjectId == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM session_drafts WHERE id = $1 AND user_id = $2`, draft.Id, draft.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if err := lockUserSessions(ctx, tx, draft.UserId); err != nil {
+		return nil, err
+	}
+	overlaps, err := txHasOverlap(ctx, tx, draft.UserId, draft.StartAt, draft.EndAt, 0)
+	if err != nil {
+		return nil, err
+	}
+	if overlaps {
+		return nil, ErrSessionOverlaps
+	}
+
+	end := draft.EndAt
+	ws := &WorkSession{
+		UserId:       draft.UserId,
+		ProjectId:    *draft.ProjectId,
+		StartAt:      draft.StartAt,
+		EndAt:        &end,
+		Note:         draft.Note,
+		CustomFields: custom,
+	}
+
+	customArg, err := customValuesArg(custom)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO work_sessions (user_id, project_id, note, start_at, end_at, custom_fields, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at`,
+		ws.UserId, ws.ProjectId, ws.Note, ws.StartAt, ws.EndAt, customArg,
+	).Scan(&ws.Id, &ws.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PostgresImportStore struct {
+	db *sql.DB
+}
+
+func NewPostgresImportStore(db *sql.DB) *PostgresImportStore {
+	return &PostgresImportStore{db: db}
+}
+
+// ImportSessionRow is one parsed CSV line, before users and projects are resolved.
+type ImportSessionRow struct {
+	Row         int
+	UserEmail   string
+	ProjectName string
+	StartAt     time.Time
+	EndAt       time.Time
+	Note        string
+}
+
+type ImportOptions struct {
+	DryRun         bool
+	CreateProjects bool
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun          bool             `json:"dry_run"`
+	TotalRows       int              `json:"total_rows"`
+	Imported        int              `json:"imported"` // in dry-run: rows that would be imported
+	Skipped         int              `json:"skipped"`  // exact duplicates of existing sessions
+	CreatedProjects []string         `json:"created_projects"`
+	Errors          []ImportRowError `json:"errors"`
+}
+
+type ImportStore interface {
+	ImportSessions(ctx context.Context, rows []ImportSessionRow, opts ImportOptions) (*ImportReport, error)
+}
+
+// ImportSessions resolves users by email and projects by name, then inserts all rows
+// in one transaction. Nothing is committed when opts.DryRun is set or when any row fails,
+// so the report of a dry run matches what the real run will do.
+func (pg *PostgresImportStore) ImportSessions(ctx context.Context, rows []ImportSessionRow, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{
+		DryRun:          opts.DryRun,
+		TotalRows:       len(rows),
+		CreatedProjects: []string{},
+		Errors:          []ImportRowError{},
+	}
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	users, err := importLookup(ctx, tx, `SELECT id, LOWER(email) FROM users WHERE LOWER(email) = ANY($1)`, rows, func(r ImportSessionRow) string {
+		return r.UserEmail
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	projectName := func(r ImportSessionRow) string {
+		return r.ProjectName
+	}
+
+	// of projects sharing a name, the oldest one that isn't archived
+	projects, err := importLookup(ctx, tx, `
+		SELECT COALESCE(MIN(id) FILTER (WHERE archived_at IS NULL), MIN(id)), LOWER(name)
+		FROM projects
+		WHERE LOWER(name) = ANY($1)
+		GROUP BY LOWER(name)`, rows, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := importLookup(ctx, tx, `
+		SELECT MIN(id), LOWER(name)
+		FROM projects
+		WHERE LOWER(name) = ANY($1)
+		GROUP BY LOWER(name)
+		HAVING bool_and(archived_at IS NOT NULL)`, rows, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var activeStatusID int64
+	if opts.CreateProjects {
+		err := tx.QueryRowContext(ctx, `SELECT id FROM statuses WHERE name = 'active'`).Scan(&activeStatusID)
+		if err != nil {
+			return nil, fmt.Errorf("import: active status: %w", err)
+		}
+	}
+
+	required, err := requiredSessionField(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	insertQuery := `
+		INSERT INTO work_sessions (user_id, project_id, note, start_at, end_at, created_at)
+		SELECT $1, $2, $3, $4, $5, NOW()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM work_sessions
+			WHERE user_id = $1 AND project_id = $2 AND start_at = $4 AND end_at = $5
+		)
+	`
+
+	for _, row := range rows {
+		userID, ok := users[strings.ToLower(row.UserEmail)]
+		if !ok {
+			report.Errors = append(report.Errors, ImportRowError{Row: row.Row, Error: "user not found: " + row.UserEmail})
+			continue
+		}
+
+		projectKey := strings.ToLower(row.ProjectName)
+		projectID, ok := projects[projectKey]
+		if !ok {
+			if !opts.CreateProjects {
+				report.Errors = append(report.Errors, ImportRowError{Row: row.Row, Error: "project not found: " + row.ProjectName})
+				continue
+			}
+
+			err := tx.QueryRowContext(ctx,
+				`INSERT INTO projects (name, status_id) VALUES ($1, $2) RETURNING id`,
+				row.ProjectName, activeStatusID,
+			).Scan(&projectID)
+			if err != nil {
+				return nil, err
+			}
+			projects[projectKey] = projectID
+			report.CreatedProjects = append(report.CreatedProjects, row.ProjectName)
+		}
+		if _, ok := archived[projectKey]; ok {
+			report.Errors = append(report.Errors, ImportRowError{Row: row.Row, Error: "project is archived: " + row.ProjectName})
+			continue
+		}
+
+		if required != "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: row.Row, Error: "custom field " + required + " is required"})
+			continue
+		}
+
+		res, err := tx.ExecContext(ctx, insertQuery, userID, projectID, row.Note, row.StartAt, row.EndAt)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			report.Skipped++
+			continue
+		}
+		report.Imported++
+	}
+
+	if opts.DryRun || len(report.Errors) > 0 {
+		if len(report.Errors) > 0 && !opts.DryRun {
+			report.Imported = 0
+			report.Skipped = 0
+			report.CreatedProjects = []string{}
+		}
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// importLookup maps lower-cased keys (emails, project names) to ids.
+func importLookup(ctx context.Context, tx *sql.Tx, query string, rows []ImportSessionRow, key func(ImportSessionRow) string) (map[string]int64, error) {
+	seen := map[string]bool{}
+	var keys []string
+	for _, r := range rows {
+		k := strings.ToLower(key(r))
+		if k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	out := map[string]int64{}
+	if len(keys) == 0 {
+		return out, nil
+	}
+
+	res, err := tx.QueryContext(ctx, query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var id int64
+		var k string
+		if err := res.Scan(&id, &k); err != nil {
+			return nil, err
+		}
+		out[k] = id
+	}
+
+	return out, res.Err()
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SessionSearch is the parsed form of the `search` query param of ListSessions.
+//
+// Supported syntax:
+//
+//	project:apollo user:alice "exact phrase" -excluded word -project:internal -user:bob
+//
+// project: and user: accept quoted values (project:"big launch"); negated they leave out
+// matching projects or users.
+// Everything else is matched against work_sessions.note with full-text search.
+type SessionSearch struct {
+	Project  string
+	User     string
+	Terms    []string
+	Phrases  []string
+	Excluded []string
+
+	ExcludedProjects []string
+	ExcludedUsers    []string
+}
+
+func ParseSessionSearch(raw string) SessionSearch {
+	var s SessionSearch
+
+	for _, tok := range tokenizeSearch(raw) {
+		switch {
+		case tok.qualifier == "project" && tok.negated:
+			s.ExcludedProjects = append(s.ExcludedProjects, tok.value)
+		case tok.qualifier == "user" && tok.negated:
+			s.ExcludedUsers = append(s.ExcludedUsers, tok.value)
+		case tok.qualifier == "project":
+			s.Project = tok.value
+		case tok.qualifier == "user":
+			s.User = tok.value
+		case tok.negated:
+			s.Excluded = append(s.Excluded, tok.value)
+		case tok.quoted:
+			s.Phrases = append(s.Phrases, tok.value)
+		default:
+			s.Terms = append(s.Terms, tok.value)
+		}
+	}
+
+	return s
+}
+
+func (s SessionSearch) IsEmpty() bool {
+	return s.Project == "" && s.User == "" && s.TextQuery() == "" &&
+		len(s.ExcludedProjects) == 0 && len(s.ExcludedUsers) == 0
+}
+
+// TextQuery rebuilds the note part of the search in websearch_to_tsquery syntax.
+// Quotes are stripped from the values, so user input can't change the query structure.
+func (s SessionSearch) TextQuery() string {
+	var parts []string
+
+	for _, t := range s.Terms {
+		if t = cleanSearchValue(t); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	for _, p := range s.Phrases {
+		if p = cleanSearchValue(p); p != "" {
+			parts = append(parts, `"`+p+`"`)
+		}
+	}
+	for _, e := range s.Excluded {
+		if e = cleanSearchValue(e); e != "" {
+			if strings.ContainsFunc(e, unicode.IsSpace) {
+				e = `"` + e + `"`
+			}
+			parts = append(parts, "-"+e)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+type searchToken struct {
+	qualifier string
+	value     string
+	quoted    bool
+	negated   bool
+}
+
+func tokenizeSearch(raw string) []searchToken {
+	var out []searchToken
+	rs := []rune(raw)
+
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+
+		var tok searchToken
+
+		if rs[i] == '-' {
+			tok.negated = true
+			i++
+		}
+
+		// qualifier, e.g. project: or user:
+		for _, q := range []string{"project", "user"} {
+			prefix := []rune(q + ":")
+			if hasRunePrefix(rs[i:], prefix) {
+				tok.qualifier = q
+				i += len(prefix)
+				break
+			}
+		}
+
+		if i < len(rs) && rs[i] == '"' {
+			tok.quoted = true
+			i++
+			start := i
+			for i < len(rs) && rs[i] != '"' {
+				i++
+			}
+			tok.value = string(rs[start:i])
+			if i < len(rs) {
+				i++ // closing quote
+			}
+		} else {
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) {
+				i++
+			}
+			tok.value = string(rs[start:i])
+		}
+
+		tok.value = strings.TrimSpace(tok.value)
+		if tok.value == "" {
+			continue
+		}
+		out = append(out, tok)
+	}
+
+	return out
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if unicode.ToLower(s[i]) != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func cleanSearchValue(v string) string {
+	v = strings.ReplaceAll(v, `"`, " ")
+	return strings.Join(strings.Fields(v), " ")
+}
+
+// likePatterns escapes each value and wraps it in prefix and suffix, for ILIKE ANY.
+// The result is never nil, so it binds as an empty array rather than NULL.
+func likePatterns(values []string, prefix, suffix string) []string {
+	out := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, prefix+escapeLike(v)+suffix)
+		}
+	}
+	return out
+}
+
+// escapeLike escapes ILIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -3,11 +3,16 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 )
 
 type Status struct {
-	Id   int64  `json:"id"`
-	Name string `json:"name"`
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	AcceptsTime bool   `json:"accepts_time"`
+
+	// Transitions are the statuses a project can move to from this one; empty means any.
+	Transitions []int64 `json:"transitions"`
 }
 
 type PostgresStatusStore struct {
@@ -23,13 +28,36 @@ func NewPostgresStatusStore(db *sql.DB) *PostgresStatusStore {
 type StatusStore interface {
 	GetStatusbyId(id int64) (*Status, error)
 	GetAllStatuses(ctx context.Context) ([]*Status, error)
+	CreateStatus(ctx context.Context, status *Status) error
+	UpdateStatus(ctx context.Context, id int64, name *string, acceptsTime *bool) error
+	DeleteStatus(ctx context.Context, id int64) error
+	SetStatusTransitions(ctx context.Context, id int64, to []int64) error
 }
 
-func (pg *PostgresStatusStore) GetStatusbyId(id int64) (*Status, error) {
+const statusColumns = `
+	s.id, s.name, s.accepts_time,
+	COALESCE((
+		SELECT json_agg(t.to_status_id ORDER BY t.to_status_id)
+		FROM status_transitions t
+		WHERE t.from_status_id = s.id
+	), '[]')`
+
+func scanStatus(scan func(dest ...any) error) (*Status, error) {
 	status := &Status{}
-	query := `SELECT id, name FROM statuses WHERE id = $1`
+	var transitions []byte
+	if err := scan(&status.Id, &status.Name, &status.AcceptsTime, &transitions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(transitions, &status.Transitions); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (pg *PostgresStatusStore) GetStatusbyId(id int64) (*Status, error) {
+	query := `SELECT ` + statusColumns + ` FROM statuses s WHERE s.id = $1`
 	row := pg.db.QueryRow(query, id)
-	err := row.Scan(&status.Id, &status.Name)
+	status, err := scanStatus(row.Scan)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -40,7 +68,7 @@ func (pg *PostgresStatusStore) GetStatusbyId(id int64) (*Status, error) {
 }
 
 func (pg *PostgresStatusStore) GetAllStatuses(ctx context.Context) ([]*Status, error) {
-	query := `SELECT id, name FROM statuses ORDER BY id`
+	query := `SELECT ` + statusColumns + ` FROM statuses s ORDER BY s.id`
 
 	rows, err := pg.db.QueryContext(ctx, query)
 	if err != nil {
@@ -50,8 +78,8 @@ func (pg *PostgresStatusStore) GetAllStatuses(ctx context.Context) ([]*Status, e
 
 	var statuses []*Status
 	for rows.Next() {
-		status := &Status{}
-		if err := rows.Scan(&status.Id, &status.Name); err != nil {
+		status, err := scanStatus(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 		statuses = append(statuses, status)
@@ -64,4 +92,56 @@ func (pg *PostgresStatusStore) GetAllStatuses(ctx context.Context) ([]*Status, e
 	return statuses, nil
 }
 
+func (pg *PostgresStatusStore) CreateStatus(ctx context.Context, status *Status) error {
+	status.Transitions = []int64{}
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO statuses (name, accepts_time) VALUES ($1, $2) RETURNING id`,
+		status.Name, status.AcceptsTime,
+	).Scan(&status.Id)
+}
 
+// UpdateStatus changes the fields that are set. It returns sql.ErrNoRows when the status doesn't exist.
+func (pg *PostgresStatusStore) UpdateStatus(ctx context.Context, id int64, name *string, acceptsTime *bool) error {
+	return execAffectingOne(ctx, pg.db, `
+		UPDATE statuses
+		SET name = COALESCE($2, name),
+		    accepts_time = COALESCE($3, accepts_time)
+		WHERE id = $1`,
+		id, name, acceptsTime)
+}
+
+// DeleteStatus returns sql.ErrNoRows when the status doesn't exist; a status that projects
+// still have fails on projects_status_id_fkey.
+func (pg *PostgresStatusStore) DeleteStatus(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM statuses WHERE id = $1`, id)
+}
+
+// SetStatusTransitions replaces the statuses a project can move to from this one.
+// It returns sql.ErrNoRows when the status doesn't exist.
+func (pg *PostgresStatusStore) SetStatusTransitions(ctx context.Context, id int64, to []int64) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// a missing status comes back as sql.ErrNoRows
+	var locked int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM statuses WHERE id = $1 FOR UPDATE`, id).Scan(&locked); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM status_transitions WHERE from_status_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO status_transitions (from_status_id, to_status_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING`,
+		id, nonNilInt64s(to),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
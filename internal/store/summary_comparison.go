@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// SummaryDelta compares the totals of one entity between two periods.
+// DeltaPercent is null when there was nothing in the previous period.
+type SummaryDelta struct {
+	CurrentSessions  int `json:"current_sessions"`
+	PreviousSessions int `json:"previous_sessions"`
+	DeltaSessions    int `json:"delta_sessions"`
+
+	CurrentSeconds  int64    `json:"current_seconds"`
+	PreviousSeconds int64    `json:"previous_seconds"`
+	DeltaSeconds    int64    `json:"delta_seconds"`
+	DeltaPercent    *float64 `json:"delta_percent"`
+}
+
+type ProjectComparison struct {
+	ProjectID   int64  `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	SummaryDelta
+}
+
+type UserComparison struct {
+	UserID    int64  `json:"user_id"`
+	UserName  string `json:"user_name"`
+	UserEmail string `json:"user_email"`
+	SummaryDelta
+
+	Projects []ProjectComparison `json:"projects"`
+}
+
+type ComparisonRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type SummaryComparison struct {
+	Current  ComparisonRange `json:"current"`
+	Previous ComparisonRange `json:"previous"`
+
+	Filters SummaryFilters `json:"filters"`
+	Overall SummaryDelta   `json:"overall"`
+
+	Users    []UserComparison    `json:"users"`
+	Projects []ProjectComparison `json:"projects"`
+}
+
+func (d *SummaryDelta) finish() {
+	d.DeltaSessions = d.CurrentSessions - d.PreviousSessions
+	d.DeltaSeconds = d.CurrentSeconds - d.PreviousSeconds
+	d.DeltaPercent = nil
+	if d.PreviousSeconds > 0 {
+		p := math.Round(float64(d.DeltaSeconds)/float64(d.PreviousSeconds)*1000) / 10
+		d.DeltaPercent = &p
+	}
+}
+
+// CompareSummaryReports runs the same summary filter over two ranges and returns the deltas
+// per user, per project (across users) and overall. Users and projects found in only one
+// of the periods are included with zeros for the other.
+func (pg *PostgresWorkSessionStore) CompareSummaryReports(ctx context.Context, current, previous SummaryRangeFilter) (*SummaryComparison, error) {
+	cur, err := pg.GetSummaryReport(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := pg.GetSummaryReport(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &SummaryComparison{
+		Current:  ComparisonRange{From: cur.From, To: cur.To},
+		Previous: ComparisonRange{From: prev.From, To: prev.To},
+		Filters:  cur.Filters,
+		Overall: SummaryDelta{
+			CurrentSessions:  cur.Overall.TotalSessions,
+			PreviousSessions: prev.Overall.TotalSessions,
+			CurrentSeconds:   int64(math.Round(cur.Overall.TotalSeconds)),
+			PreviousSeconds:  int64(math.Round(prev.Overall.TotalSeconds)),
+		},
+	}
+	out.Overall.finish()
+
+	users := map[int64]*UserComparison{}
+	userProjects := map[int64]map[int64]*ProjectComparison{}
+	projects := map[int64]*ProjectComparison{}
+
+	add := func(report *SummaryReport, isCurrent bool) {
+		for _, us := range report.Users {
+			u := users[us.UserID]
+			if u == nil {
+				u = &UserComparison{UserID: us.UserID, UserName: us.UserName, UserEmail: us.UserEmail}
+				users[us.UserID] = u
+				userProjects[us.UserID] = map[int64]*ProjectComparison{}
+			}
+			addToDelta(&u.SummaryDelta, us.TotalSessions, us.TotalSeconds, isCurrent)
+
+			for _, ps := range us.Projects {
+				up := userProjects[us.UserID][ps.ProjectID]
+				if up == nil {
+					up = &ProjectComparison{ProjectID: ps.ProjectID, ProjectName: ps.ProjectName}
+					userProjects[us.UserID][ps.ProjectID] = up
+				}
+				addToDelta(&up.SummaryDelta, ps.TotalSessions, ps.TotalSeconds, isCurrent)
+
+				p := projects[ps.ProjectID]
+				if p == nil {
+					p = &ProjectComparison{ProjectID: ps.ProjectID, ProjectName: ps.ProjectName}
+					projects[ps.ProjectID] = p
+				}
+				addToDelta(&p.SummaryDelta, ps.TotalSessions, ps.TotalSeconds, isCurrent)
+			}
+		}
+	}
+	add(prev, false)
+	add(cur, true)
+
+	out.Users = make([]UserComparison, 0, len(users))
+	for id, u := range users {
+		u.finish()
+		u.Projects = sortedProjectComparisons(userProjects[id])
+		out.Users = append(out.Users, *u)
+	}
+	sort.Slice(out.Users, func(i, j int) bool { return out.Users[i].UserID < out.Users[j].UserID })
+
+	out.Projects = sortedProjectComparisons(projects)
+
+	return out, nil
+}
+
+func addToDelta(d *SummaryDelta, sessions int, seconds float64, isCurrent bool) {
+	if isCurrent {
+		d.CurrentSessions += sessions
+		d.CurrentSeconds += int64(math.Round(seconds))
+	} else {
+		d.PreviousSessions += sessions
+		d.PreviousSeconds += int64(math.Round(seconds))
+	}
+}
+
+func sortedProjectComparisons(m map[int64]*ProjectComparison) []ProjectComparison {
+	out := make([]ProjectComparison, 0, len(m))
+	for _, p := range m {
+		p.finish()
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ProjectID < out[j].ProjectID })
+	return out
+}
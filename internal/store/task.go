@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Task struct {
+	Id        int64     `json:"id"`
+	ProjectId int64     `json:"project_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskRef is a task as shown on a session.
+type TaskRef struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type TaskStore interface {
+	ListTasks(ctx context.Context, projectID int64) ([]Task, error)
+	CreateTask(ctx context.Context, task *Task) error
+	RenameTask(ctx context.Context, id int64, name string) error
+	DeleteTask(ctx context.Context, id int64) (int64, error)
+}
+
+type PostgresTaskStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTaskStore(db *sql.DB) *PostgresTaskStore {
+	return &PostgresTaskStore{db: db}
+}
+
+func (pg *PostgresTaskStore) ListTasks(ctx context.Context, projectID int64) ([]Task, error) {
+	query := `
+		SELECT id, project_id, name, created_at
+		FROM tasks
+		WHERE project_id = $1
+		ORDER BY LOWER(name), id`
+
+	rows, err := pg.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Task{}
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.Id, &t.ProjectId, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresTaskStore) CreateTask(ctx context.Context, task *Task) error {
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO tasks (project_id, name) VALUES ($1, $2) RETURNING id, created_at`,
+		task.ProjectId, task.Name,
+	).Scan(&task.Id, &task.CreatedAt)
+}
+
+// RenameTask returns sql.ErrNoRows when the task doesn't exist.
+func (pg *PostgresTaskStore) RenameTask(ctx context.Context, id int64, name string) error {
+	return execAffectingOne(ctx, pg.db, `UPDATE tasks SET name = $2 WHERE id = $1`, id, name)
+}
+
+// DeleteTask deletes a task and returns how many sessions it was taken off;
+// the sessions stay on the project. It returns sql.ErrNoRows when the task doesn't exist.
+func (pg *PostgresTaskStore) DeleteTask(ctx context.Context, id int64) (int64, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE work_sessions SET task_id = NULL WHERE task_id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	detached, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM tasks WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return 0, err
+	}
+
+	return detached, tx.Commit()
+}
@@ -0,0 +1,453 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// sessions created from an empty timesheet cell start at this local hour
+const timesheetDayStartHour = 9
+
+// TimesheetRunningCell is the cell error for cells backed by a session that is still running.
+const TimesheetRunningCell = "cell has a running session"
+
+type PostgresTimesheetStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTimesheetStore(db *sql.DB) *PostgresTimesheetStore {
+	return &PostgresTimesheetStore{db: db}
+}
+
+type TimesheetCell struct {
+	Date      string `json:"date"`
+	Seconds   int64  `json:"seconds"`
+	Sessions  int    `json:"sessions"`
+	HasActive bool   `json:"has_active"`
+}
+
+type TimesheetRow struct {
+	ProjectId    int64           `json:"project_id"`
+	ProjectName  string          `json:"project_name"`
+	Cells        []TimesheetCell `json:"cells"`
+	TotalSeconds int64           `json:"total_seconds"`
+}
+
+// Timesheet is one user's week: projects as rows, days (Monday first) as columns.
+// A session belongs to the day it started on, in the requested time zone.
+type Timesheet struct {
+	UserId       int64          `json:"user_id"`
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	TimeZone     string         `json:"tz"`
+	Days         []string       `json:"days"`
+	Rows         []TimesheetRow `json:"rows"`
+	DayTotals    []int64        `json:"day_totals"`
+	TotalSeconds int64          `json:"total_seconds"`
+}
+
+type TimesheetCellUpdate struct {
+	ProjectId int64  `json:"project_id"`
+	Date      string `json:"date"`
+	Seconds   int64  `json:"seconds"`
+}
+
+type TimesheetCellError struct {
+	ProjectId int64  `json:"project_id"`
+	Date      string `json:"date"`
+	Error     string `json:"error"`
+}
+
+type TimesheetStore interface {
+	GetTimesheet(ctx context.Context, userID int64, weekStart time.Time) (*Timesheet, error)
+	SaveTimesheet(ctx context.Context, userID int64, weekStart time.Time, cells []TimesheetCellUpdate) ([]TimesheetCellError, error)
+}
+
+type timesheetSession struct {
+	id          int64
+	projectID   int64
+	projectName string
+	startAt     time.Time
+	endAt       *time.Time
+}
+
+func (s timesheetSession) seconds(now time.Time) int64 {
+	end := now
+	if s.endAt != nil {
+		end = *s.endAt
+	}
+	return int64(end.Sub(s.startAt).Seconds())
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func loadTimesheetSessions(ctx context.Context, q queryer, userID int64, from, to time.Time, lock bool) ([]timesheetSession, error) {
+	query := `
+		SELECT ws.id, ws.project_id, COALESCE(p.name, ''), ws.start_at, ws.end_at
+		FROM work_sessions ws
+		LEFT JOIN projects p ON p.id = ws.project_id
+		WHERE ws.user_id = $1
+		  AND ws.start_at >= $2
+		  AND ws.start_at < $3
+		ORDER BY ws.start_at, ws.id`
+	if lock {
+		query += ` FOR UPDATE OF ws`
+	}
+
+	rows, err := q.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []timesheetSession
+	for rows.Next() {
+		var s timesheetSession
+		if err := rows.Scan(&s.id, &s.projectID, &s.projectName, &s.startAt, &s.endAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+// weekDays returns the local midnights of the 7 days starting at weekStart.
+func weekDays(weekStart time.Time) []time.Time {
+	days := make([]time.Time, 8)
+	for i := range days {
+		days[i] = weekStart.AddDate(0, 0, i)
+	}
+	return days
+}
+
+func dayIndex(days []time.Time, t time.Time) int {
+	for i := 0; i < 7; i++ {
+		if !t.Before(days[i]) && t.Before(days[i+1]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (pg *PostgresTimesheetStore) GetTimesheet(ctx context.Context, userID int64, weekStart time.Time) (*Timesheet, error) {
+	days := weekDays(weekStart)
+
+	sessions, err := loadTimesheetSessions(ctx, pg.db, userID, days[0], days[7], false)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &Timesheet{
+		UserId:    userID,
+		From:      days[0].Format(time.DateOnly),
+		To:        days[6].Format(time.DateOnly),
+		TimeZone:  weekStart.Location().String(),
+		Days:      make([]string, 7),
+		Rows:      []TimesheetRow{},
+		DayTotals: make([]int64, 7),
+	}
+	for i := 0; i < 7; i++ {
+		ts.Days[i] = days[i].Format(time.DateOnly)
+	}
+
+	now := time.Now()
+	rowByProject := map[int64]*TimesheetRow{}
+
+	for _, s := range sessions {
+		row, ok := rowByProject[s.projectID]
+		if !ok {
+			row = &TimesheetRow{
+				ProjectId:   s.projectID,
+				ProjectName: s.projectName,
+				Cells:       make([]TimesheetCell, 7),
+			}
+			for i := range row.Cells {
+				row.Cells[i].Date = ts.Days[i]
+			}
+			rowByProject[s.projectID] = row
+		}
+
+		i := dayIndex(days, s.startAt)
+		if i < 0 {
+			continue
+		}
+
+		secs := s.seconds(now)
+		row.Cells[i].Seconds += secs
+		row.Cells[i].Sessions++
+		if s.endAt == nil {
+			row.Cells[i].HasActive = true
+		}
+		row.TotalSeconds += secs
+		ts.DayTotals[i] += secs
+		ts.TotalSeconds += secs
+	}
+
+	for _, row := range rowByProject {
+		ts.Rows = append(ts.Rows, *row)
+	}
+	sort.Slice(ts.Rows, func(i, j int) bool {
+		if ts.Rows[i].ProjectName != ts.Rows[j].ProjectName {
+			return ts.Rows[i].ProjectName < ts.Rows[j].ProjectName
+		}
+		return ts.Rows[i].ProjectId < ts.Rows[j].ProjectId
+	})
+
+	return ts, nil
+}
+
+// SaveTimesheet reconciles edited cell totals into work_sessions, in one transaction.
+// Only the cells sent are touched. For each cell:
+//   - a cell with a running session can't be edited;
+//   - 0 deletes every session in the cell;
+//   - an empty cell gets one new session starting at 09:00 local time,
+//     or right after the day's last session when 09:00 is taken;
+//   - a larger total extends the cell's latest session;
+//   - a smaller total trims sessions from the latest one backwards,
+//     deleting those that shrink to nothing.
+//
+// Changed sessions must not overlap other sessions or end in the future.
+// If any cell fails, nothing is saved and the errors are returned.
+func (pg *PostgresTimesheetStore) SaveTimesheet(ctx context.Context, userID int64, weekStart time.Time, cells []TimesheetCellUpdate) ([]TimesheetCellError, error) {
+	days := weekDays(weekStart)
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockUserSessions(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	sessions, err := loadTimesheetSessions(ctx, tx, userID, days[0], days[7], true)
+	if err != nil {
+		return nil, err
+	}
+
+	type cellKey struct {
+		projectID int64
+		day       int
+	}
+	byCell := map[cellKey][]timesheetSession{}
+	for _, s := range sessions {
+		k := cellKey{s.projectID, dayIndex(days, s.startAt)}
+		byCell[k] = append(byCell[k], s)
+	}
+
+	now := time.Now()
+	var cellErrors []TimesheetCellError
+
+	for _, c := range cells {
+		day := -1
+		for i := 0; i < 7; i++ {
+			if days[i].Format(time.DateOnly) == c.Date {
+				day = i
+			}
+		}
+
+		fail := func(msg string) {
+			cellErrors = append(cellErrors, TimesheetCellError{ProjectId: c.ProjectId, Date: c.Date, Error: msg})
+		}
+
+		if day < 0 {
+			fail("date is not in this week")
+			continue
+		}
+
+		cellSessions := byCell[cellKey{c.ProjectId, day}]
+
+		var current int64
+		hasActive := false
+		for _, s := range cellSessions {
+			current += s.seconds(now)
+			if s.endAt == nil {
+				hasActive = true
+			}
+		}
+
+		if hasActive {
+			fail(TimesheetRunningCell)
+			continue
+		}
+		if c.Seconds == current {
+			continue
+		}
+
+		msg, err := reconcileCell(ctx, tx, userID, c, cellSessions, current, days[day], days[day+1], now)
+		if err != nil {
+			return nil, err
+		}
+		if msg != "" {
+			fail(msg)
+		}
+	}
+
+	if len(cellErrors) > 0 {
+		return cellErrors, nil
+	}
+
+	return nil, tx.Commit()
+}
+
+func reconcileCell(ctx context.Context, tx *sql.Tx, userID int64, c TimesheetCellUpdate, cellSessions []timesheetSession, current int64, dayStart, dayEnd, now time.Time) (string, error) {
+	// latest first
+	sort.Slice(cellSessions, func(i, j int) bool {
+		return cellSessions[i].startAt.After(cellSessions[j].startAt)
+	})
+
+	switch {
+	case len(cellSessions) == 0:
+		var archivedAt *time.Time
+		var member bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT
+				p.archived_at,
+				EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = p.id AND pm.user_id = $2)
+				OR EXISTS (SELECT 1 FROM users u WHERE u.id = $2 AND u.role = 'admin')
+			FROM projects p
+			WHERE p.id = $1`,
+			c.ProjectId, userID,
+		).Scan(&archivedAt, &member)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "project not found", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if archivedAt != nil {
+			return "project is archived", nil
+		}
+		if !member {
+			return "not a member of this project", nil
+		}
+
+		required, err := requiredSessionField(ctx, tx)
+		if err != nil {
+			return "", err
+		}
+		if required != "" {
+			return "custom field " + required + " is required", nil
+		}
+
+		duration := time.Duration(c.Seconds) * time.Second
+		start := dayStart.Add(timesheetDayStartHour * time.Hour)
+
+		overlaps, err := txHasOverlap(ctx, tx, userID, start, start.Add(duration), 0)
+		if err != nil {
+			return "", err
+		}
+		if overlaps {
+			var lastEnd sql.NullTime
+			err := tx.QueryRowContext(ctx, `
+				SELECT MAX(COALESCE(end_at, NOW()))
+				FROM work_sessions
+				WHERE user_id = $1 AND start_at >= $2 AND start_at < $3`,
+				userID, dayStart, dayEnd,
+			).Scan(&lastEnd)
+			if err != nil {
+				return "", err
+			}
+			if lastEnd.Valid {
+				start = lastEnd.Time
+			}
+		}
+
+		end := start.Add(duration)
+		if msg, err := checkTimesheetWindow(ctx, tx, userID, start, end, 0, now); msg != "" || err != nil {
+			return msg, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO work_sessions (user_id, project_id, note, start_at, end_at, created_at)
+			VALUES ($1, $2, 'Timesheet entry', $3, $4, NOW())`,
+			userID, c.ProjectId, start, end,
+		)
+		return "", err
+
+	case c.Seconds > current:
+		latest := cellSessions[0]
+		end := latest.endAt.Add(time.Duration(c.Seconds-current) * time.Second)
+
+		if msg, err := checkTimesheetWindow(ctx, tx, userID, latest.startAt, end, latest.id, now); msg != "" || err != nil {
+			return msg, err
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE work_sessions SET end_at = $1 WHERE id = $2`, end, latest.id)
+		return "", err
+
+	default:
+		reduce := current - c.Seconds
+		for _, s := range cellSessions {
+			if reduce <= 0 {
+				break
+			}
+
+			secs := s.seconds(now)
+			if secs <= reduce {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM work_sessions WHERE id = $1`, s.id); err != nil {
+					return "", err
+				}
+				reduce -= secs
+				continue
+			}
+
+			end := s.endAt.Add(-time.Duration(reduce) * time.Second)
+			if _, err := tx.ExecContext(ctx, `UPDATE work_sessions SET end_at = $1 WHERE id = $2`, end, s.id); err != nil {
+				return "", err
+			}
+			reduce = 0
+		}
+		return "", nil
+	}
+}
+
+func checkTimesheetWindow(ctx context.Context, tx *sql.Tx, userID int64, start, end time.Time, excludeID int64, now time.Time) (string, error) {
+	if end.After(now) {
+		return fmt.Sprintf("session would end in the future (%s)", end.Format(time.RFC3339)), nil
+	}
+
+	overlaps, err := txHasOverlap(ctx, tx, userID, start, end, excludeID)
+	if err != nil {
+		return "", err
+	}
+	if overlaps {
+		return "session would overlap another session", nil
+	}
+	return "", nil
+}
+
+// lockUserSessions serializes the transactions that add or move a user's finished sessions,
+// so their overlap checks can't both pass. It locks the user's row.
+func lockUserSessions(ctx context.Context, tx *sql.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	return err
+}
+
+// txHasOverlap reports whether the user has another session intersecting [start, end).
+// A running session counts as lasting until now.
+func txHasOverlap(ctx context.Context, tx *sql.Tx, userID int64, start, end time.Time, excludeID int64) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM work_sessions
+			WHERE user_id = $1
+			  AND id <> $4
+			  AND start_at < $3
+			  AND COALESCE(end_at, NOW()) > $2
+		)`,
+		userID, start, end, excludeID,
+	).Scan(&exists)
+	return exists, err
+}
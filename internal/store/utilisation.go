@@ -0,0 +1,444 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	UtilisationPeriodWeek  = "week"
+	UtilisationPeriodMonth = "month"
+
+	UtilisationUnder = "under"
+	UtilisationOK    = "ok"
+	UtilisationOver  = "over"
+)
+
+type PostgresUtilisationStore struct {
+	db *sql.DB
+}
+
+func NewPostgresUtilisationStore(db *sql.DB) *PostgresUtilisationStore {
+	return &PostgresUtilisationStore{db: db}
+}
+
+type Team struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UtilisationFilter struct {
+	UserID *int64
+	TeamID *int64
+	From   time.Time // dates (YYYY-MM-DD), to inclusive
+	To     time.Time
+	Period string
+
+	LowPercent  float64
+	HighPercent float64
+}
+
+// UtilisationPeriod holds the figures of one period, or of the whole range.
+// For teams and the overall total, working days and capacity are summed over people.
+type UtilisationPeriod struct {
+	Period string `json:"period,omitempty"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+
+	WorkingDays        int     `json:"working_days"`
+	CapacitySeconds    int64   `json:"capacity_seconds"`
+	LoggedSeconds      int64   `json:"logged_seconds"`
+	BillableSeconds    int64   `json:"billable_seconds"`
+	UtilisationPercent float64 `json:"utilisation_percent"`
+	BillablePercent    float64 `json:"billable_percent"`
+	Flag               string  `json:"flag,omitempty"`
+}
+
+type UserUtilisation struct {
+	UserId              int64   `json:"user_id"`
+	UserName            string  `json:"user_name"`
+	TeamId              *int64  `json:"team_id"`
+	TeamName            string  `json:"team_name,omitempty"`
+	WeeklyCapacityHours float64 `json:"weekly_capacity_hours"`
+
+	Total UtilisationPeriod   `json:"total"`
+	Trend []UtilisationPeriod `json:"trend"`
+}
+
+type TeamUtilisation struct {
+	TeamId   *int64 `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Members  int    `json:"members"`
+
+	Total UtilisationPeriod   `json:"total"`
+	Trend []UtilisationPeriod `json:"trend"`
+}
+
+type UtilisationReport struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Period      string  `json:"period"`
+	LowPercent  float64 `json:"low_percent"`
+	HighPercent float64 `json:"high_percent"`
+
+	Total UtilisationPeriod   `json:"total"`
+	Trend []UtilisationPeriod `json:"trend"`
+	Users []UserUtilisation   `json:"users"`
+	Teams []TeamUtilisation   `json:"teams"`
+}
+
+type UtilisationStore interface {
+	ListTeams(ctx context.Context) ([]Team, error)
+	CreateTeam(ctx context.Context, t *Team) error
+	DeleteTeam(ctx context.Context, id int64) error
+	SetUserCapacity(ctx context.Context, userID int64, weeklyHours float64, teamID *int64) error
+
+	GetUtilisationReport(ctx context.Context, filter UtilisationFilter) (*UtilisationReport, error)
+}
+
+func (pg *PostgresUtilisationStore) ListTeams(ctx context.Context) ([]Team, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT id, name, created_at FROM teams ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Team{}
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.Id, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+func (pg *PostgresUtilisationStore) CreateTeam(ctx context.Context, t *Team) error {
+	return pg.db.QueryRowContext(ctx,
+		`INSERT INTO teams (name) VALUES ($1) RETURNING id, created_at`, t.Name,
+	).Scan(&t.Id, &t.CreatedAt)
+}
+
+func (pg *PostgresUtilisationStore) DeleteTeam(ctx context.Context, id int64) error {
+	return execAffectingOne(ctx, pg.db, `DELETE FROM teams WHERE id = $1`, id)
+}
+
+// SetUserCapacity sets the user's weekly capacity and team; a nil team removes them from their team.
+func (pg *PostgresUtilisationStore) SetUserCapacity(ctx context.Context, userID int64, weeklyHours float64, teamID *int64) error {
+	return execAffectingOne(ctx, pg.db,
+		`UPDATE users SET weekly_capacity_hours = $2, team_id = $3, updated_at = NOW() WHERE id = $1`,
+		userID, weeklyHours, teamID,
+	)
+}
+
+// utilisationPeriods splits [from, to] into weeks (Monday to Sunday) or calendar months,
+// clipped to the range.
+func utilisationPeriods(from, to time.Time, period string) []UtilisationPeriod {
+	var out []UtilisationPeriod
+
+	for start := from; !start.After(to); {
+		var label string
+		var next time.Time
+
+		if period == UtilisationPeriodMonth {
+			label = start.Format("2006-01")
+			next = time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		} else {
+			y, w := start.ISOWeek()
+			label = fmt.Sprintf("%d-W%02d", y, w)
+			next = start.AddDate(0, 0, 8-isoWeekday(start))
+		}
+
+		end := next.AddDate(0, 0, -1)
+		if end.After(to) {
+			end = to
+		}
+
+		out = append(out, UtilisationPeriod{
+			Period: label,
+			From:   start.Format(time.DateOnly),
+			To:     end.Format(time.DateOnly),
+		})
+		start = next
+	}
+
+	return out
+}
+
+// isoWeekday returns 1 for Monday through 7 for Sunday.
+func isoWeekday(d time.Time) int {
+	if d.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(d.Weekday())
+}
+
+// dailyCapacityFunc spreads the weekly capacity over the days the user normally works
+// (schedule days, or Monday to Friday). Holidays and approved leave take their share off.
+func dailyCapacityFunc(weeklyHours float64, schedules []WorkSchedule, holidays map[string]bool, absences map[string]float64) func(day time.Time) float64 {
+	isWorkingDay := workingDayFunc(schedules, holidays)
+	isUsualDay := workingDayFunc(schedules, nil)
+
+	return func(day time.Time) float64 {
+		if !isWorkingDay(day) {
+			return 0
+		}
+
+		monday := day.AddDate(0, 0, 1-isoWeekday(day))
+		days := 0
+		for d := monday; d.Before(monday.AddDate(0, 0, 7)); d = d.AddDate(0, 0, 1) {
+			if isUsualDay(d) {
+				days++
+			}
+		}
+		if days == 0 {
+			return 0
+		}
+
+		share := 1 - absences[day.Format(time.DateOnly)]
+		return weeklyHours * 3600 / float64(days) * share
+	}
+}
+
+// finish computes the percentages and the threshold flag.
+func (p *UtilisationPeriod) finish(low, high float64) {
+	p.UtilisationPercent, p.BillablePercent, p.Flag = 0, 0, ""
+
+	if p.CapacitySeconds <= 0 {
+		if p.LoggedSeconds > 0 {
+			p.Flag = UtilisationOver
+		}
+		return
+	}
+
+	p.UtilisationPercent = math.Round(float64(p.LoggedSeconds)/float64(p.CapacitySeconds)*1000) / 10
+	p.BillablePercent = math.Round(float64(p.BillableSeconds)/float64(p.CapacitySeconds)*1000) / 10
+
+	switch {
+	case p.UtilisationPercent < low:
+		p.Flag = UtilisationUnder
+	case p.UtilisationPercent > high:
+		p.Flag = UtilisationOver
+	default:
+		p.Flag = UtilisationOK
+	}
+}
+
+func (p *UtilisationPeriod) add(o UtilisationPeriod) {
+	p.WorkingDays += o.WorkingDays
+	p.CapacitySeconds += o.CapacitySeconds
+	p.LoggedSeconds += o.LoggedSeconds
+	p.BillableSeconds += o.BillableSeconds
+}
+
+// GetUtilisationReport compares logged time with capacity, per user and team, for each
+// period in the range. Like the summary report, sessions count in full on the UTC day they
+// started. Active users are included even when they logged nothing.
+func (pg *PostgresUtilisationStore) GetUtilisationReport(ctx context.Context, filter UtilisationFilter) (*UtilisationReport, error) {
+	from := time.Date(filter.From.Year(), filter.From.Month(), filter.From.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(filter.To.Year(), filter.To.Month(), filter.To.Day(), 0, 0, 0, 0, time.UTC)
+
+	periods := utilisationPeriods(from, to, filter.Period)
+
+	report := &UtilisationReport{
+		From:        from.Format(time.DateOnly),
+		To:          to.Format(time.DateOnly),
+		Period:      filter.Period,
+		LowPercent:  filter.LowPercent,
+		HighPercent: filter.HighPercent,
+		Total:       UtilisationPeriod{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly)},
+		Trend:       make([]UtilisationPeriod, len(periods)),
+		Users:       []UserUtilisation{},
+		Teams:       []TeamUtilisation{},
+	}
+	copy(report.Trend, periods)
+
+	query := `
+		SELECT u.id, u.name, u.team_id, COALESCE(t.name, ''), u.weekly_capacity_hours
+		FROM users u
+		LEFT JOIN teams t ON t.id = u.team_id
+		WHERE (u.is_active OR u.id = $1)
+		  AND ($1::bigint IS NULL OR u.id = $1)
+		  AND ($2::bigint IS NULL OR u.team_id = $2)
+		ORDER BY u.name, u.id`
+
+	rows, err := pg.db.QueryContext(ctx, query, filter.UserID, filter.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u UserUtilisation
+		if err := rows.Scan(&u.UserId, &u.UserName, &u.TeamId, &u.TeamName, &u.WeeklyCapacityHours); err != nil {
+			return nil, err
+		}
+		u.Total = UtilisationPeriod{From: report.From, To: report.To}
+		u.Trend = make([]UtilisationPeriod, len(periods))
+		copy(u.Trend, periods)
+		report.Users = append(report.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	byUser := make(map[int64]*UserUtilisation, len(report.Users))
+
+	// capacity and working days, day by day
+	for i := range report.Users {
+		u := &report.Users[i]
+		byUser[u.UserId] = u
+
+		schedules, err := listSchedules(ctx, pg.db, u.UserId)
+		if err != nil {
+			return nil, err
+		}
+		holidays, err := userHolidayDates(ctx, pg.db, u.UserId, from, to)
+		if err != nil {
+			return nil, err
+		}
+		absences, err := approvedAbsences(ctx, pg.db, u.UserId, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		isWorkingDay := workingDayFunc(schedules, holidays)
+		capacity := dailyCapacityFunc(u.WeeklyCapacityHours, schedules, holidays, absences)
+
+		for j := range u.Trend {
+			p := &u.Trend[j]
+			start, _ := time.Parse(time.DateOnly, p.From)
+			end, _ := time.Parse(time.DateOnly, p.To)
+
+			var secs float64
+			for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+				secs += capacity(d)
+			}
+			p.CapacitySeconds = int64(math.Round(secs))
+			p.WorkingDays = countWorkingDays(isWorkingDay, start, end)
+		}
+	}
+
+	// logged and billable time, by user and by the UTC day the sessions started on
+	dayPeriod := map[string]int{}
+	for j, p := range periods {
+		start, _ := time.Parse(time.DateOnly, p.From)
+		end, _ := time.Parse(time.DateOnly, p.To)
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			dayPeriod[d.Format(time.DateOnly)] = j
+		}
+	}
+
+	logged, err := pg.db.QueryContext(ctx, `
+		SELECT
+			ws.user_id,
+			to_char(ws.start_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'),
+			SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))),
+			COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))) FILTER (WHERE p.billable), 0)
+		FROM work_sessions ws
+		JOIN projects p ON p.id = ws.project_id
+		JOIN users u ON u.id = ws.user_id
+		WHERE ws.start_at >= $1 AND ws.start_at < $2
+		  AND ($3::bigint IS NULL OR ws.user_id = $3)
+		  AND ($4::bigint IS NULL OR u.team_id = $4)
+		GROUP BY 1, 2`,
+		from, to.AddDate(0, 0, 1), filter.UserID, filter.TeamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer logged.Close()
+
+	loggedSecs := map[int64][]float64{}
+	billableSecs := map[int64][]float64{}
+	for logged.Next() {
+		var userID int64
+		var day string
+		var secs, billable float64
+		if err := logged.Scan(&userID, &day, &secs, &billable); err != nil {
+			return nil, err
+		}
+		j, ok := dayPeriod[day]
+		if byUser[userID] == nil || !ok {
+			continue
+		}
+		if loggedSecs[userID] == nil {
+			loggedSecs[userID] = make([]float64, len(periods))
+			billableSecs[userID] = make([]float64, len(periods))
+		}
+		loggedSecs[userID][j] += secs
+		billableSecs[userID][j] += billable
+	}
+	if err := logged.Err(); err != nil {
+		return nil, err
+	}
+
+	for userID, secs := range loggedSecs {
+		u := byUser[userID]
+		for j := range secs {
+			u.Trend[j].LoggedSeconds = int64(math.Round(secs[j]))
+			u.Trend[j].BillableSeconds = int64(math.Round(billableSecs[userID][j]))
+		}
+	}
+
+	// users without a team are grouped under team id 0
+	teamIndex := map[int64]int{}
+
+	for i := range report.Users {
+		u := &report.Users[i]
+
+		var key int64
+		if u.TeamId != nil {
+			key = *u.TeamId
+		}
+		ti, ok := teamIndex[key]
+		if !ok {
+			t := TeamUtilisation{
+				TeamId:   u.TeamId,
+				TeamName: u.TeamName,
+				Total:    UtilisationPeriod{From: report.From, To: report.To},
+				Trend:    make([]UtilisationPeriod, len(periods)),
+			}
+			if u.TeamId == nil {
+				t.TeamName = "No team"
+			}
+			copy(t.Trend, periods)
+			report.Teams = append(report.Teams, t)
+			ti = len(report.Teams) - 1
+			teamIndex[key] = ti
+		}
+		team := &report.Teams[ti]
+		team.Members++
+
+		for j := range u.Trend {
+			u.Total.add(u.Trend[j])
+			team.Trend[j].add(u.Trend[j])
+			report.Trend[j].add(u.Trend[j])
+			u.Trend[j].finish(filter.LowPercent, filter.HighPercent)
+		}
+		team.Total.add(u.Total)
+		report.Total.add(u.Total)
+		u.Total.finish(filter.LowPercent, filter.HighPercent)
+	}
+
+	for i := range report.Teams {
+		t := &report.Teams[i]
+		t.Total.finish(filter.LowPercent, filter.HighPercent)
+		for j := range t.Trend {
+			t.Trend[j].finish(filter.LowPercent, filter.HighPercent)
+		}
+	}
+	report.Total.finish(filter.LowPercent, filter.HighPercent)
+	for j := range report.Trend {
+		report.Trend[j].finish(filter.LowPercent, filter.HighPercent)
+	}
+
+	return report, nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -21,10 +22,42 @@ type WorkSession struct {
 	Id        int64      `json:"id"`
 	UserId    int64      `json:"user_id"`
 	ProjectId int64      `json:"project_id"`
+	TaskId    *int64     `json:"task_id"`
 	StartAt   time.Time  `json:"start_at"`
 	EndAt     *time.Time `json:"end_at"`
 	Note      string     `json:"note"`
+	Tags      Tags       `json:"tags"`
 	CreatedAt time.Time  `json:"created_at"`
+
+	CustomFields CustomValues `json:"custom_fields"`
+}
+
+// Tags reads a text[] column selected as to_json(...).
+type Tags []string
+
+func (t *Tags) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*t = Tags{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("tags: unsupported type %T", src)
+	}
+
+	var out []string
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	if out == nil {
+		out = []string{}
+	}
+	*t = out
+	return nil
 }
 
 type UserResponse struct {
@@ -39,7 +72,11 @@ type WorkSessionResponse struct {
 	StartAt   time.Time  `json:"start_at"`
 	EndAt     *time.Time `json:"end_at"`
 	Note      string     `json:"note"`
+	Tags      Tags       `json:"tags"`
+	Task      *TaskRef   `json:"task"`
 	CreatedAt time.Time  `json:"created_at"`
+
+	CustomFields CustomValues `json:"custom_fields"`
 }
 
 type WorkSessionRow struct {
@@ -50,21 +87,44 @@ type WorkSessionRow struct {
 	DerivedStatus string `json:"status"`
 }
 
+// SessionScope limits a project manager to their own sessions and the sessions
+// on the projects they manage.
+type SessionScope struct {
+	UserID     int64
+	ProjectIDs []int64
+}
+
 type WorkSessionFilter struct {
 	Filter
 
 	UserID    *int64
 	ProjectID *int64
+	ClientID  *int64
+	TaskID    *int64
 	Active    *bool
-	Search    *string
+	Search    *SessionSearch
+	Scope     *SessionScope
+
+	// CustomFields and ProjectCustomFields keep the sessions whose own or whose project's
+	// custom values contain every value given
+	CustomFields        CustomValues
+	ProjectCustomFields CustomValues
 }
 
 type SummaryRangeFilter struct {
 	UserID    *int64
 	ProjectID *int64
+	ClientID  *int64
+	TaskID    *int64
+	Scope     *SessionScope
 	FromDate  time.Time // date (YYYY-MM-DD) parsed -> any time ok
 	ToDate    time.Time // date (YYYY-MM-DD)
 
+	// Rollup adds the client -> project -> task breakdown
+	Rollup bool
+
+	// StatusHistory adds the statuses each project had during the range
+	StatusHistory bool
 }
 
 type ReportUser struct {
@@ -74,24 +134,57 @@ type ReportUser struct {
 }
 
 type OverallSummary struct {
-	TotalSessions  int    `json:"total_sessions"`
-	TotalDurations string `json:"total_durations"`
+	TotalSessions  int     `json:"total_sessions"`
+	TotalSeconds   float64 `json:"-"`
+	TotalDurations string  `json:"total_durations"`
+	WorkingDays    *int    `json:"working_days,omitempty"` // nil when the users' counts differ
 }
 
 type ProjectSummary struct {
 	ProjectID   int64  `json:"project_id"`
 	ProjectName string `json:"project_name"`
 	Status      string `json:"status"`
+	Billable    bool   `json:"billable"`
 
-	TotalSessions  int    `json:"total_sessions"`
-	TotalDurations string `json:"total_durations"`
+	TotalSessions  int     `json:"total_sessions"`
+	TotalSeconds   float64 `json:"-"`
+	TotalDurations string  `json:"total_durations"`
 
 	Users []UserSummary `json:"users,omitempty"`
+	Tasks []TaskSummary `json:"tasks,omitempty"`
+
+	StatusHistory []StatusSpan `json:"status_history,omitempty"`
+}
+
+// ClientSummary is one branch of the client rollup; a nil ClientID holds
+// the projects without a client.
+type ClientSummary struct {
+	ClientID   *int64 `json:"client_id"`
+	ClientName string `json:"client_name"`
+
+	TotalSessions  int     `json:"total_sessions"`
+	TotalSeconds   float64 `json:"-"`
+	TotalDurations string  `json:"total_durations"`
+
+	Projects []ProjectSummary `json:"projects"`
+}
+
+// TaskSummary is a project's time on one task; a nil TaskID is the time
+// logged without a task.
+type TaskSummary struct {
+	TaskID   *int64 `json:"task_id"`
+	TaskName string `json:"task_name"`
+
+	TotalSessions  int     `json:"total_sessions"`
+	TotalSeconds   float64 `json:"-"`
+	TotalDurations string  `json:"total_durations"`
 }
 
 type SummaryFilters struct {
 	UserID    *int64 `json:"user_id"`
 	ProjectID *int64 `json:"project_id"`
+	ClientID  *int64 `json:"client_id"`
+	TaskID    *int64 `json:"task_id"`
 }
 
 type SummaryReport struct {
@@ -103,6 +196,7 @@ type SummaryReport struct {
 
 	Users    []UserSummary    `json:"users,omitempty"`
 	Projects []ProjectSummary `json:"projects,omitempty"`
+	Clients  []ClientSummary  `json:"clients,omitempty"`
 }
 
 type UserSummary struct {
@@ -111,27 +205,36 @@ type UserSummary struct {
 	UserEmail string `json:"user_email"`
 	IsActive  bool   `json:"is_active"`
 
-	TotalSessions  int    `json:"total_sessions"`
-	TotalDurations string `json:"total_durations"`
+	TotalSessions  int     `json:"total_sessions"`
+	TotalSeconds   float64 `json:"-"`
+	TotalDurations string  `json:"total_durations"`
+	WorkingDays    int     `json:"working_days"`
 
 	Projects []ProjectSummary `json:"projects,omitempty"`
 }
 
 type WorkSessionStore interface {
 	StartSession(ctx context.Context, ws *WorkSession) error
-	StopSession(ctx context.Context, sessionID, userID int64) (int64, time.Time, error)
+	StopSession(ctx context.Context, sessionID, userID int64) (ownerID, projectID int64, endAt time.Time, err error)
 	GetSummaryReport(ctx context.Context, filter SummaryRangeFilter) (*SummaryReport, error)
+	GetHeatmap(ctx context.Context, filter HeatmapFilter) (*Heatmap, error)
+	CompareSummaryReports(ctx context.Context, current, previous SummaryRangeFilter) (*SummaryComparison, error)
 	ListSessions(ctx context.Context, filter WorkSessionFilter) ([]WorkSessionRow, int, error)
 }
 
 func (pg *PostgresWorkSessionStore) StartSession(ctx context.Context, ws *WorkSession) error {
 	query := `
-		INSERT INTO work_sessions (user_id, project_id, note, start_at, created_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
+		INSERT INTO work_sessions (user_id, project_id, note, tags, task_id, custom_fields, start_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
 		RETURNING id, start_at, created_at;
 	`
 
-	err := pg.db.QueryRowContext(ctx, query, ws.UserId, ws.ProjectId, ws.Note).
+	custom, err := customValuesArg(ws.CustomFields)
+	if err != nil {
+		return err
+	}
+
+	err = pg.db.QueryRowContext(ctx, query, ws.UserId, ws.ProjectId, ws.Note, tagsArg(ws.Tags), ws.TaskId, custom).
 		Scan(&ws.Id, &ws.StartAt, &ws.CreatedAt)
 	if err != nil {
 		return err
@@ -139,7 +242,9 @@ func (pg *PostgresWorkSessionStore) StartSession(ctx context.Context, ws *WorkSe
 	return nil
 }
 
-func (pg *PostgresWorkSessionStore) StopSession(ctx context.Context, sessionID, userID int64) (int64, time.Time, error) {
+// StopSession stops a running session of the user, or any running session when the user is an admin
+// or manages the session's project.
+func (pg *PostgresWorkSessionStore) StopSession(ctx context.Context, sessionID, userID int64) (int64, int64, time.Time, error) {
 	query := `
 		UPDATE work_sessions ws
 		SET end_at = NOW()
@@ -152,24 +257,36 @@ func (pg *PostgresWorkSessionStore) StopSession(ctx context.Context, sessionID,
 		              FROM users u
 		              WHERE u.id = $2 AND u.role = 'admin'
 		        )
+		        OR EXISTS (
+		              SELECT 1
+		              FROM project_members pm
+		              WHERE pm.project_id = ws.project_id AND pm.user_id = $2 AND pm.is_manager
+		        )
 		  )
-		RETURNING ws.user_id, ws.end_at;
+		RETURNING ws.user_id, ws.project_id, ws.end_at;
 	`
 
-	var ownerUserID int64
+	var ownerUserID, projectID int64
 	var endAt time.Time
 
-	err := pg.db.QueryRowContext(ctx, query, sessionID, userID).Scan(&ownerUserID, &endAt)
+	err := pg.db.QueryRowContext(ctx, query, sessionID, userID).Scan(&ownerUserID, &projectID, &endAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, time.Time{}, sql.ErrNoRows
+			return 0, 0, time.Time{}, sql.ErrNoRows
 		}
-		return 0, time.Time{}, err
+		return 0, 0, time.Time{}, err
 	}
 
-	return ownerUserID, endAt, nil
+	return ownerUserID, projectID, endAt, nil
 }
 
+// tagsArg never passes NULL for the NOT NULL tags column.
+func tagsArg(t Tags) []string {
+	if t == nil {
+		return []string{}
+	}
+	return t
+}
 
 func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter WorkSessionFilter) ([]WorkSessionRow, int, error) {
 	limit := filter.Limit()
@@ -185,9 +302,39 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 		projectID = *filter.ProjectID
 	}
 
-	search := ""
+	clientID := int64(0)
+	if filter.ClientID != nil {
+		clientID = *filter.ClientID
+	}
+
+	taskID := int64(0)
+	if filter.TaskID != nil {
+		taskID = *filter.TaskID
+	}
+
+	scopeUserID, scopeProjectIDs := int64(0), []int64{}
+	if filter.Scope != nil {
+		scopeUserID = filter.Scope.UserID
+		scopeProjectIDs = nonNilInt64s(filter.Scope.ProjectIDs)
+	}
+
+	textQuery, projectSearch, userSearch := "", "", ""
+	excludedProjects, excludedUsers := []string{}, []string{}
 	if filter.Search != nil {
-		search = strings.TrimSpace(*filter.Search)
+		textQuery = filter.Search.TextQuery()
+		projectSearch = escapeLike(strings.TrimSpace(filter.Search.Project))
+		userSearch = escapeLike(strings.TrimSpace(filter.Search.User))
+		excludedProjects = likePatterns(filter.Search.ExcludedProjects, "%", "%")
+		excludedUsers = likePatterns(filter.Search.ExcludedUsers, "", "%")
+	}
+
+	customFields, err := customValuesArg(filter.CustomFields)
+	if err != nil {
+		return nil, 0, err
+	}
+	projectCustomFields, err := customValuesArg(filter.ProjectCustomFields)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	active := ""
@@ -213,11 +360,17 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 		p.name    AS project_name,
 		COALESCE(s.id, 0)    AS project_status_id,
 		COALESCE(s.name, '') AS project_status_name,
+		c.id      AS client_id,
+		c.name    AS client_name,
+		p.custom_fields AS project_custom_fields,
 
-
+		tk.id     AS task_id,
+		tk.name   AS task_name,
 		ws.start_at,
 		ws.end_at,
 		COALESCE(ws.note, '') AS note,
+		to_json(ws.tags) AS tags,
+		ws.custom_fields,
 		ws.created_at,
 
 		CASE WHEN ws.end_at IS NULL THEN 'active' ELSE 'inactive' END AS status
@@ -225,24 +378,32 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 	JOIN projects p ON p.id = ws.project_id
 	JOIN users u ON u.id = ws.user_id
 	LEFT JOIN statuses s ON s.id = p.status_id
+	LEFT JOIN clients c ON c.id = p.client_id
+	LEFT JOIN tasks tk ON tk.id = ws.task_id
 	WHERE
 		($1 = 0 OR ws.user_id = $1)
 		AND ($2 = 0 OR ws.project_id = $2)
+		AND ($3 = '' OR ws.note_search @@ websearch_to_tsquery('simple', $3))
+		AND ($4 = '' OR p.name ILIKE '%' || $4 || '%')
+		AND ($5 = '' OR u.name ILIKE $5 || '%' OR u.email ILIKE $5 || '%')
 		AND (
-			$3 = '' OR (
-				p.name ILIKE $3 || '%%' OR
-				u.name ILIKE $3 || '%%' OR
-				u.email ILIKE $3 || '%%' OR
-				COALESCE(ws.note,'') ILIKE $3 || '%%'
-			)
-		)
-		AND (
-			$4 = '' OR
-			($4 = 'true'  AND ws.end_at IS NULL) OR
-			($4 = 'false' AND ws.end_at IS NOT NULL)
+			$6 = '' OR
+			($6 = 'true'  AND ws.end_at IS NULL) OR
+			($6 = 'false' AND ws.end_at IS NOT NULL)
 		)
-	ORDER BY ws.start_at DESC, ws.id DESC
-	LIMIT $5 OFFSET $6;
+		AND ($9 = 0 OR p.client_id = $9)
+		AND ($10 = 0 OR ws.task_id = $10)
+		AND ($11 = 0 OR ws.user_id = $11 OR ws.project_id = ANY($12::bigint[]))
+		AND ws.custom_fields @> $13::jsonb
+		AND p.custom_fields @> $14::jsonb
+		AND NOT p.name ILIKE ANY($15::text[])
+		AND NOT (u.name ILIKE ANY($16::text[]) OR u.email ILIKE ANY($16::text[]))
+	ORDER BY
+		CASE WHEN $3 = '' THEN 0
+			ELSE ts_rank(ws.note_search, websearch_to_tsquery('simple', $3))
+		END DESC,
+		ws.start_at DESC, ws.id DESC
+	LIMIT $7 OFFSET $8;
 `
 
 	rows, err := pg.db.QueryContext(
@@ -250,10 +411,20 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 		query,
 		userID,
 		projectID,
-		search,
+		textQuery,
+		projectSearch,
+		userSearch,
 		active,
 		limit,
 		offset,
+		clientID,
+		taskID,
+		scopeUserID,
+		scopeProjectIDs,
+		customFields,
+		projectCustomFields,
+		excludedProjects,
+		excludedUsers,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -267,6 +438,10 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 		var (
 			row          WorkSessionRow
 			totalRecords int
+			clientID     sql.NullInt64
+			clientName   sql.NullString
+			taskID       sql.NullInt64
+			taskName     sql.NullString
 		)
 
 		if err := rows.Scan(
@@ -282,10 +457,17 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 			&row.Project.Name,
 			&row.Project.Status.Id,
 			&row.Project.Status.Name,
+			&clientID,
+			&clientName,
+			&row.Project.CustomFields,
 
+			&taskID,
+			&taskName,
 			&row.Session.StartAt,
 			&row.Session.EndAt,
 			&row.Session.Note,
+			&row.Session.Tags,
+			&row.Session.CustomFields,
 			&row.Session.CreatedAt,
 
 			&row.DerivedStatus,
@@ -293,6 +475,13 @@ func (pg *PostgresWorkSessionStore) ListSessions(ctx context.Context, filter Wor
 			return nil, 0, err
 		}
 
+		if clientID.Valid {
+			row.Project.Client = &ClientRef{Id: clientID.Int64, Name: clientName.String}
+		}
+		if taskID.Valid {
+			row.Session.Task = &TaskRef{Id: taskID.Int64, Name: taskName.String}
+		}
+
 		total = totalRecords
 		out = append(out, row)
 	}
@@ -313,6 +502,8 @@ func (pg *PostgresWorkSessionStore) GetSummaryReport(ctx context.Context, filter
 		Filters: SummaryFilters{
 			UserID:    filter.UserID,
 			ProjectID: filter.ProjectID,
+			ClientID:  filter.ClientID,
+			TaskID:    filter.TaskID,
 		},
 	}
 
@@ -333,6 +524,25 @@ func (pg *PostgresWorkSessionStore) GetSummaryReport(ctx context.Context, filter
 		args = append(args, *filter.ProjectID)
 	}
 
+	// the per-user queries don't join projects, so the client goes through a subquery
+	if filter.ClientID != nil {
+		argCount++
+		whereClause += fmt.Sprintf(" AND ws.project_id IN (SELECT id FROM projects WHERE client_id = $%d)", argCount)
+		args = append(args, *filter.ClientID)
+	}
+
+	if filter.TaskID != nil {
+		argCount++
+		whereClause += fmt.Sprintf(" AND ws.task_id = $%d", argCount)
+		args = append(args, *filter.TaskID)
+	}
+
+	if filter.Scope != nil {
+		whereClause += fmt.Sprintf(" AND (ws.user_id = $%d OR ws.project_id = ANY($%d::bigint[]))", argCount+1, argCount+2)
+		args = append(args, filter.Scope.UserID, nonNilInt64s(filter.Scope.ProjectIDs))
+		argCount += 2
+	}
+
 	overallQuery := fmt.Sprintf(`
 		SELECT 
 			COUNT(*) AS total_sessions,
@@ -355,6 +565,7 @@ func (pg *PostgresWorkSessionStore) GetSummaryReport(ctx context.Context, filter
 
 	report.Overall = OverallSummary{
 		TotalSessions:  totalSessions,
+		TotalSeconds:   totalSeconds,
 		TotalDurations: formatDuration(totalSeconds),
 	}
 
@@ -363,10 +574,192 @@ func (pg *PostgresWorkSessionStore) GetSummaryReport(ctx context.Context, filter
 		return nil, err
 	}
 
+	// working days: the user's schedule (or Monday to Friday) minus their holidays;
+	// the overall figure is the filtered user's, or the users' shared count when they agree
+	for i := range users {
+		isWorkingDay, err := userWorkingDays(ctx, pg.db, users[i].UserID, fromStart, toStart)
+		if err != nil {
+			return nil, err
+		}
+		users[i].WorkingDays = countWorkingDays(isWorkingDay, fromStart, toStart)
+	}
+
+	if filter.UserID != nil {
+		isWorkingDay, err := userWorkingDays(ctx, pg.db, *filter.UserID, fromStart, toStart)
+		if err != nil {
+			return nil, err
+		}
+		days := countWorkingDays(isWorkingDay, fromStart, toStart)
+		report.Overall.WorkingDays = &days
+	} else if len(users) > 0 {
+		days := users[0].WorkingDays
+		for _, u := range users[1:] {
+			if u.WorkingDays != days {
+				days = -1
+				break
+			}
+		}
+		if days >= 0 {
+			report.Overall.WorkingDays = &days
+		}
+	}
+
 	report.Users = users
+
+	if filter.Rollup {
+		report.Clients, err = pg.getClientRollup(ctx, whereClause, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.StatusHistory {
+		if err := pg.addStatusHistory(ctx, report, fromStart, toEnd); err != nil {
+			return nil, err
+		}
+	}
+
 	return report, nil
 }
 
+// addStatusHistory fills in the statuses of every project in the report during [from, to).
+func (pg *PostgresWorkSessionStore) addStatusHistory(ctx context.Context, report *SummaryReport, from, to time.Time) error {
+	var projects []*ProjectSummary
+	for i := range report.Users {
+		for j := range report.Users[i].Projects {
+			projects = append(projects, &report.Users[i].Projects[j])
+		}
+	}
+	for i := range report.Clients {
+		for j := range report.Clients[i].Projects {
+			projects = append(projects, &report.Clients[i].Projects[j])
+		}
+	}
+	if len(projects) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(projects))
+	for _, p := range projects {
+		ids = append(ids, p.ProjectID)
+	}
+
+	spans, err := projectStatusSpans(ctx, pg.db, ids, from, to)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		p.StatusHistory = spans[p.ProjectID]
+	}
+	return nil
+}
+
+// getClientRollup aggregates sessions per client, project and task in one
+// query and nests the rows; rows come ordered so each level is contiguous.
+func (pg *PostgresWorkSessionStore) getClientRollup(ctx context.Context, whereClause string, args []interface{}) ([]ClientSummary, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		c.id,
+		COALESCE(c.name, ''),
+		p.id,
+		p.name,
+		COALESCE(s.name, '') AS status,
+		p.billable,
+		tk.id,
+		COALESCE(tk.name, ''),
+		COUNT(ws.id) AS total_sessions,
+		COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))), 0) AS total_seconds
+	FROM work_sessions ws
+	JOIN projects p ON p.id = ws.project_id
+	LEFT JOIN statuses s ON s.id = p.status_id
+	LEFT JOIN clients c ON c.id = p.client_id
+	LEFT JOIN tasks tk ON tk.id = ws.task_id
+	%s
+	GROUP BY c.id, c.name, p.id, p.name, s.name, p.billable, tk.id, tk.name
+	ORDER BY LOWER(c.name) NULLS LAST, c.id, p.id, LOWER(tk.name) NULLS LAST, tk.id
+`, whereClause)
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []ClientSummary
+	for rows.Next() {
+		var (
+			clientID sql.NullInt64
+			taskID   sql.NullInt64
+			cs       ClientSummary
+			ps       ProjectSummary
+			ts       TaskSummary
+		)
+
+		if err := rows.Scan(
+			&clientID,
+			&cs.ClientName,
+			&ps.ProjectID,
+			&ps.ProjectName,
+			&ps.Status,
+			&ps.Billable,
+			&taskID,
+			&ts.TaskName,
+			&ts.TotalSessions,
+			&ts.TotalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		if clientID.Valid {
+			cs.ClientID = &clientID.Int64
+		}
+		if taskID.Valid {
+			ts.TaskID = &taskID.Int64
+		}
+
+		n := len(clients)
+		if n == 0 || !sameID(clients[n-1].ClientID, cs.ClientID) {
+			clients = append(clients, cs)
+			n++
+		}
+		client := &clients[n-1]
+
+		m := len(client.Projects)
+		if m == 0 || client.Projects[m-1].ProjectID != ps.ProjectID {
+			client.Projects = append(client.Projects, ps)
+			m++
+		}
+		project := &client.Projects[m-1]
+
+		ts.TotalDurations = formatDuration(ts.TotalSeconds)
+		project.Tasks = append(project.Tasks, ts)
+
+		project.TotalSessions += ts.TotalSessions
+		project.TotalSeconds += ts.TotalSeconds
+		client.TotalSessions += ts.TotalSessions
+		client.TotalSeconds += ts.TotalSeconds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range clients {
+		clients[i].TotalDurations = formatDuration(clients[i].TotalSeconds)
+		for j := range clients[i].Projects {
+			p := &clients[i].Projects[j]
+			p.TotalDurations = formatDuration(p.TotalSeconds)
+		}
+	}
+
+	return clients, nil
+}
+
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
 // aggregates sessions per user
 func (pg *PostgresWorkSessionStore) getUserSummaries(ctx context.Context, whereClause string, args []interface{}) ([]UserSummary, error) {
 	query := fmt.Sprintf(`
@@ -408,6 +801,7 @@ func (pg *PostgresWorkSessionStore) getUserSummaries(ctx context.Context, whereC
 			return nil, err
 		}
 
+		user.TotalSeconds = totalSeconds
 		user.TotalDurations = formatDuration(totalSeconds)
 
 		projects, err := pg.getProjectsForUser(
@@ -434,6 +828,7 @@ func (pg *PostgresWorkSessionStore) getProjectsForUser(ctx context.Context, user
 		p.id,
 		p.name,
 		COALESCE(s.name, '') AS status,
+		p.billable,
 		COUNT(ws.id) as total_sessions,
 		COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ws.end_at, NOW()) - ws.start_at))), 0) as total_seconds
 	FROM projects p
@@ -441,7 +836,7 @@ func (pg *PostgresWorkSessionStore) getProjectsForUser(ctx context.Context, user
 	INNER JOIN work_sessions ws ON ws.project_id = p.id
 	%s
 		AND ws.user_id = $%d
-	GROUP BY p.id, p.name, s.name
+	GROUP BY p.id, p.name, s.name, p.billable
 	ORDER BY p.id
 `, whereClause, len(args)+1)
 
@@ -461,6 +856,7 @@ func (pg *PostgresWorkSessionStore) getProjectsForUser(ctx context.Context, user
 			&project.ProjectID,
 			&project.ProjectName,
 			&project.Status,
+			&project.Billable,
 			&project.TotalSessions,
 			&totalSeconds,
 		)
@@ -469,6 +865,7 @@ func (pg *PostgresWorkSessionStore) getProjectsForUser(ctx context.Context, user
 			return nil, err
 		}
 
+		project.TotalSeconds = totalSeconds
 		project.TotalDurations = formatDuration(totalSeconds)
 		projects = append(projects, project)
 	}
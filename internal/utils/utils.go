@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -81,4 +82,22 @@ func ReadCSV(r *http.Request, key string, defaultValue []string) []string {
 	}
 
 	return strings.Split(csv, ",")
-}
\ No newline at end of file
+}
+// PublicURL builds an absolute link to this API, e.g. for calendar feeds.
+// WORKTIME_PUBLIC_URL must be set when the API runs behind a real domain.
+func PublicURL(path string) string {
+	base := os.Getenv("WORKTIME_PUBLIC_URL")
+	if base == "" {
+		base = "http://localhost:4000"
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// LoadTimeZone loads a tz parameter. "Local" is rejected: it names the server's zone,
+// which Postgres does not know by that name.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, errors.New("unknown time zone Local")
+	}
+	return time.LoadLocation(name)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
@@ -19,6 +20,11 @@ func main() {
 	// Load .env (local development only)
 	_ = godotenv.Load()
 
+	// Subcommands: `worktime import ...`
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
 	// Default port from env
 	envPort := 4000
 	if p := os.Getenv("WORKTIME_PORT"); p != "" {
@@ -38,6 +44,12 @@ func main() {
 
 	routes := router.SetUpRoutes(application)
 
+	// project budget alerts
+	go application.BudgetWatcher.Run(context.Background(), time.Minute)
+
+	// scheduled saved reports
+	go application.ReportScheduler.Run(context.Background(), time.Minute)
+
 	allowed := map[string]bool{
 		"http://localhost:5173": true,
 		"http://localhost:4000": true,
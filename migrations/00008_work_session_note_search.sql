@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE work_sessions
+ADD COLUMN IF NOT EXISTS note_search TSVECTOR;
+
+-- 'simple' config: notes are written in several languages,
+-- so we don't want english stemming or stop words here.
+-- The same config is used by ListSessions when it builds the query.
+
+UPDATE work_sessions
+SET note_search = to_tsvector('simple', COALESCE(note, ''));
+
+CREATE OR REPLACE FUNCTION work_sessions_note_search_update() RETURNS trigger AS $$
+BEGIN
+    NEW.note_search := to_tsvector('simple', COALESCE(NEW.note, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER work_sessions_note_search_trigger
+BEFORE INSERT OR UPDATE OF note ON work_sessions
+FOR EACH ROW EXECUTE FUNCTION work_sessions_note_search_update();
+
+CREATE INDEX IF NOT EXISTS idx_work_sessions_note_search
+    ON work_sessions USING GIN (note_search);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_work_sessions_note_search;
+DROP TRIGGER IF EXISTS work_sessions_note_search_trigger ON work_sessions;
+DROP FUNCTION IF EXISTS work_sessions_note_search_update();
+
+ALTER TABLE work_sessions
+DROP COLUMN IF EXISTS note_search;
+
+-- +goose StatementEnd
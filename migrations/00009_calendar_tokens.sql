@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT 'user' CHECK (scope IN ('user', 'team')),
+    token_hash BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ NULL
+);
+
+-- Feed requests look the token up by its hash
+
+CREATE UNIQUE INDEX idx_calendar_tokens_token_hash
+    ON calendar_tokens(token_hash);
+
+-- Regenerating a token revokes the old one,
+-- so each user has at most one live token per scope
+
+CREATE UNIQUE INDEX one_live_calendar_token_per_scope
+    ON calendar_tokens(user_id, scope)
+    WHERE revoked_at IS NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS calendar_tokens;
+
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Rules that map imported calendar events to projects.
+-- summary: case-insensitive "contains", category: case-insensitive equality.
+
+CREATE TABLE IF NOT EXISTS calendar_import_rules (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    match_field TEXT NOT NULL CHECK (match_field IN ('summary', 'category')),
+    pattern TEXT NOT NULL,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_calendar_import_rules_user_id
+    ON calendar_import_rules(user_id);
+
+-- Planned work blocks waiting to be confirmed as work sessions
+
+CREATE TABLE IF NOT EXISTS session_drafts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id BIGINT NULL REFERENCES projects(id) ON DELETE SET NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    source_uid TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (end_at > start_at)
+);
+
+-- Importing the same calendar twice must not duplicate drafts
+
+CREATE UNIQUE INDEX idx_session_drafts_source
+    ON session_drafts(user_id, source_uid, start_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS session_drafts;
+DROP TABLE IF EXISTS calendar_import_rules;
+
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Expected working time per weekday. A schedule applies from effective_from
+-- until the user's next schedule starts.
+
+CREATE TABLE IF NOT EXISTS work_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL,
+    mon_seconds INTEGER NOT NULL DEFAULT 0 CHECK (mon_seconds BETWEEN 0 AND 86400),
+    tue_seconds INTEGER NOT NULL DEFAULT 0 CHECK (tue_seconds BETWEEN 0 AND 86400),
+    wed_seconds INTEGER NOT NULL DEFAULT 0 CHECK (wed_seconds BETWEEN 0 AND 86400),
+    thu_seconds INTEGER NOT NULL DEFAULT 0 CHECK (thu_seconds BETWEEN 0 AND 86400),
+    fri_seconds INTEGER NOT NULL DEFAULT 0 CHECK (fri_seconds BETWEEN 0 AND 86400),
+    sat_seconds INTEGER NOT NULL DEFAULT 0 CHECK (sat_seconds BETWEEN 0 AND 86400),
+    sun_seconds INTEGER NOT NULL DEFAULT 0 CHECK (sun_seconds BETWEEN 0 AND 86400),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX one_schedule_per_user_and_day
+    ON work_schedules(user_id, effective_from);
+
+-- Manual corrections of the overtime balance (positive or negative)
+
+CREATE TABLE IF NOT EXISTS overtime_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    effective_date DATE NOT NULL,
+    seconds INTEGER NOT NULL CHECK (seconds <> 0),
+    reason TEXT NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_overtime_adjustments_user_date
+    ON overtime_adjustments(user_id, effective_date);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS overtime_adjustments;
+DROP TABLE IF EXISTS work_schedules;
+
+-- +goose StatementEnd
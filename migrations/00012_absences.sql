@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS absence_types (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    paid BOOLEAN NOT NULL DEFAULT TRUE,
+    -- whether approved days are taken from the user's yearly allowance
+    uses_allowance BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO absence_types (name, paid, uses_allowance) VALUES
+    ('Vacation', TRUE, TRUE),
+    ('Sick leave', TRUE, FALSE),
+    ('Unpaid leave', FALSE, FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS absence_requests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    absence_type_id BIGINT NOT NULL REFERENCES absence_types(id) ON DELETE RESTRICT,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    half_day BOOLEAN NOT NULL DEFAULT FALSE,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled')),
+    note TEXT NOT NULL DEFAULT '',
+    decided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMPTZ,
+    decision_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (end_date >= start_date),
+    -- a half day is always a single day
+    CHECK (NOT half_day OR start_date = end_date)
+);
+
+CREATE INDEX idx_absence_requests_user_dates
+    ON absence_requests(user_id, start_date, end_date);
+
+-- Yearly entitlement per user and absence type.
+-- accrual 'upfront': all days are available on January 1st,
+-- 'monthly': 1/12 of the days becomes available at the start of each month.
+-- Unused days carry over into the next year, up to carry_over_max_days.
+
+CREATE TABLE IF NOT EXISTS absence_allowances (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    absence_type_id BIGINT NOT NULL REFERENCES absence_types(id) ON DELETE CASCADE,
+    year INTEGER NOT NULL,
+    days DOUBLE PRECISION NOT NULL CHECK (days >= 0),
+    accrual TEXT NOT NULL DEFAULT 'upfront' CHECK (accrual IN ('upfront', 'monthly')),
+    carry_over_max_days DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (carry_over_max_days >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX one_allowance_per_user_type_year
+    ON absence_allowances(user_id, absence_type_id, year);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS absence_allowances;
+DROP TABLE IF EXISTS absence_requests;
+DROP TABLE IF EXISTS absence_types;
+
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS holiday_calendars (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES holiday_calendars(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX one_holiday_per_calendar_day
+    ON holidays(calendar_id, date);
+
+CREATE TABLE IF NOT EXISTS offices (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    holiday_calendar_id BIGINT REFERENCES holiday_calendars(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A user's own calendar wins over their office's calendar
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS office_id BIGINT REFERENCES offices(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS holiday_calendar_id BIGINT REFERENCES holiday_calendars(id) ON DELETE SET NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS holiday_calendar_id,
+    DROP COLUMN IF EXISTS office_id;
+
+DROP TABLE IF EXISTS offices;
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS holiday_calendars;
+
+-- +goose StatementEnd
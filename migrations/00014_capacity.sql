@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS teams (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS team_id BIGINT REFERENCES teams(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS weekly_capacity_hours DOUBLE PRECISION NOT NULL DEFAULT 40
+        CHECK (weekly_capacity_hours >= 0 AND weekly_capacity_hours <= 168);
+
+ALTER TABLE projects
+    ADD COLUMN IF NOT EXISTS billable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE projects
+    DROP COLUMN IF EXISTS billable;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS weekly_capacity_hours,
+    DROP COLUMN IF EXISTS team_id;
+
+DROP TABLE IF EXISTS teams;
+
+-- +goose StatementEnd
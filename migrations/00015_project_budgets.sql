@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE projects
+    ADD COLUMN IF NOT EXISTS budget_hours DOUBLE PRECISION CHECK (budget_hours > 0),
+    ADD COLUMN IF NOT EXISTS budget_amount DOUBLE PRECISION CHECK (budget_amount > 0),
+    ADD COLUMN IF NOT EXISTS hourly_rate DOUBLE PRECISION CHECK (hourly_rate >= 0),
+    ADD COLUMN IF NOT EXISTS budget_period TEXT NOT NULL DEFAULT 'total'
+        CHECK (budget_period IN ('total', 'monthly'));
+
+-- period is 'total' or the month ('2026-02') of a monthly budget
+
+CREATE TABLE IF NOT EXISTS project_budget_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    percent DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX one_alert_per_project_threshold_period
+    ON project_budget_alerts(project_id, threshold, period);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS project_budget_alerts;
+
+ALTER TABLE projects
+    DROP COLUMN IF EXISTS budget_period,
+    DROP COLUMN IF EXISTS hourly_rate,
+    DROP COLUMN IF EXISTS budget_amount,
+    DROP COLUMN IF EXISTS budget_hours;
+
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE work_sessions
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS work_sessions_tags_idx
+    ON work_sessions USING GIN (tags);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS work_sessions_tags_idx;
+
+ALTER TABLE work_sessions
+    DROP COLUMN IF EXISTS tags;
+
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- definition is the report builder request without its dates; period decides the
+-- dates on every run, in the report's time zone
+
+CREATE TABLE IF NOT EXISTS saved_reports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    definition JSONB NOT NULL,
+    period TEXT NOT NULL
+        CHECK (period IN ('previous_week', 'previous_month', 'last_7_days', 'last_30_days', 'week_to_date', 'month_to_date')),
+    output TEXT NOT NULL DEFAULT 'csv' CHECK (output IN ('csv', 'json')),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    schedule TEXT,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    next_run_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT saved_reports_user_name_key UNIQUE (user_id, name)
+);
+
+CREATE INDEX saved_reports_next_run_at_idx ON saved_reports(next_run_at)
+    WHERE next_run_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS saved_report_runs (
+    id BIGSERIAL PRIMARY KEY,
+    saved_report_id BIGINT NOT NULL REFERENCES saved_reports(id) ON DELETE CASCADE,
+    trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('success', 'failed')),
+    error TEXT NOT NULL DEFAULT '',
+    delivery TEXT NOT NULL CHECK (delivery IN ('sent', 'failed', 'skipped')),
+    delivery_error TEXT NOT NULL DEFAULT '',
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    output BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX saved_report_runs_report_idx ON saved_report_runs(saved_report_id, created_at DESC);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS saved_report_runs;
+DROP TABLE IF EXISTS saved_reports;
+
+-- +goose StatementEnd
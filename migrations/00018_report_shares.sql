@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the link itself is a signed token carrying the share id; a frozen share keeps
+-- the report as it was when the link was made, a live one is rebuilt on every view
+
+CREATE TABLE IF NOT EXISTS report_shares (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    title TEXT NOT NULL DEFAULT '',
+    mode TEXT NOT NULL CHECK (mode IN ('live', 'frozen')),
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    snapshot JSONB,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (to_date >= from_date)
+);
+
+CREATE INDEX report_shares_project_idx ON report_shares(project_id);
+
+CREATE TABLE IF NOT EXISTS report_share_accesses (
+    id BIGSERIAL PRIMARY KEY,
+    share_id BIGINT NOT NULL REFERENCES report_shares(id) ON DELETE CASCADE,
+    outcome TEXT NOT NULL CHECK (outcome IN ('ok', 'expired', 'revoked')),
+    format TEXT NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    accessed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX report_share_accesses_share_idx ON report_share_accesses(share_id, accessed_at DESC);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS report_share_accesses;
+DROP TABLE IF EXISTS report_shares;
+
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+-- archived projects keep their sessions, but nothing new can be logged on them:
+-- no new sessions and no moving sessions onto them, whichever path writes them
+CREATE OR REPLACE FUNCTION reject_archived_project_sessions() RETURNS trigger AS $$
+BEGIN
+    IF NEW.project_id IS NOT NULL
+       AND (TG_OP = 'INSERT' OR NEW.project_id IS DISTINCT FROM OLD.project_id)
+       AND EXISTS (SELECT 1 FROM projects WHERE id = NEW.project_id AND archived_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'work_sessions_project_archived: project % is archived', NEW.project_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER work_sessions_project_archived
+BEFORE INSERT OR UPDATE OF project_id ON work_sessions
+FOR EACH ROW EXECUTE FUNCTION reject_archived_project_sessions();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS work_sessions_project_archived ON work_sessions;
+DROP FUNCTION IF EXISTS reject_archived_project_sessions();
+ALTER TABLE projects DROP COLUMN IF EXISTS archived_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- users can only log time on the projects they're assigned to; admins on any project
+
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX project_members_user_idx ON project_members(user_id);
+
+-- everyone keeps the projects they have already logged time on
+INSERT INTO project_members (project_id, user_id)
+SELECT DISTINCT project_id, user_id
+FROM work_sessions
+WHERE project_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS project_members;
+
+-- +goose StatementEnd
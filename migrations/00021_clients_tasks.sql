@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- clients own projects, projects own tasks; sessions can name a task of their project
+
+CREATE TABLE IF NOT EXISTS clients (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX clients_name_key ON clients (LOWER(name));
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS client_id BIGINT REFERENCES clients(id) ON DELETE SET NULL;
+
+CREATE INDEX projects_client_idx ON projects(client_id);
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (id, project_id)
+);
+
+CREATE UNIQUE INDEX tasks_project_name_key ON tasks (project_id, LOWER(name));
+
+-- the pair keeps a session's task inside the session's project
+ALTER TABLE work_sessions ADD COLUMN IF NOT EXISTS task_id BIGINT;
+ALTER TABLE work_sessions ADD CONSTRAINT work_sessions_task_fkey
+    FOREIGN KEY (task_id, project_id) REFERENCES tasks(id, project_id);
+
+CREATE INDEX work_sessions_task_idx ON work_sessions(task_id) WHERE task_id IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE work_sessions DROP CONSTRAINT IF EXISTS work_sessions_task_fkey;
+ALTER TABLE work_sessions DROP COLUMN IF EXISTS task_id;
+DROP TABLE IF EXISTS tasks;
+ALTER TABLE projects DROP COLUMN IF EXISTS client_id;
+DROP TABLE IF EXISTS clients;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a manager is a member with admin rights over one project's sessions and members
+ALTER TABLE project_members ADD COLUMN IF NOT EXISTS is_manager BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX project_members_manager_idx ON project_members(user_id) WHERE is_manager;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS project_members_manager_idx;
+ALTER TABLE project_members DROP COLUMN IF EXISTS is_manager;
+
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE statuses ADD COLUMN IF NOT EXISTS accepts_time BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE statuses SET accepts_time = FALSE WHERE name = 'inactive';
+
+-- the statuses a project can move to; a status without any rows can move to every status
+CREATE TABLE IF NOT EXISTS status_transitions (
+    from_status_id BIGINT NOT NULL REFERENCES statuses(id) ON DELETE CASCADE,
+    to_status_id BIGINT NOT NULL REFERENCES statuses(id) ON DELETE CASCADE,
+    PRIMARY KEY (from_status_id, to_status_id),
+    CHECK (from_status_id <> to_status_id)
+);
+
+CREATE OR REPLACE FUNCTION reject_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status_id IS DISTINCT FROM OLD.status_id
+       AND EXISTS (SELECT 1 FROM status_transitions WHERE from_status_id = OLD.status_id)
+       AND NOT EXISTS (
+            SELECT 1 FROM status_transitions
+            WHERE from_status_id = OLD.status_id AND to_status_id = NEW.status_id
+       ) THEN
+        RAISE EXCEPTION 'projects_status_transition: project % can''t move from status % to %', NEW.id, OLD.status_id, NEW.status_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER projects_status_transition
+BEFORE UPDATE OF status_id ON projects
+FOR EACH ROW EXECUTE FUNCTION reject_status_transition();
+
+-- running sessions need a status that accepts time: no starting one on such a project, and no
+-- moving one onto it. Finished sessions can still be logged, e.g. to catch up on a closed project.
+CREATE OR REPLACE FUNCTION reject_closed_status_sessions() RETURNS trigger AS $$
+BEGIN
+    IF NEW.end_at IS NULL
+       AND (TG_OP = 'INSERT' OR NEW.project_id IS DISTINCT FROM OLD.project_id)
+       AND EXISTS (
+            SELECT 1 FROM projects p
+            JOIN statuses s ON s.id = p.status_id
+            WHERE p.id = NEW.project_id AND NOT s.accepts_time
+       ) THEN
+        RAISE EXCEPTION 'work_sessions_status_closed: the status of project % doesn''t accept time', NEW.project_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER work_sessions_status_closed
+BEFORE INSERT OR UPDATE OF project_id ON work_sessions
+FOR EACH ROW EXECUTE FUNCTION reject_closed_status_sessions();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS work_sessions_status_closed ON work_sessions;
+DROP FUNCTION IF EXISTS reject_closed_status_sessions();
+DROP TRIGGER IF EXISTS projects_status_transition ON projects;
+DROP FUNCTION IF EXISTS reject_status_transition();
+DROP TABLE IF EXISTS status_transitions;
+ALTER TABLE statuses DROP COLUMN IF EXISTS accepts_time;
+
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- one row per changed field; values are JSON so every field fits, and statuses and clients
+-- keep their name as it was at the time
+CREATE TABLE IF NOT EXISTS project_history (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    old_value JSONB,
+    new_value JSONB,
+    changed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS project_history_project_idx ON project_history(project_id, changed_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS project_history;
+
+-- +goose StatementEnd
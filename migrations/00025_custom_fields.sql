@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- admin-defined fields for projects and sessions; the handlers validate values against them
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id BIGSERIAL PRIMARY KEY,
+    entity TEXT NOT NULL CHECK (entity IN ('project', 'session')),
+    key TEXT NOT NULL CHECK (key ~ '^[a-z][a-z0-9_]{0,49}$'),
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('text', 'number', 'enum', 'date')),
+    options TEXT[] NOT NULL DEFAULT '{}',
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT custom_fields_entity_key_key UNIQUE (entity, key)
+);
+
+-- values by field key
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE work_sessions ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS work_sessions_custom_fields_idx ON work_sessions USING GIN (custom_fields jsonb_path_ops);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS work_sessions_custom_fields_idx;
+ALTER TABLE work_sessions DROP COLUMN IF EXISTS custom_fields;
+ALTER TABLE projects DROP COLUMN IF EXISTS custom_fields;
+DROP TABLE IF EXISTS custom_fields;
+
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the effort a project is expected to take and the day it should be done;
+-- unlike a budget they don't limit anything, they drive the forecast
+ALTER TABLE projects
+    ADD COLUMN IF NOT EXISTS estimated_hours DOUBLE PRECISION CHECK (estimated_hours > 0),
+    ADD COLUMN IF NOT EXISTS target_end_date DATE;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE projects
+    DROP COLUMN IF EXISTS target_end_date,
+    DROP COLUMN IF EXISTS estimated_hours;
+
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- projects a user pinned for starting timers quickly; recent and frequent ones come from work_sessions
+
+CREATE TABLE IF NOT EXISTS favorite_projects (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, project_id)
+);
+
+CREATE INDEX IF NOT EXISTS work_sessions_user_project_start_idx
+    ON work_sessions(user_id, project_id, start_at DESC);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS work_sessions_user_project_start_idx;
+DROP TABLE IF EXISTS favorite_projects;
+
+-- +goose StatementEnd
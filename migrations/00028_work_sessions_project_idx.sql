@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the project list totals the sessions of one page of projects at a time
+CREATE INDEX IF NOT EXISTS work_sessions_project_start_idx ON work_sessions(project_id, start_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS work_sessions_project_start_idx;
+
+-- +goose StatementEnd